@@ -0,0 +1,307 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
+)
+
+const (
+	// maxBurstEvents força o flush de rajadas muito longas
+	maxBurstEvents = 50
+	// maxWaitFactor limita a espera total a N janelas mesmo com mensagens contínuas
+	maxWaitFactor = 3
+	// maxFlushAttempts descarta a rajada após N falhas seguidas (ex.: cliente removido)
+	maxFlushAttempts = 5
+	// storeTimeout limita as operações do Store fora do contexto da requisição
+	storeTimeout = 5 * time.Second
+)
+
+// retryDelay espaça novas tentativas de uma rajada cujo Flush falhou (var para os testes)
+var retryDelay = 10 * time.Second
+
+// Store persiste os eventos segurados para que sobrevivam a um restart do processo
+type Store interface {
+	Save(ctx context.Context, secretID, burstKey string, entry []byte) (int64, error)
+	List(ctx context.Context) ([]models.BufferedEvent, error)
+	Delete(ctx context.Context, ids []int64) error
+}
+
+// Entry é um evento candidato a agregação
+type Entry struct {
+	SecretID string
+	// JSONData do evento; chat/sender vêm de webhook.ExtractEventInfo
+	JSONData string
+	// Info do pipeline: fallback de chat/sender para providers fora do formato wuzapi
+	Info *webhook.EventInfo
+	// Payload é o evento como seria entregue individualmente (JSON)
+	Payload   json.RawMessage
+	Timestamp time.Time
+	RouteURL  string
+	// ID da linha no Store (0 sem persistência)
+	ID int64 `json:"-"`
+}
+
+// Batch é a rajada consolidada de um chat/sender, com os eventos originais em ordem
+type Batch struct {
+	SecretID string
+	Chat     string
+	Sender   string
+	RouteURL string
+	// Info do último evento (seleção de destinos)
+	Info *webhook.EventInfo
+	// EventAt é o timestamp do primeiro evento (ordenação por chat)
+	EventAt *time.Time
+	Events  []json.RawMessage
+}
+
+type burst struct {
+	key     string
+	chat    string
+	sender  string
+	entries []Entry
+	first   time.Time
+	timer   *time.Timer
+	// attempts conta os Flush que falharam
+	attempts int
+}
+
+// Aggregator segura mensagens do mesmo chat/sender por uma janela deslizante (debounce)
+// e entrega a rajada inteira de uma vez via Flush. Com Store, cada evento é gravado antes
+// de ser aceito e só é apagado depois que a rajada foi enfileirada.
+type Aggregator struct {
+	mu      sync.Mutex
+	pending map[string]*burst
+	closed  bool
+	store   Store
+	logger  *slog.Logger
+	// Flush recebe cada rajada fechada; chamado fora do lock. Erro mantém a rajada para nova tentativa.
+	Flush func(Batch) error
+}
+
+// NewAggregator cria o agregador; store nil mantém as rajadas só em memória
+func NewAggregator(store Store, logger *slog.Logger) *Aggregator {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Aggregator{pending: make(map[string]*burst), store: store, logger: logger}
+}
+
+// burstKey identifica a rajada do evento; ok=false quando não pode ser agregado (sem chat ou grupo)
+func burstKey(e Entry) (key, chat, sender string, ok bool) {
+	isGroup, chat, sender, reason := webhook.ExtractEventInfo(e.JSONData)
+	if (reason == "json_parse_failed" || chat == "") && e.Info != nil {
+		isGroup, chat, sender = e.Info.IsGroup, e.Info.Chat, e.Info.Sender
+	}
+	if chat == "" || isGroup {
+		return "", "", "", false
+	}
+	return e.SecretID + "|" + chat + "|" + sender, chat, sender, true
+}
+
+// Add agenda o evento na rajada do chat/sender. Retorna false quando o evento não pode
+// ser agregado (sem chat, grupo, shutdown ou falha ao persistir) e deve seguir o fluxo normal.
+func (a *Aggregator) Add(ctx context.Context, e Entry, window time.Duration) bool {
+	if window <= 0 {
+		return false
+	}
+	key, chat, sender, ok := burstKey(e)
+	if !ok {
+		return false
+	}
+	a.mu.Lock()
+	closed := a.closed
+	a.mu.Unlock()
+	if closed {
+		return false
+	}
+	if a.store != nil {
+		raw, err := json.Marshal(e)
+		if err == nil {
+			e.ID, err = a.store.Save(ctx, e.SecretID, key, raw)
+		}
+		if err != nil {
+			// Sem persistência o evento não é aceito na rajada: segue o fluxo normal
+			a.logger.Error("Erro ao persistir evento da rajada", "secret_id", e.SecretID, "chat", chat, "err", err)
+			return false
+		}
+	}
+
+	a.mu.Lock()
+	if a.closed {
+		// Shutdown começou durante o Save: o evento persistido volta no Recover
+		a.mu.Unlock()
+		return e.ID != 0
+	}
+	b, ok := a.pending[key]
+	if !ok {
+		b = &burst{key: key, chat: chat, sender: sender, first: time.Now()}
+		a.pending[key] = b
+	}
+	b.entries = append(b.entries, e)
+	full := len(b.entries) >= maxBurstEvents || time.Since(b.first) >= window*maxWaitFactor
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	if full {
+		delete(a.pending, key)
+		a.mu.Unlock()
+		a.emit(b)
+		return true
+	}
+	b.timer = time.AfterFunc(window, func() { a.fire(key, b) })
+	a.mu.Unlock()
+	return true
+}
+
+// fire fecha a rajada quando o timer expira sem novas mensagens
+func (a *Aggregator) fire(key string, b *burst) {
+	a.mu.Lock()
+	if a.pending[key] != b {
+		// Já foi fechada (limite atingido ou FlushAll)
+		a.mu.Unlock()
+		return
+	}
+	delete(a.pending, key)
+	a.mu.Unlock()
+	a.emit(b)
+}
+
+// FlushAll fecha todas as rajadas pendentes no shutdown; as que falharem ficam no Store para Recover
+func (a *Aggregator) FlushAll() {
+	a.mu.Lock()
+	a.closed = true
+	pending := a.pending
+	a.pending = make(map[string]*burst)
+	a.mu.Unlock()
+	for _, b := range pending {
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+		a.emit(b)
+	}
+}
+
+// Pending retorna quantas rajadas aguardam flush
+func (a *Aggregator) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.pending)
+}
+
+// Recover reenvia as rajadas que ficaram no Store (processo encerrado antes do flush).
+// Chame depois de configurar Flush e antes de aceitar requisições.
+func (a *Aggregator) Recover(ctx context.Context) error {
+	if a.store == nil {
+		return nil
+	}
+	rows, err := a.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	bursts := map[string]*burst{}
+	var order []*burst
+	for _, row := range rows {
+		var e Entry
+		if err := json.Unmarshal(row.Entry, &e); err != nil {
+			a.logger.Error("Evento de rajada ilegível descartado", "id", row.ID, "err", err)
+			a.deleteStored([]int64{row.ID})
+			continue
+		}
+		e.ID = row.ID
+		b, ok := bursts[row.BurstKey]
+		if !ok {
+			_, chat, sender, _ := burstKey(e)
+			b = &burst{key: row.BurstKey, chat: chat, sender: sender, first: row.CreatedAt}
+			bursts[row.BurstKey] = b
+			order = append(order, b)
+		}
+		b.entries = append(b.entries, e)
+	}
+	for _, b := range order {
+		a.logger.Info("burst_recovered", "secret_id", b.entries[0].SecretID, "chat", b.chat, "count", len(b.entries))
+		a.emit(b)
+	}
+	return nil
+}
+
+// emit ordena por timestamp (chegada como desempate) e entrega a rajada
+func (a *Aggregator) emit(b *burst) {
+	entries := b.entries
+	if a.Flush == nil || len(entries) == 0 {
+		return
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		ti, tj := entries[i].Timestamp, entries[j].Timestamp
+		if ti.IsZero() || tj.IsZero() {
+			return false
+		}
+		return ti.Before(tj)
+	})
+	last := entries[len(entries)-1]
+	batch := Batch{SecretID: last.SecretID, Chat: b.chat, Sender: b.sender, RouteURL: last.RouteURL, Info: last.Info}
+	if first := entries[0].Timestamp; !first.IsZero() {
+		batch.EventAt = &first
+	}
+	ids := make([]int64, 0, len(entries))
+	for _, e := range entries {
+		batch.Events = append(batch.Events, e.Payload)
+		if e.ID != 0 {
+			ids = append(ids, e.ID)
+		}
+	}
+	if err := a.Flush(batch); err != nil {
+		a.retry(b, err)
+		return
+	}
+	a.deleteStored(ids)
+}
+
+// retry reagenda a rajada que falhou; no shutdown ela fica no Store para Recover
+func (a *Aggregator) retry(b *burst, err error) {
+	b.attempts++
+	logger := a.logger.With("secret_id", b.entries[0].SecretID, "chat", b.chat, "count", len(b.entries), "attempts", b.attempts, "err", err)
+	if b.attempts >= maxFlushAttempts {
+		logger.Error("Rajada descartada após falhas seguidas no flush")
+		ids := make([]int64, 0, len(b.entries))
+		for _, e := range b.entries {
+			if e.ID != 0 {
+				ids = append(ids, e.ID)
+			}
+		}
+		a.deleteStored(ids)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		logger.Warn("Flush da rajada falhou no shutdown; mantida para a próxima inicialização")
+		return
+	}
+	logger.Warn("Flush da rajada falhou; nova tentativa agendada", "retry_in", retryDelay)
+	if nb, ok := a.pending[b.key]; ok {
+		// Chegaram eventos novos para o mesmo chat: os antigos vão na frente da nova rajada
+		nb.entries = append(b.entries, nb.entries...)
+		nb.first, nb.attempts = b.first, b.attempts
+		return
+	}
+	a.pending[b.key] = b
+	b.timer = time.AfterFunc(retryDelay, func() { a.fire(b.key, b) })
+}
+
+func (a *Aggregator) deleteStored(ids []int64) {
+	if a.store == nil || len(ids) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := a.store.Delete(ctx, ids); err != nil {
+		a.logger.Error("Erro ao remover eventos de rajada entregues", "count", len(ids), "err", err)
+	}
+}
@@ -0,0 +1,302 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+)
+
+// memStore simula burst_buffer
+type memStore struct {
+	mu      sync.Mutex
+	rows    []models.BufferedEvent
+	next    int64
+	failAdd bool
+}
+
+func (s *memStore) Save(_ context.Context, secretID, burstKey string, entry []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failAdd {
+		return 0, errors.New("db fora")
+	}
+	s.next++
+	s.rows = append(s.rows, models.BufferedEvent{ID: s.next, SecretID: secretID, BurstKey: burstKey, Entry: entry, CreatedAt: time.Now()})
+	return s.next, nil
+}
+
+func (s *memStore) List(context.Context) ([]models.BufferedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.BufferedEvent(nil), s.rows...), nil
+}
+
+func (s *memStore) Delete(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	drop := map[int64]bool{}
+	for _, id := range ids {
+		drop[id] = true
+	}
+	kept := s.rows[:0]
+	for _, r := range s.rows {
+		if !drop[r.ID] {
+			kept = append(kept, r)
+		}
+	}
+	s.rows = kept
+	return nil
+}
+
+func (s *memStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.rows)
+}
+
+// flushes coleta as rajadas entregues; fail faz os próximos N Flush falharem
+type flushes struct {
+	mu      sync.Mutex
+	batches []Batch
+	fail    int
+	ch      chan Batch
+}
+
+func (f *flushes) flush(b Batch) error {
+	f.mu.Lock()
+	if f.fail > 0 {
+		f.fail--
+		f.mu.Unlock()
+		return errors.New("fila indisponível")
+	}
+	f.batches = append(f.batches, b)
+	f.mu.Unlock()
+	f.ch <- b
+	return nil
+}
+
+func newTestAggregator(store Store) (*Aggregator, *flushes) {
+	f := &flushes{ch: make(chan Batch, 100)}
+	a := NewAggregator(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	a.Flush = f.flush
+	return a, f
+}
+
+func entry(chat, text string) Entry {
+	data := fmt.Sprintf(`{"type":"Message","event":{"Info":{"Chat":%q,"Sender":%q,"IsGroup":false}}}`, chat, chat)
+	return Entry{SecretID: "s1", JSONData: data, Payload: json.RawMessage(fmt.Sprintf(`{"text":%q}`, text))}
+}
+
+func waitBatch(t *testing.T, f *flushes, timeout time.Duration) Batch {
+	t.Helper()
+	select {
+	case b := <-f.ch:
+		return b
+	case <-time.After(timeout):
+		t.Fatal("rajada não foi entregue")
+		return Batch{}
+	}
+}
+
+func noBatch(t *testing.T, f *flushes, wait time.Duration) {
+	t.Helper()
+	select {
+	case b := <-f.ch:
+		t.Fatalf("rajada entregue antes da hora: %s (%d eventos)", b.Chat, len(b.Events))
+	case <-time.After(wait):
+	}
+}
+
+func TestAddWindowExtends(t *testing.T) {
+	a, f := newTestAggregator(nil)
+	const window = 150 * time.Millisecond
+	ctx := context.Background()
+
+	a.Add(ctx, entry("1@s.whatsapp.net", "a"), window)
+	time.Sleep(100 * time.Millisecond)
+	a.Add(ctx, entry("1@s.whatsapp.net", "b"), window)
+	// 200ms após o primeiro evento, mas só 100ms após o último: a janela foi estendida
+	noBatch(t, f, 100*time.Millisecond)
+
+	b := waitBatch(t, f, time.Second)
+	if len(b.Events) != 2 || string(b.Events[0]) != `{"text":"a"}` || string(b.Events[1]) != `{"text":"b"}` {
+		t.Fatalf("eventos = %s", b.Events)
+	}
+	if a.Pending() != 0 {
+		t.Fatalf("Pending = %d", a.Pending())
+	}
+}
+
+func TestAddMaxWait(t *testing.T) {
+	a, f := newTestAggregator(nil)
+	const window = 40 * time.Millisecond
+	ctx := context.Background()
+
+	// Mensagens contínuas não seguram a rajada além de maxWaitFactor janelas
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		a.Add(ctx, entry("1@s.whatsapp.net", "x"), window)
+		if len(f.ch) > 0 {
+			break
+		}
+		time.Sleep(window / 2)
+	}
+	b := waitBatch(t, f, 10*time.Millisecond)
+	if len(b.Events) < 2 {
+		t.Fatalf("eventos = %d", len(b.Events))
+	}
+}
+
+func TestAddMaxBurstEvents(t *testing.T) {
+	a, f := newTestAggregator(nil)
+	ctx := context.Background()
+	for i := 0; i < maxBurstEvents; i++ {
+		if !a.Add(ctx, entry("1@s.whatsapp.net", fmt.Sprint(i)), time.Hour) {
+			t.Fatal("evento não agregado")
+		}
+	}
+	// O evento que completa a rajada dispara o flush na hora
+	b := waitBatch(t, f, 10*time.Millisecond)
+	if len(b.Events) != maxBurstEvents {
+		t.Fatalf("eventos = %d, want %d", len(b.Events), maxBurstEvents)
+	}
+	if a.Pending() != 0 {
+		t.Fatalf("Pending = %d", a.Pending())
+	}
+}
+
+func TestAddPerChatTimers(t *testing.T) {
+	a, f := newTestAggregator(nil)
+	const window = 150 * time.Millisecond
+	ctx := context.Background()
+
+	a.Add(ctx, entry("1@s.whatsapp.net", "a"), window)
+	time.Sleep(100 * time.Millisecond)
+	a.Add(ctx, entry("2@s.whatsapp.net", "b"), window)
+
+	// Evento no chat 2 não estende a janela do chat 1
+	if b := waitBatch(t, f, 100*time.Millisecond); b.Chat != "1@s.whatsapp.net" {
+		t.Fatalf("1ª rajada do chat %s", b.Chat)
+	}
+	if a.Pending() != 1 {
+		t.Fatalf("Pending = %d, want 1", a.Pending())
+	}
+	if b := waitBatch(t, f, time.Second); b.Chat != "2@s.whatsapp.net" {
+		t.Fatalf("2ª rajada do chat %s", b.Chat)
+	}
+}
+
+func TestAddRejectsGroups(t *testing.T) {
+	a, _ := newTestAggregator(nil)
+	e := entry("123@g.us", "a")
+	e.JSONData = `{"type":"Message","event":{"Info":{"Chat":"123@g.us","IsGroup":true}}}`
+	if a.Add(context.Background(), e, time.Second) {
+		t.Fatal("evento de grupo agregado")
+	}
+	if a.Add(context.Background(), entry("1@s.whatsapp.net", "a"), 0) {
+		t.Fatal("janela 0 agregou")
+	}
+}
+
+func TestStoreLifecycle(t *testing.T) {
+	store := &memStore{}
+	a, f := newTestAggregator(store)
+	ctx := context.Background()
+
+	a.Add(ctx, entry("1@s.whatsapp.net", "a"), time.Hour)
+	a.Add(ctx, entry("1@s.whatsapp.net", "b"), time.Hour)
+	if store.len() != 2 {
+		t.Fatalf("persistidos = %d, want 2", store.len())
+	}
+	a.FlushAll()
+	waitBatch(t, f, time.Second)
+	if store.len() != 0 {
+		t.Fatalf("restaram %d eventos após o flush", store.len())
+	}
+	// Após o shutdown nada é aceito na rajada
+	if a.Add(ctx, entry("1@s.whatsapp.net", "c"), time.Hour) {
+		t.Fatal("Add aceito após FlushAll")
+	}
+}
+
+func TestAddStoreFailure(t *testing.T) {
+	a, _ := newTestAggregator(&memStore{failAdd: true})
+	// Evento não persistido não pode ser aceito (202) só em memória
+	if a.Add(context.Background(), entry("1@s.whatsapp.net", "a"), time.Hour) {
+		t.Fatal("evento aceito sem persistência")
+	}
+	if a.Pending() != 0 {
+		t.Fatalf("Pending = %d", a.Pending())
+	}
+}
+
+func TestFlushErrorRetries(t *testing.T) {
+	old := retryDelay
+	retryDelay = 50 * time.Millisecond
+	t.Cleanup(func() { retryDelay = old })
+
+	store := &memStore{}
+	a, f := newTestAggregator(store)
+	f.fail = 1
+	a.Add(context.Background(), entry("1@s.whatsapp.net", "a"), 20*time.Millisecond)
+
+	b := waitBatch(t, f, time.Second)
+	if len(b.Events) != 1 {
+		t.Fatalf("eventos = %d", len(b.Events))
+	}
+	if store.len() != 0 {
+		t.Fatalf("restaram %d eventos após o retry", store.len())
+	}
+}
+
+func TestFlushErrorOnShutdownKeepsStore(t *testing.T) {
+	store := &memStore{}
+	a, f := newTestAggregator(store)
+	f.fail = 1
+	a.Add(context.Background(), entry("1@s.whatsapp.net", "a"), time.Hour)
+	a.FlushAll()
+	if store.len() != 1 {
+		t.Fatalf("persistidos = %d, want 1 (para o Recover)", store.len())
+	}
+
+	// Próxima inicialização: Recover entrega e limpa
+	b, f2 := newTestAggregator(store)
+	if err := b.Recover(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := waitBatch(t, f2, time.Second)
+	if got.Chat != "1@s.whatsapp.net" || len(got.Events) != 1 || string(got.Events[0]) != `{"text":"a"}` {
+		t.Fatalf("rajada recuperada = %+v", got)
+	}
+	if store.len() != 0 {
+		t.Fatalf("restaram %d eventos após o Recover", store.len())
+	}
+}
+
+func TestFlushGivesUp(t *testing.T) {
+	old := retryDelay
+	retryDelay = 5 * time.Millisecond
+	t.Cleanup(func() { retryDelay = old })
+
+	store := &memStore{}
+	a, f := newTestAggregator(store)
+	f.fail = maxFlushAttempts
+	a.Add(context.Background(), entry("1@s.whatsapp.net", "a"), 5*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for store.len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if store.len() != 0 || a.Pending() != 0 {
+		t.Fatalf("rajada não descartada: store=%d pending=%d", store.len(), a.Pending())
+	}
+	noBatch(t, f, 20*time.Millisecond)
+}
@@ -0,0 +1,217 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	Closed   State = "closed"
+	Open     State = "open"
+	HalfOpen State = "half_open"
+)
+
+type Options struct {
+	// Falhas consecutivas que abrem o circuito
+	FailureThreshold int
+	// Taxa de erro (0..1) que abre o circuito, avaliada com ao menos MinRequests na janela
+	ErrorRate   float64
+	MinRequests int
+	Window      time.Duration
+	// Tempo aberto antes de liberar probes (half-open)
+	OpenTimeout time.Duration
+	// Probes simultâneos permitidos em half-open
+	HalfOpenProbes int
+}
+
+// Breaker mantém um circuito por chave (destino; ver delivery.circuitKey)
+type Breaker struct {
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	opts     Options
+	// OnStateChange é chamado (fora do lock) uma vez por transição
+	OnStateChange func(key string, from, to State)
+}
+
+type circuit struct {
+	state       State
+	consecutive int
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probes      int
+	probeAt     time.Time
+	lastSeen    time.Time
+}
+
+// Snapshot é o estado exposto de um circuito
+type Snapshot struct {
+	State               State      `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+}
+
+func New(opts Options) *Breaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 5
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 20
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 30 * time.Second
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 1
+	}
+	return &Breaker{circuits: make(map[string]*circuit), opts: opts}
+}
+
+// Allow informa se uma requisição pode seguir para a chave. Com o circuito aberto,
+// retorna o tempo restante até a próxima janela de probe.
+func (b *Breaker) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	b.mu.Lock()
+	c := b.get(key, now)
+	halfOpened := false
+	if c.state == Open {
+		if wait := c.openedAt.Add(b.opts.OpenTimeout).Sub(now); wait > 0 {
+			b.mu.Unlock()
+			return false, wait
+		}
+		c.state, c.probes, halfOpened = HalfOpen, 0, true
+	}
+	// Probe sem resultado por um OpenTimeout inteiro é considerado perdido
+	if c.state == HalfOpen && now.Sub(c.probeAt) > b.opts.OpenTimeout {
+		c.probes = 0
+	}
+	allowed := c.state == Closed || c.probes < b.opts.HalfOpenProbes
+	if allowed && c.state == HalfOpen {
+		c.probes++
+		c.probeAt = now
+	}
+	b.mu.Unlock()
+	if halfOpened {
+		b.notify(key, Open, HalfOpen)
+	}
+	if !allowed {
+		return false, b.opts.OpenTimeout
+	}
+	return true, 0
+}
+
+// Success registra uma resposta do destino (inclusive 4xx definitivos: o endpoint está de pé)
+func (b *Breaker) Success(key string) {
+	now := time.Now()
+	b.mu.Lock()
+	c := b.get(key, now)
+	c.requests++
+	c.consecutive = 0
+	// Resposta atrasada com o circuito aberto não fecha: só o probe em half-open
+	recovered := c.state == HalfOpen
+	if recovered {
+		c.state = Closed
+		c.probes = 0
+		c.requests, c.failures, c.windowStart = 0, 0, now
+	}
+	b.mu.Unlock()
+	if recovered {
+		b.notify(key, HalfOpen, Closed)
+	}
+}
+
+// Failure registra erro de rede/timeout ou status retentável
+func (b *Breaker) Failure(key string) {
+	now := time.Now()
+	b.mu.Lock()
+	c := b.get(key, now)
+	c.requests++
+	c.failures++
+	c.consecutive++
+	from := c.state
+	trip := false
+	switch c.state {
+	case HalfOpen:
+		trip = true
+	case Closed:
+		rateTrip := c.requests >= b.opts.MinRequests && b.opts.ErrorRate > 0 &&
+			float64(c.failures)/float64(c.requests) >= b.opts.ErrorRate
+		trip = c.consecutive >= b.opts.FailureThreshold || rateTrip
+	}
+	if trip {
+		c.state = Open
+		c.openedAt = now
+		c.probes = 0
+	}
+	b.mu.Unlock()
+	if trip && from != Open {
+		b.notify(key, from, Open)
+	}
+}
+
+// State retorna o estado atual da chave (closed se nunca vista)
+func (b *Breaker) State(key string) State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		return c.state
+	}
+	return Closed
+}
+
+// Snapshot lista os circuitos conhecidos
+func (b *Breaker) Snapshot() map[string]Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]Snapshot, len(b.circuits))
+	for k, c := range b.circuits {
+		s := Snapshot{State: c.state, ConsecutiveFailures: c.consecutive}
+		if c.state != Closed {
+			t := c.openedAt
+			s.OpenedAt = &t
+		}
+		out[k] = s
+	}
+	return out
+}
+
+// Prune remove circuitos fechados sem tráfego há mais de idle; retorna quantos saíram
+func (b *Breaker) Prune(idle time.Duration) int {
+	cutoff := time.Now().Add(-idle)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for k, c := range b.circuits {
+		if c.state == Closed && c.lastSeen.Before(cutoff) {
+			delete(b.circuits, k)
+			n++
+		}
+	}
+	return n
+}
+
+// get retorna o circuito da chave, reiniciando a janela de taxa de erro quando expirada
+func (b *Breaker) get(key string, now time.Time) *circuit {
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{state: Closed, windowStart: now}
+		b.circuits[key] = c
+	}
+	c.lastSeen = now
+	if now.Sub(c.windowStart) > b.opts.Window {
+		c.requests, c.failures, c.windowStart = 0, 0, now
+	}
+	return c
+}
+
+func (b *Breaker) notify(key string, from, to State) {
+	if b.OnStateChange != nil {
+		b.OnStateChange(key, from, to)
+	}
+}
@@ -0,0 +1,37 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneIdleClosed(t *testing.T) {
+	b := New(Options{FailureThreshold: 1, OpenTimeout: time.Hour})
+	b.Success("ativo")
+	b.Success("ocioso")
+	b.Failure("aberto")
+	if b.State("aberto") != Open {
+		t.Fatal("circuito não abriu")
+	}
+
+	// Simula tráfego antigo em dois circuitos; o ativo acabou de ser usado
+	b.mu.Lock()
+	b.circuits["ocioso"].lastSeen = time.Now().Add(-2 * time.Hour)
+	b.circuits["aberto"].lastSeen = time.Now().Add(-2 * time.Hour)
+	b.mu.Unlock()
+
+	if n := b.Prune(time.Hour); n != 1 {
+		t.Fatalf("Prune = %d, want 1", n)
+	}
+	snap := b.Snapshot()
+	if _, ok := snap["ocioso"]; ok {
+		t.Fatal("circuito ocioso mantido")
+	}
+	// Circuito aberto fica mesmo sem tráfego: o estado ainda segura a fila
+	if _, ok := snap["aberto"]; !ok {
+		t.Fatal("circuito aberto removido")
+	}
+	if _, ok := snap["ativo"]; !ok {
+		t.Fatal("circuito ativo removido")
+	}
+}
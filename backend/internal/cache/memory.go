@@ -2,6 +2,7 @@ package cache
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,6 +11,9 @@ type MemoryCache[K comparable, V any] struct {
 	ttl      time.Duration
 	items    map[K]entry[V]
 	stopChan chan struct{}
+	// Contadores de Get para métricas
+	hits   atomic.Uint64
+	misses atomic.Uint64
 }
 
 type entry[V any] struct {
@@ -31,17 +35,36 @@ func (c *MemoryCache[K, V]) Get(key K) (V, bool) {
 	e, ok := c.items[key]
 	c.mu.RUnlock()
 	if !ok || time.Now().After(e.expiresAt) {
+		c.misses.Add(1)
 		return zero, false
 	}
+	c.hits.Add(1)
 	return e.value, true
 }
 
+// Stats retorna os hits/misses acumulados de Get
+func (c *MemoryCache[K, V]) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
 func (c *MemoryCache[K, V]) Set(key K, value V) {
 	c.mu.Lock()
 	c.items[key] = entry[V]{value: value, expiresAt: time.Now().Add(c.ttl)}
 	c.mu.Unlock()
 }
 
+// SetIfAbsent grava apenas se a chave não existir (ou estiver expirada). Retorna true se gravou.
+func (c *MemoryCache[K, V]) SetIfAbsent(key K, value V) bool {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok && !now.After(e.expiresAt) {
+		return false
+	}
+	c.items[key] = entry[V]{value: value, expiresAt: now.Add(c.ttl)}
+	return true
+}
+
 func (c *MemoryCache[K, V]) Delete(key K) {
 	c.mu.Lock()
 	delete(c.items, key)
@@ -3,18 +3,70 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Port              string
-	DatabaseURL       string
-	CacheTTL          time.Duration
-	AdminIngestURL    string
-	AdminServiceToken string
-	SlackWebhookURL   string
-	SlackBotToken     string
-	SlackChannelID    string
+	Port        string
+	DatabaseURL string
+	// Logs: nível (debug | info | warn | error) e formato (json | text)
+	LogLevel       string
+	LogFormat      string
+	CacheTTL       time.Duration
+	LIDCacheTTL    time.Duration
+	LIDMissTTL     time.Duration
+	APIKeyCacheTTL time.Duration
+	AdminIngestURL string
+	// Lotes do shipper de ingest (ADMIN_INGEST_URL)
+	IngestBatchSize     int
+	IngestFlushInterval time.Duration
+	IngestBufferSize    int
+	IngestMaxRetries    int
+	AdminServiceToken   string
+	// Métricas Prometheus: modo (summary | events+summary) e limite de clientes com label próprio
+	MetricsMode            string
+	MetricsMaxClientLabels int
+	// Tracing OpenTelemetry: ativo quando há endpoint OTLP (OTEL_EXPORTER_OTLP_*ENDPOINT)
+	TracingEnabled     bool
+	TracingServiceName string
+	SlackWebhookURL    string
+	SlackBotToken      string
+	SlackChannelID     string
+	EncryptionKey      string
+	// APP_ENV=development permite subir sem ENCRYPTION_KEY (segredos gravados sem cifra)
+	AppEnv string
+	// Janela em que o segredo de assinatura anterior continua válido após rotação
+	SigningSecretGrace time.Duration
+	// URL padrão da API Avisa para conversão LID->JID (cliente pode sobrescrever)
+	AvisaAPIURL string
+
+	// Janela de deduplicação por ID de mensagem
+	DedupWindow time.Duration
+
+	// Fila de entregas (outbox)
+	DeliveryWorkers      int
+	DeliveryMaxAttempts  int
+	DeliveryBackoffBase  time.Duration
+	DeliveryBackoffMax   time.Duration
+	DeliveryPollInterval time.Duration
+	// Retenção do log de entregas (delivery_log)
+	DeliveryLogRetention time.Duration
+	// Retenção das entregas finalizadas (delivered/dead) na outbox, com suas tentativas
+	OutboxRetention time.Duration
+
+	// Prazo total do shutdown gracioso (HTTP em andamento, fila de entregas, ingest)
+	ShutdownTimeout time.Duration
+	// Entregas vencidas acima das quais o readyz responde 503 (0 desativa)
+	ReadyMaxBacklog int
+
+	// Circuit breaker por URL de destino
+	BreakerFailureThreshold int
+	BreakerErrorRate        float64
+	BreakerMinRequests      int
+	BreakerWindow           time.Duration
+	BreakerOpenTimeout      time.Duration
+	BreakerHalfOpenProbes   int
 }
 
 func Load() Config {
@@ -34,20 +86,86 @@ func Load() Config {
 	}
 
 	return Config{
-		Port:              port,
-		DatabaseURL:       dbURL,
-		CacheTTL:          time.Duration(ttlSeconds) * time.Second,
-		AdminIngestURL:    os.Getenv("ADMIN_INGEST_URL"),
-		AdminServiceToken: os.Getenv("ADMIN_SERVICE_TOKEN"),
-		SlackWebhookURL:   os.Getenv("SLACK_WEBHOOK_URL"),
-		SlackBotToken:     os.Getenv("SLACK_BOT_TOKEN"),
-		SlackChannelID:    os.Getenv("SLACK_CHANNEL_ID"),
+		Port:                port,
+		DatabaseURL:         dbURL,
+		LogLevel:            getenvDefault("LOG_LEVEL", "info"),
+		LogFormat:           getenvDefault("LOG_FORMAT", "json"),
+		CacheTTL:            time.Duration(ttlSeconds) * time.Second,
+		LIDCacheTTL:         time.Duration(getenvInt("LID_CACHE_TTL_SECONDS", 3600)) * time.Second,
+		LIDMissTTL:          time.Duration(getenvInt("LID_MISS_CACHE_TTL_SECONDS", 60)) * time.Second,
+		APIKeyCacheTTL:      time.Duration(getenvInt("API_KEY_CACHE_TTL_SECONDS", 30)) * time.Second,
+		AdminIngestURL:      os.Getenv("ADMIN_INGEST_URL"),
+		IngestBatchSize:     getenvInt("INGEST_BATCH_SIZE", 100),
+		IngestFlushInterval: time.Duration(getenvInt("INGEST_FLUSH_INTERVAL_MS", 2000)) * time.Millisecond,
+		IngestBufferSize:    getenvInt("INGEST_BUFFER_SIZE", 10000),
+		IngestMaxRetries:    getenvInt("INGEST_MAX_RETRIES", 3),
+		AdminServiceToken:   os.Getenv("ADMIN_SERVICE_TOKEN"),
+		MetricsMode:         getenvDefault("METRICS_MODE", "summary"),
+		// 0 desativa o label por cliente
+		MetricsMaxClientLabels: getenvNonNegativeInt("METRICS_MAX_CLIENT_LABELS", 100),
+		TracingEnabled:         tracingEnabled(),
+		TracingServiceName:     getenvDefault("OTEL_SERVICE_NAME", "ms-sdr"),
+		SlackWebhookURL:        os.Getenv("SLACK_WEBHOOK_URL"),
+		SlackBotToken:          os.Getenv("SLACK_BOT_TOKEN"),
+		SlackChannelID:         os.Getenv("SLACK_CHANNEL_ID"),
+		EncryptionKey:          os.Getenv("ENCRYPTION_KEY"),
+		AppEnv:                 getenvDefault("APP_ENV", "production"),
+		SigningSecretGrace:     time.Duration(getenvInt("SIGNING_SECRET_GRACE_HOURS", 24)) * time.Hour,
+		AvisaAPIURL:            os.Getenv("AVISA_API_URL"),
+
+		DedupWindow: time.Duration(getenvInt("DEDUP_WINDOW_SECONDS", 600)) * time.Second,
+
+		DeliveryWorkers:      getenvInt("DELIVERY_WORKERS", 4),
+		DeliveryMaxAttempts:  getenvInt("DELIVERY_MAX_ATTEMPTS", 8),
+		DeliveryBackoffBase:  time.Duration(getenvInt("DELIVERY_BACKOFF_BASE_MS", 2000)) * time.Millisecond,
+		DeliveryBackoffMax:   time.Duration(getenvInt("DELIVERY_BACKOFF_MAX_SECONDS", 600)) * time.Second,
+		DeliveryPollInterval: time.Duration(getenvInt("DELIVERY_POLL_INTERVAL_MS", 1000)) * time.Millisecond,
+		DeliveryLogRetention: time.Duration(getenvInt("DELIVERY_LOG_RETENTION_DAYS", 14)) * 24 * time.Hour,
+		OutboxRetention:      time.Duration(getenvInt("OUTBOX_RETENTION_DAYS", 7)) * 24 * time.Hour,
+		ShutdownTimeout:      time.Duration(getenvInt("SHUTDOWN_TIMEOUT_SECONDS", 25)) * time.Second,
+		ReadyMaxBacklog:      getenvNonNegativeInt("READY_MAX_BACKLOG", 5000),
+
+		BreakerFailureThreshold: getenvInt("BREAKER_FAILURE_THRESHOLD", 5),
+		BreakerErrorRate:        float64(getenvInt("BREAKER_ERROR_RATE_PERCENT", 50)) / 100,
+		BreakerMinRequests:      getenvInt("BREAKER_MIN_REQUESTS", 20),
+		BreakerWindow:           time.Duration(getenvInt("BREAKER_WINDOW_SECONDS", 60)) * time.Second,
+		BreakerOpenTimeout:      time.Duration(getenvInt("BREAKER_OPEN_SECONDS", 30)) * time.Second,
+		BreakerHalfOpenProbes:   getenvInt("BREAKER_HALF_OPEN_PROBES", 1),
 	}
 }
 
+// IsDevelopment indica APP_ENV=development
+func (c Config) IsDevelopment() bool {
+	return strings.EqualFold(strings.TrimSpace(c.AppEnv), "development")
+}
+
 func getenvDefault(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return def
 }
+
+// tracingEnabled liga o export OTLP quando há endpoint configurado e o SDK não foi desativado
+func tracingEnabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// getenvNonNegativeInt lê um inteiro >= 0 do ambiente (0 é um valor válido), com default
+func getenvNonNegativeInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
+
+// getenvInt lê um inteiro positivo do ambiente, com default
+func getenvInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,119 @@
+package dedup
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+)
+
+// Deduper detecta eventos repetidos por (secretId, messageId) dentro de uma janela.
+// Cache em memória na frente; Postgres cobre restarts e múltiplas instâncias.
+type Deduper struct {
+	cache  *cache.MemoryCache[string, struct{}]
+	repo   repository.ProcessedEventRepository
+	window time.Duration
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	hits     map[string]uint64
+	stopChan chan struct{}
+}
+
+func NewDeduper(repo repository.ProcessedEventRepository, window time.Duration, logger *slog.Logger) *Deduper {
+	return &Deduper{
+		cache:    cache.NewMemoryCache[string, struct{}](window),
+		repo:     repo,
+		window:   window,
+		logger:   logger,
+		hits:     make(map[string]uint64),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Seen marca a mensagem como processada e retorna true se ela já tinha sido vista.
+// A marca é feita antes do encaminhamento para barrar retries concorrentes; se o evento
+// não for aceito, o chamador deve liberá-la com Release. Falha no banco não bloqueia o evento (fail-open).
+func (d *Deduper) Seen(ctx context.Context, secretID, messageID string) bool {
+	if messageID == "" {
+		return false
+	}
+	if !d.cache.SetIfAbsent(secretID+"|"+messageID, struct{}{}) {
+		d.countHit(secretID)
+		return true
+	}
+	if d.repo == nil {
+		return false
+	}
+	fresh, err := d.repo.MarkProcessed(ctx, secretID, messageID, d.window)
+	if err != nil {
+		d.logger.Error("Erro no dedup (Postgres)", "secret_id", secretID, "message_id", messageID, "err", err)
+		return false
+	}
+	if !fresh {
+		d.countHit(secretID)
+		return true
+	}
+	return false
+}
+
+// Release desfaz o Seen quando o evento não foi entregue nem persistido, para o retry do provider passar
+func (d *Deduper) Release(ctx context.Context, secretID, messageID string) {
+	if messageID == "" {
+		return
+	}
+	d.cache.Delete(secretID + "|" + messageID)
+	if d.repo == nil {
+		return
+	}
+	if err := d.repo.Release(ctx, secretID, messageID); err != nil {
+		d.logger.Error("Erro ao liberar dedup (Postgres)", "secret_id", secretID, "message_id", messageID, "err", err)
+	}
+}
+
+func (d *Deduper) countHit(secretID string) {
+	d.mu.Lock()
+	d.hits[secretID]++
+	d.mu.Unlock()
+}
+
+// Hits retorna um snapshot dos duplicados barrados por secretId
+func (d *Deduper) Hits() map[string]uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[string]uint64, len(d.hits))
+	for k, v := range d.hits {
+		out[k] = v
+	}
+	return out
+}
+
+// StartJanitor limpa o cache e remove do Postgres registros fora da janela
+func (d *Deduper) StartJanitor() {
+	go d.cache.StartJanitor()
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if d.repo == nil {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if _, err := d.repo.Prune(ctx, d.window); err != nil {
+				d.logger.Error("Erro ao limpar processed_events", "err", err)
+			}
+			cancel()
+		case <-d.stopChan:
+			return
+		}
+	}
+}
+
+func (d *Deduper) StopJanitor() {
+	d.cache.StopJanitor()
+	close(d.stopChan)
+}
@@ -0,0 +1,40 @@
+package delivery
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Backoff calcula a espera antes da tentativa seguinte: exponencial com jitter
+// (metade fixa + metade aleatória) e teto em max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half)
+}
+
+// Retryable indica se o status HTTP do destino justifica nova tentativa: 5xx e os 4xx
+// transitórios (408 timeout, 425 too early, 429 rate limit); os demais 4xx são definitivos
+func Retryable(statusCode int) bool {
+	switch {
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusTooEarly, statusCode == http.StatusTooManyRequests:
+		return true
+	case statusCode >= 500:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,71 @@
+package delivery
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffBounds(t *testing.T) {
+	tests := []struct {
+		attempt   int
+		base, max time.Duration
+		// ceil é a espera sem jitter; o resultado fica em [ceil/2, ceil)
+		ceil time.Duration
+	}{
+		{0, 2 * time.Second, time.Minute, 2 * time.Second},
+		{1, 2 * time.Second, time.Minute, 2 * time.Second},
+		{2, 2 * time.Second, time.Minute, 4 * time.Second},
+		{4, 2 * time.Second, time.Minute, 16 * time.Second},
+		{5, 2 * time.Second, time.Minute, 32 * time.Second},
+		{6, 2 * time.Second, time.Minute, time.Minute},
+		{50, 2 * time.Second, 10 * time.Minute, 10 * time.Minute},
+		{3, time.Minute, 30 * time.Second, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 200; i++ {
+			got := Backoff(tt.attempt, tt.base, tt.max)
+			if got < tt.ceil/2 || got >= tt.ceil {
+				t.Fatalf("Backoff(%d, %s, %s) = %s, want [%s, %s)", tt.attempt, tt.base, tt.max, got, tt.ceil/2, tt.ceil)
+			}
+		}
+	}
+	// Base mínima não divide por zero no jitter
+	if got := Backoff(1, time.Nanosecond, time.Second); got != time.Nanosecond {
+		t.Fatalf("Backoff com base 1ns = %s", got)
+	}
+}
+
+func TestBackoffJitterSpreads(t *testing.T) {
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 50; i++ {
+		seen[Backoff(3, time.Second, time.Minute)] = true
+	}
+	if len(seen) < 10 {
+		t.Fatalf("jitter gerou só %d valores distintos em 50 amostras", len(seen))
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusNotFound, false},
+		{http.StatusConflict, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooEarly, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		if got := Retryable(tt.status); got != tt.want {
+			t.Errorf("Retryable(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,632 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/breaker"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/ingest"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/logging"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/metrics"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/notify"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/tracing"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/pkg/webhooksig"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxResponseBody limita o quanto da resposta do destino é lido/repassado
+const maxResponseBody = 1 << 20 // 1 MiB
+
+// maxLoggedBody limita a resposta do destino gravada no log de entregas
+const maxLoggedBody = 2 << 10 // 2 KiB
+
+type Options struct {
+	Workers      int
+	MaxAttempts  int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	PollInterval time.Duration
+	// Lease é o tempo que uma entrega fica reservada para um worker/handler antes de poder ser retomada
+	Lease time.Duration
+	// Breaker opcional por destino (circuitKey); aberto, as entregas esperam na fila sem tentativa
+	Breaker *breaker.Breaker
+	// Log opcional de tentativas (consulta pelo admin); registros mais velhos que LogRetention são removidos
+	Log          repository.DeliveryLogRepository
+	LogRetention time.Duration
+	// Retention é por quanto tempo entregas delivered/dead ficam na outbox (o corpo vai junto)
+	Retention time.Duration
+	// Metrics opcional: status/latência de cada tentativa
+	Metrics *metrics.Metrics
+	// Ingest opcional: eventos forwarded/failed para o painel admin
+	Ingest *ingest.Shipper
+}
+
+// ErrCircuitOpen indica que a entrega foi para a fila sem tentativa porque o circuito do destino está aberto
+var ErrCircuitOpen = errors.New("circuito aberto para o destino")
+
+// ErrSigningSecretUnavailable indica entrega de cliente com assinatura HMAC sem segredo disponível
+// na tentativa (cliente removido/inativo, segredo apagado ou falha no lookup); ela não sai sem assinatura
+var ErrSigningSecretUnavailable = errors.New("segredo de assinatura indisponível; entrega não enviada sem assinatura")
+
+// Result descreve uma tentativa de entrega e o estado resultante
+type Result struct {
+	Attempt     int
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	Latency     time.Duration
+	Err         error
+	Status      models.DeliveryStatus
+}
+
+// SecretSource fornece os segredos HMAC vigentes do cliente para assinar entregas
+type SecretSource interface {
+	SigningSecrets(ctx context.Context, secretID string) []string
+}
+
+// Dispatcher persiste eventos na outbox e os entrega com retries/backoff via pool de workers
+type Dispatcher struct {
+	repo       repository.DeliveryRepository
+	httpClient *http.Client
+	secrets    SecretSource
+	notifier   *notify.Notifier
+	opts       Options
+	logger     *slog.Logger
+
+	wake      chan struct{}
+	stopChan  chan struct{}
+	drainChan chan struct{}
+	stopOnce  sync.Once
+	drainOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewDispatcher(repo repository.DeliveryRepository, httpClient *http.Client, secrets SecretSource, notifier *notify.Notifier, opts Options, logger *slog.Logger) *Dispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BackoffBase <= 0 {
+		opts.BackoffBase = 2 * time.Second
+	}
+	if opts.BackoffMax <= 0 {
+		opts.BackoffMax = 10 * time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 2 * time.Minute
+	}
+	if opts.LogRetention <= 0 {
+		opts.LogRetention = 14 * 24 * time.Hour
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 7 * 24 * time.Hour
+	}
+	d := &Dispatcher{
+		repo:       repo,
+		httpClient: httpClient,
+		secrets:    secrets,
+		notifier:   notifier,
+		opts:       opts,
+		logger:     logger,
+		wake:       make(chan struct{}, opts.Workers),
+		stopChan:   make(chan struct{}),
+		drainChan:  make(chan struct{}),
+	}
+	if opts.Breaker != nil && opts.Breaker.OnStateChange == nil {
+		opts.Breaker.OnStateChange = d.breakerChanged
+	}
+	return d
+}
+
+// breakerChanged loga toda transição, mas só avisa no Slack quando o circuito abre a partir
+// de closed e quando volta a fechar: reaberturas após probe (half_open -> open) repetiriam o
+// alerta a cada cooldown durante uma queda longa
+func (d *Dispatcher) breakerChanged(key string, from, to breaker.State) {
+	d.logger.Warn("circuit_state", "circuit", key, "from", from, "to", to)
+	switch {
+	case from == breaker.Closed && to == breaker.Open:
+		d.notifier.Notify(fmt.Sprintf(":rotating_light: Circuito aberto para %s; eventos seguem para a fila", redactCircuitKey(key)))
+	case to == breaker.Closed:
+		d.notifier.Notify(fmt.Sprintf(":white_check_mark: Circuito fechado para %s; entregas normalizadas", redactCircuitKey(key)))
+	}
+}
+
+// circuitKey identifica o circuito sem a URL completa: destinos cadastrados pelo ID e a
+// WebhookURL do cliente (ou rota de regra) por host+path, sem query, credenciais ou fragmento
+func circuitKey(del *models.Delivery) string {
+	if del.DestinationID != "" {
+		return "destination:" + del.DestinationID
+	}
+	u, err := url.Parse(del.TargetURL)
+	if err != nil || u.Host == "" {
+		return "invalid-url"
+	}
+	return u.Host + u.Path
+}
+
+// redactCircuitKey reduz a chave ao host para alertas externos: o path pode carregar tokens
+func redactCircuitKey(key string) string {
+	if strings.HasPrefix(key, "destination:") {
+		return key
+	}
+	host, path, _ := strings.Cut(key, "/")
+	if path == "" {
+		return host
+	}
+	return host + "/…"
+}
+
+// allow consulta o breaker do destino (sempre liberado sem breaker)
+func (d *Dispatcher) allow(del *models.Delivery) (bool, time.Duration) {
+	if d.opts.Breaker == nil {
+		return true, 0
+	}
+	return d.opts.Breaker.Allow(circuitKey(del))
+}
+
+// record alimenta o breaker e as métricas com o resultado da tentativa
+func (d *Dispatcher) record(del *models.Delivery, res *Result) {
+	d.opts.Metrics.Delivery(del.ClientID, res.StatusCode, res.Err, res.Latency)
+	// Sem requisição ao destino (segredo indisponível) o breaker não tem o que registrar
+	if d.opts.Breaker == nil || errors.Is(res.Err, ErrSigningSecretUnavailable) {
+		return
+	}
+	if res.Err != nil || Retryable(res.StatusCode) {
+		d.opts.Breaker.Failure(circuitKey(del))
+	} else {
+		d.opts.Breaker.Success(circuitKey(del))
+	}
+}
+
+// Start sobe o pool de workers que consome a outbox
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.opts.Workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	d.wg.Add(1)
+	go d.janitor()
+}
+
+// pruneBatch limita cada DELETE da outbox para não segurar locks longos
+const pruneBatch = 1000
+
+// circuitIdle é o tempo sem tráfego após o qual um circuito fechado sai do breaker
+const circuitIdle = 24 * time.Hour
+
+// janitor remove, de hora em hora, as entregas finalizadas, o log de entregas fora da retenção
+// e os circuitos fechados sem tráfego
+func (d *Dispatcher) janitor() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.pruneOutbox()
+			d.pruneLog()
+			d.pruneCircuits()
+		case <-d.stopChan:
+			return
+		case <-d.drainChan:
+			return
+		}
+	}
+}
+
+// pruneOutbox apaga em lotes as entregas delivered/dead mais velhas que Retention
+func (d *Dispatcher) pruneOutbox() {
+	var total int64
+	for {
+		select {
+		case <-d.stopChan:
+			return
+		case <-d.drainChan:
+			return
+		default:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		n, err := d.repo.PruneFinished(ctx, d.opts.Retention, pruneBatch)
+		cancel()
+		if err != nil {
+			d.logger.Error("Erro ao limpar outbox", "err", err)
+			return
+		}
+		total += n
+		if n < pruneBatch {
+			break
+		}
+	}
+	if total > 0 {
+		d.logger.Info("outbox_pruned", "rows", total)
+	}
+}
+
+func (d *Dispatcher) pruneLog() {
+	if d.opts.Log == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	n, err := d.opts.Log.Prune(ctx, d.opts.LogRetention)
+	cancel()
+	if err != nil {
+		d.logger.Error("Erro ao limpar delivery_log", "err", err)
+	} else if n > 0 {
+		d.logger.Info("delivery_log_pruned", "rows", n)
+	}
+}
+
+func (d *Dispatcher) pruneCircuits() {
+	if d.opts.Breaker == nil {
+		return
+	}
+	if n := d.opts.Breaker.Prune(circuitIdle); n > 0 {
+		d.logger.Info("circuits_pruned", "count", n)
+	}
+}
+
+// Stop sinaliza os workers e aguarda as entregas em andamento terminarem
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() { close(d.stopChan) })
+	d.wg.Wait()
+}
+
+// Shutdown esvazia a fila antes de parar: os workers seguem entregando o que já venceu e saem quando
+// não há mais nada a reservar. Se o ctx expirar antes, param após a tentativa em andamento; o que
+// sobrar (incluindo retries agendados para depois) fica na outbox para a próxima instância.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.drainOnce.Do(func() { close(d.drainChan) })
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.stopOnce.Do(func() { close(d.stopChan) })
+		return ctx.Err()
+	}
+}
+
+// Wake acorda um worker ocioso sem esperar o próximo poll (rajadas acordam até Workers workers)
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Enqueue grava a entrega como pendente para os workers
+func (d *Dispatcher) Enqueue(ctx context.Context, del *models.Delivery) error {
+	d.applyDefaults(ctx, del)
+	del.Status = models.DeliveryPending
+	if err := d.repo.Enqueue(ctx, del); err != nil {
+		return err
+	}
+	d.Wake()
+	return nil
+}
+
+// DeliverNow grava a entrega já reservada (lease) e faz a primeira tentativa inline.
+// Se a outbox estiver indisponível, faz uma única tentativa sem persistência para não perder o evento.
+func (d *Dispatcher) DeliverNow(ctx context.Context, del *models.Delivery) *Result {
+	d.applyDefaults(ctx, del)
+	if ok, wait := d.allow(del); !ok {
+		// Circuito aberto: sem tentativa inline, a entrega aguarda a próxima janela de probe na fila
+		del.Status = models.DeliveryPending
+		del.NextAttemptAt = time.Now().Add(wait)
+		res := &Result{Err: ErrCircuitOpen, Status: models.DeliveryPending}
+		if err := d.repo.Enqueue(ctx, del); err != nil {
+			d.logger.Error("Erro ao gravar outbox com circuito aberto", "secret_id", del.SecretID, "err", err)
+			res.Status = models.DeliveryFailed
+		}
+		return res
+	}
+	del.Status = models.DeliveryInFlight
+	del.NextAttemptAt = time.Now().Add(d.opts.Lease)
+	if err := d.repo.Enqueue(ctx, del); err != nil {
+		d.logger.Error("Erro ao gravar outbox (entrega sem persistência)", "secret_id", del.SecretID, "err", err)
+		res := d.send(ctx, del)
+		d.record(del, res)
+		res.Attempt = 1
+		if res.Err == nil && res.StatusCode < 400 {
+			res.Status = models.DeliveryDelivered
+		} else {
+			res.Status = models.DeliveryFailed
+		}
+		errMsg := attemptError(res)
+		d.logAttempt(del, res, errMsg)
+		d.ship(del, res, errMsg)
+		return res
+	}
+	return d.attempt(ctx, del)
+}
+
+// Replay reenfileira um dead letter para o destino informado (URL atual do cliente/destino)
+func (d *Dispatcher) Replay(ctx context.Context, dl *models.DeadLetter, targetURL string) (*models.Delivery, error) {
+	del := &models.Delivery{
+		ClientID:      dl.ClientID,
+		SecretID:      dl.SecretID,
+		DestinationID: dl.DestinationID,
+		TargetURL:     targetURL,
+		ContentType:   dl.ContentType,
+		Body:          dl.Body,
+	}
+	if err := d.Enqueue(ctx, del); err != nil {
+		return nil, err
+	}
+	return del, nil
+}
+
+func (d *Dispatcher) applyDefaults(ctx context.Context, del *models.Delivery) {
+	if del.TraceParent == "" {
+		del.TraceParent = tracing.TraceParent(ctx)
+	}
+	if del.RequestID == "" {
+		del.RequestID = logging.RequestID(ctx)
+	}
+	if !del.Signed && d.secrets != nil {
+		del.Signed = len(d.secrets.SigningSecrets(ctx, del.SecretID)) > 0
+	}
+	if del.MaxAttempts <= 0 {
+		del.MaxAttempts = d.opts.MaxAttempts
+	}
+	if del.ContentType == "" {
+		del.ContentType = "application/x-www-form-urlencoded"
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.drain()
+		select {
+		case <-d.stopChan:
+			return
+		case <-d.drainChan:
+			// Shutdown: última passada pelas vencidas antes de sair
+			d.drain()
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain processa entregas vencidas até a fila esvaziar ou o dispatcher parar
+func (d *Dispatcher) drain() {
+	for {
+		select {
+		case <-d.stopChan:
+			return
+		default:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		items, err := d.repo.ClaimDue(ctx, 1, d.opts.Lease)
+		cancel()
+		if err != nil {
+			d.logger.Error("Erro ao reservar entregas", "err", err)
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		for i := range items {
+			if ok, wait := d.allow(&items[i]); !ok {
+				d.postpone(&items[i], wait)
+				continue
+			}
+			// Retries continuam o trace da requisição que originou a entrega
+			d.attempt(tracing.WithTraceParent(context.Background(), items[i].TraceParent), &items[i])
+		}
+	}
+}
+
+// postpone devolve a entrega à fila sem consumir tentativa (circuito aberto)
+func (d *Dispatcher) postpone(del *models.Delivery, wait time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.repo.MarkRetry(ctx, del.ID, del.Attempts, time.Now().Add(wait), ErrCircuitOpen.Error(), del.LastStatus); err != nil {
+		d.logger.Error("Erro ao adiar entrega com circuito aberto", "delivery_id", del.ID, "err", err)
+	}
+}
+
+// attempt executa uma tentativa, registra o resultado e agenda retry/desistência
+func (d *Dispatcher) attempt(ctx context.Context, del *models.Delivery) *Result {
+	res := d.send(ctx, del)
+	d.record(del, res)
+	res.Attempt = del.Attempts + 1
+	del.Attempts = res.Attempt
+
+	errMsg := attemptError(res)
+
+	// Bookkeeping independe do contexto da requisição original
+	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.repo.RecordAttempt(dbCtx, &models.DeliveryAttempt{
+		DeliveryID: del.ID,
+		Attempt:    res.Attempt,
+		StatusCode: res.StatusCode,
+		Error:      errMsg,
+		LatencyMs:  res.Latency.Milliseconds(),
+	}); err != nil {
+		d.logger.Error("Erro ao registrar tentativa", "delivery_id", del.ID, "err", err)
+	}
+
+	var err error
+	switch {
+	case errMsg == "":
+		res.Status = models.DeliveryDelivered
+		err = d.repo.MarkDelivered(dbCtx, del.ID, res.Attempt, res.StatusCode)
+		d.logger.Info("delivery_ok", "delivery_id", del.ID, "request_id", del.RequestID, "secret_id", del.SecretID, "attempt", res.Attempt, "status", res.StatusCode, "latency_ms", res.Latency.Milliseconds())
+
+	case (res.Err != nil || Retryable(res.StatusCode)) && res.Attempt < del.MaxAttempts:
+		res.Status = models.DeliveryPending
+		next := time.Now().Add(Backoff(res.Attempt, d.opts.BackoffBase, d.opts.BackoffMax))
+		err = d.repo.MarkRetry(dbCtx, del.ID, res.Attempt, next, errMsg, res.StatusCode)
+		d.logger.Warn("delivery_retry", "delivery_id", del.ID, "request_id", del.RequestID, "secret_id", del.SecretID, "attempt", res.Attempt, "status", res.StatusCode, "err", errMsg, "next_attempt_at", next.Format(time.RFC3339))
+
+	default:
+		res.Status = models.DeliveryDead
+		var dlID string
+		dlID, err = d.repo.MoveToDeadLetter(dbCtx, del.ID, res.Attempt, errMsg, res.StatusCode)
+		d.logger.Error("Entrega movida para dead-letter", "delivery_id", del.ID, "request_id", del.RequestID, "dead_letter_id", dlID, "secret_id", del.SecretID, "attempts", res.Attempt, "err", errMsg)
+		d.notifier.Notify(fmt.Sprintf(":warning: Entrega desistida após %d tentativa(s) para %s | secretId=%s | deadLetterId=%s | requestId=%s | err=%s", res.Attempt, del.TargetURL, del.SecretID, dlID, del.RequestID, errMsg))
+	}
+	if err != nil {
+		d.logger.Error("Erro ao atualizar outbox", "delivery_id", del.ID, "err", err)
+	}
+	d.logAttempt(del, res, errMsg)
+	d.ship(del, res, errMsg)
+	return res
+}
+
+// ship envia a tentativa ao ingest do painel admin
+func (d *Dispatcher) ship(del *models.Delivery, res *Result, errMsg string) {
+	typ := ingest.EventForwarded
+	if errMsg != "" {
+		typ = ingest.EventFailed
+	}
+	d.opts.Ingest.Ship(ingest.Event{
+		Type:          typ,
+		ClientID:      del.ClientID,
+		SecretID:      del.SecretID,
+		EventID:       del.EventID,
+		Chat:          del.Chat,
+		DeliveryID:    del.ID,
+		DestinationID: del.DestinationID,
+		Attempt:       res.Attempt,
+		StatusCode:    res.StatusCode,
+		LatencyMs:     res.Latency.Milliseconds(),
+		Error:         errMsg,
+	})
+}
+
+// attemptError descreve a falha da tentativa (vazio em caso de sucesso)
+func attemptError(res *Result) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	if res.StatusCode >= 400 {
+		return fmt.Sprintf("destino retornou status %d", res.StatusCode)
+	}
+	return ""
+}
+
+// logAttempt grava a tentativa no log de entregas com a resposta truncada
+func (d *Dispatcher) logAttempt(del *models.Delivery, res *Result, errMsg string) {
+	if d.opts.Log == nil {
+		return
+	}
+	body := res.Body
+	if len(body) > maxLoggedBody {
+		body = body[:maxLoggedBody]
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.opts.Log.Record(ctx, &models.DeliveryLog{
+		DeliveryID:    del.ID,
+		ClientID:      del.ClientID,
+		SecretID:      del.SecretID,
+		DestinationID: del.DestinationID,
+		TargetURL:     del.TargetURL,
+		EventID:       del.EventID,
+		Chat:          del.Chat,
+		Attempt:       res.Attempt,
+		Status:        res.Status,
+		StatusCode:    res.StatusCode,
+		Error:         errMsg,
+		LatencyMs:     res.Latency.Milliseconds(),
+		// TEXT no Postgres não aceita UTF-8 inválido nem NUL (respostas binárias)
+		ResponseBody: strings.ReplaceAll(strings.ToValidUTF8(string(body), ""), "\x00", ""),
+	}); err != nil {
+		d.logger.Error("Erro ao gravar delivery_log", "delivery_id", del.ID, "err", err)
+	}
+}
+
+// send faz o POST ao destino preservando Content-Type
+func (d *Dispatcher) send(ctx context.Context, del *models.Delivery) (res *Result) {
+	res = &Result{}
+	ctx, span := tracing.Start(ctx, "delivery.forward", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("delivery.id", del.ID),
+		attribute.String("delivery.destination_id", del.DestinationID),
+		attribute.Int("delivery.attempt", del.Attempts+1),
+		semconv.HTTPRequestMethodKey.String(http.MethodPost),
+	))
+	defer func() {
+		if res.StatusCode > 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+		}
+		var err error
+		if msg := attemptError(res); msg != "" {
+			err = errors.New(msg)
+		}
+		tracing.End(span, err)
+	}()
+	// Cancelamento do provider não deve abortar a entrega em andamento
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodPost, del.TargetURL, bytes.NewReader(del.Body))
+	if err != nil {
+		res.Err = fmt.Errorf("erro criar req: %w", err)
+		return res
+	}
+	// Só o host vai para o span: a URL do destino pode carregar tokens
+	span.SetAttributes(semconv.ServerAddress(req.URL.Hostname()))
+	req.Header.Set("Content-Type", del.ContentType)
+	req.Header.Set(webhooksig.HeaderID, del.ID)
+	if del.RequestID != "" {
+		req.Header.Set(logging.HeaderRequestID, del.RequestID)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	// Assinatura HMAC por tentativa (timestamp sempre atual)
+	var secrets []string
+	if d.secrets != nil {
+		secrets = d.secrets.SigningSecrets(ctx, del.SecretID)
+	}
+	if len(secrets) > 0 {
+		ts := time.Now().Unix()
+		req.Header.Set(webhooksig.HeaderTimestamp, strconv.FormatInt(ts, 10))
+		req.Header.Set(webhooksig.HeaderSignature, webhooksig.SignHeader(secrets, ts, del.Body))
+	} else if del.Signed {
+		// Retry segue com backoff (o lookup pode voltar) e vai para a DLQ ao esgotar as tentativas
+		res.Err = ErrSigningSecretUnavailable
+		return res
+	}
+
+	start := time.Now()
+	resp, err := d.httpClient.Do(req)
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer resp.Body.Close()
+
+	res.StatusCode = resp.StatusCode
+	res.ContentType = resp.Header.Get("Content-Type")
+	res.Body, _ = io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return res
+}
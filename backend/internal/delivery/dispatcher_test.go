@@ -0,0 +1,307 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/breaker"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/pkg/webhooksig"
+)
+
+func TestCircuitKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		del      models.Delivery
+		key      string
+		redacted string
+	}{
+		{"destino cadastrado", models.Delivery{DestinationID: "d1", TargetURL: "https://h/x?token=1"}, "destination:d1", "destination:d1"},
+		{"query e credenciais fora", models.Delivery{TargetURL: "https://user:pw@h.example/hooks/abc?token=s3cr3t#f"}, "h.example/hooks/abc", "h.example/…"},
+		{"sem path", models.Delivery{TargetURL: "http://h.example:8080?k=v"}, "h.example:8080", "h.example:8080"},
+		{"URL inválida", models.Delivery{TargetURL: "::"}, "invalid-url", "invalid-url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := circuitKey(&tt.del)
+			if key != tt.key {
+				t.Fatalf("circuitKey = %q, want %q", key, tt.key)
+			}
+			if got := redactCircuitKey(key); got != tt.redacted {
+				t.Fatalf("redactCircuitKey = %q, want %q", got, tt.redacted)
+			}
+		})
+	}
+}
+
+func TestSignedDeliveryWithoutSecret(t *testing.T) {
+	var hits atomic.Int32
+	var gotSignature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		gotSignature = r.Header.Get(webhooksig.HeaderSignature)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	repo := newMemRepo()
+	secrets := &staticSecrets{}
+	secrets.set("whsec_1")
+	circuits := breaker.New(breaker.Options{FailureThreshold: 1})
+	d := NewDispatcher(repo, srv.Client(), secrets, nil, Options{MaxAttempts: 2, Breaker: circuits}, discardLogger())
+
+	// Enfileirada com o cliente assinando; o segredo some antes da tentativa
+	del := &models.Delivery{SecretID: "s1", TargetURL: srv.URL, ContentType: "application/json", Body: []byte(`{}`)}
+	if err := d.Enqueue(context.Background(), del); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.get(del.ID).Signed {
+		t.Fatal("entrega de cliente com segredo não marcada como assinada")
+	}
+	secrets.set()
+
+	claimed := claimOne(t, repo)
+	res := d.attempt(context.Background(), &claimed)
+	if !errors.Is(res.Err, ErrSigningSecretUnavailable) || res.Status != models.DeliveryPending {
+		t.Fatalf("1ª tentativa: err=%v status=%s, want retry por segredo indisponível", res.Err, res.Status)
+	}
+	// Esgotadas as tentativas, vai para a DLQ com o motivo
+	repo.update(del.ID, func(d *models.Delivery) { d.NextAttemptAt = time.Now() })
+	claimed = claimOne(t, repo)
+	res = d.attempt(context.Background(), &claimed)
+	if res.Status != models.DeliveryDead || repo.deadErrs[del.ID] != ErrSigningSecretUnavailable.Error() {
+		t.Fatalf("2ª tentativa: status=%s dlq=%q", res.Status, repo.deadErrs[del.ID])
+	}
+	if hits.Load() != 0 {
+		t.Fatalf("destino recebeu %d requisições sem assinatura", hits.Load())
+	}
+	// Falha local não conta contra o destino
+	if s := circuits.State(circuitKey(del)); s != breaker.Closed {
+		t.Fatalf("circuito = %s, want closed", s)
+	}
+
+	// Cliente que nunca assinou segue entregando sem cabeçalho de assinatura
+	plain := &models.Delivery{SecretID: "s2", TargetURL: srv.URL, ContentType: "application/json", Body: []byte(`{}`)}
+	if res := d.DeliverNow(context.Background(), plain); res.Status != models.DeliveryDelivered {
+		t.Fatalf("entrega sem assinatura: status=%s err=%v", res.Status, res.Err)
+	}
+	if hits.Load() != 1 || gotSignature != "" {
+		t.Fatalf("hits=%d assinatura=%q", hits.Load(), gotSignature)
+	}
+}
+
+func claimOne(t *testing.T, repo *memRepo) models.Delivery {
+	t.Helper()
+	items, _ := repo.ClaimDue(context.Background(), 1, time.Minute)
+	if len(items) != 1 {
+		t.Fatalf("ClaimDue = %d entregas, want 1", len(items))
+	}
+	return items[0]
+}
+
+func TestAttemptClassification(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		maxAttempts int
+		want        models.DeliveryStatus
+	}{
+		{"2xx entregue", http.StatusOK, 3, models.DeliveryDelivered},
+		{"3xx entregue", http.StatusNotModified, 3, models.DeliveryDelivered},
+		{"5xx com tentativas restantes", http.StatusBadGateway, 3, models.DeliveryPending},
+		{"5xx na última tentativa", http.StatusBadGateway, 1, models.DeliveryDead},
+		{"408 com tentativas restantes", http.StatusRequestTimeout, 3, models.DeliveryPending},
+		{"425 com tentativas restantes", http.StatusTooEarly, 3, models.DeliveryPending},
+		{"429 com tentativas restantes", http.StatusTooManyRequests, 3, models.DeliveryPending},
+		{"429 na última tentativa", http.StatusTooManyRequests, 1, models.DeliveryDead},
+		{"4xx definitivo", http.StatusNotFound, 3, models.DeliveryDead},
+		{"4xx definitivo (422)", http.StatusUnprocessableEntity, 3, models.DeliveryDead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			repo := newMemRepo()
+			d := NewDispatcher(repo, srv.Client(), nil, nil, Options{MaxAttempts: tt.maxAttempts}, discardLogger())
+
+			del := &models.Delivery{SecretID: "s1", TargetURL: srv.URL, Body: []byte(`{}`)}
+			res := d.DeliverNow(context.Background(), del)
+			if res.Status != tt.want || res.StatusCode != tt.status {
+				t.Fatalf("status = %s (HTTP %d), want %s", res.Status, res.StatusCode, tt.want)
+			}
+			if got := repo.get(del.ID).Status; got != tt.want {
+				t.Fatalf("outbox = %s, want %s", got, tt.want)
+			}
+			if tt.want == models.DeliveryPending && !repo.get(del.ID).NextAttemptAt.After(time.Now()) {
+				t.Fatal("retry sem backoff")
+			}
+		})
+	}
+
+	t.Run("erro de rede com tentativas restantes", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		srv.Close()
+		repo := newMemRepo()
+		d := NewDispatcher(repo, srv.Client(), nil, nil, Options{MaxAttempts: 2}, discardLogger())
+		del := &models.Delivery{SecretID: "s1", TargetURL: srv.URL}
+		if res := d.DeliverNow(context.Background(), del); res.Status != models.DeliveryPending || res.Err == nil {
+			t.Fatalf("status = %s err = %v, want retry", res.Status, res.Err)
+		}
+	})
+}
+
+// TestWorkersDeliverQueue cobre o caminho assíncrono: Enqueue acorda um worker, que entrega via HTTP
+func TestWorkersDeliverQueue(t *testing.T) {
+	var hits atomic.Int32
+	var gotID, gotSignature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			// Primeira tentativa falha: o retry sai do backoff pelo poll
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		gotID = r.Header.Get(webhooksig.HeaderID)
+		gotSignature = r.Header.Get(webhooksig.HeaderSignature)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	repo := newMemRepo()
+	secrets := &staticSecrets{}
+	secrets.set("whsec_1")
+	d := NewDispatcher(repo, srv.Client(), secrets, nil, Options{
+		Workers:      2,
+		MaxAttempts:  3,
+		BackoffBase:  10 * time.Millisecond,
+		BackoffMax:   10 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	}, discardLogger())
+	d.Start()
+	defer d.Stop()
+
+	del := &models.Delivery{SecretID: "s1", TargetURL: srv.URL, ContentType: "application/json", Body: []byte(`{"a":1}`)}
+	if err := d.Enqueue(context.Background(), del); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for repo.get(del.ID).Status != models.DeliveryDelivered {
+		if time.Now().After(deadline) {
+			t.Fatalf("entrega não concluída: %+v", repo.get(del.ID))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := repo.get(del.ID).Attempts; got != 2 || hits.Load() != 2 {
+		t.Fatalf("attempts = %d, hits = %d, want 2", got, hits.Load())
+	}
+	if gotID != del.ID || gotSignature == "" {
+		t.Fatalf("cabeçalhos: id=%q assinatura=%q", gotID, gotSignature)
+	}
+}
+
+func TestShutdownDrainsDue(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	repo := newMemRepo()
+	// Poll longo: só o drain do Shutdown (e a passada inicial) consome a fila
+	d := NewDispatcher(repo, srv.Client(), nil, nil, Options{Workers: 2, PollInterval: time.Hour}, discardLogger())
+	const n = 10
+	for i := 0; i < n; i++ {
+		if err := repo.Enqueue(context.Background(), &models.Delivery{SecretID: "s1", TargetURL: srv.URL, Status: models.DeliveryPending, MaxAttempts: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Retry agendado para depois fica na outbox para a próxima instância
+	later := &models.Delivery{SecretID: "s1", TargetURL: srv.URL, Status: models.DeliveryPending, MaxAttempts: 3, NextAttemptAt: time.Now().Add(time.Hour)}
+	if err := repo.Enqueue(context.Background(), later); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if got := repo.statuses(); got[models.DeliveryDelivered] != n || got[models.DeliveryPending] != 1 {
+		t.Fatalf("status após o shutdown = %v", got)
+	}
+	if hits.Load() != n {
+		t.Fatalf("hits = %d, want %d", hits.Load(), n)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	repo := newMemRepo()
+	d := NewDispatcher(repo, srv.Client(), nil, nil, Options{Workers: 1, PollInterval: time.Hour}, discardLogger())
+	for i := 0; i < 3; i++ {
+		_ = repo.Enqueue(context.Background(), &models.Delivery{SecretID: "s1", TargetURL: srv.URL, Status: models.DeliveryPending, MaxAttempts: 3})
+	}
+	d.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want DeadlineExceeded", err)
+	}
+	// A tentativa em andamento termina; as demais ficam na outbox
+	close(release)
+	d.Stop()
+	if got := repo.statuses(); got[models.DeliveryDelivered] != 1 || got[models.DeliveryPending] != 2 {
+		t.Fatalf("status após o timeout = %v", got)
+	}
+}
+
+func TestPruneOutboxBatches(t *testing.T) {
+	tests := []struct {
+		rows  int64
+		calls int
+	}{
+		{0, 1},
+		{999, 1},
+		{1000, 2},
+		{2500, 3},
+	}
+	for _, tt := range tests {
+		repo := newMemRepo()
+		repo.pruneRows = tt.rows
+		d := NewDispatcher(repo, http.DefaultClient, nil, nil, Options{}, discardLogger())
+		d.pruneOutbox()
+		if len(repo.pruneCalls) != tt.calls || repo.pruneRows != 0 {
+			t.Fatalf("%d linhas: %d DELETEs (restaram %d), want %d", tt.rows, len(repo.pruneCalls), repo.pruneRows, tt.calls)
+		}
+		for _, b := range repo.pruneCalls {
+			if b != pruneBatch {
+				t.Fatalf("batch = %d, want %d", b, pruneBatch)
+			}
+		}
+	}
+
+	// Stop interrompe a limpeza entre lotes
+	repo := newMemRepo()
+	repo.pruneRows = 10 * pruneBatch
+	d := NewDispatcher(repo, http.DefaultClient, nil, nil, Options{}, discardLogger())
+	d.Stop()
+	d.pruneOutbox()
+	if len(repo.pruneCalls) != 0 {
+		t.Fatalf("limpeza após Stop: %d DELETEs", len(repo.pruneCalls))
+	}
+}
@@ -0,0 +1,164 @@
+package delivery
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+)
+
+// memRepo é uma outbox em memória com o ciclo pending -> delivering -> delivered/dead
+type memRepo struct {
+	repository.DeliveryRepository
+
+	mu       sync.Mutex
+	rows     map[string]*models.Delivery
+	attempts []models.DeliveryAttempt
+	deadErrs map[string]string
+	// pruneRows simula delivered/dead fora da retenção; pruneCalls registra o batch de cada DELETE
+	pruneRows  int64
+	pruneCalls []int
+}
+
+func newMemRepo() *memRepo {
+	return &memRepo{rows: map[string]*models.Delivery{}, deadErrs: map[string]string{}}
+}
+
+func (m *memRepo) Enqueue(_ context.Context, d *models.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d.ID == "" {
+		d.ID = uuid.NewString()
+	}
+	if d.NextAttemptAt.IsZero() {
+		d.NextAttemptAt = time.Now()
+	}
+	d.CreatedAt = time.Now()
+	cp := *d
+	m.rows[d.ID] = &cp
+	return nil
+}
+
+func (m *memRepo) ClaimDue(_ context.Context, limit int, lease time.Duration) ([]models.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var due []*models.Delivery
+	for _, d := range m.rows {
+		if (d.Status == models.DeliveryPending || d.Status == models.DeliveryInFlight) && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	out := make([]models.Delivery, 0, len(due))
+	for _, d := range due {
+		d.Status = models.DeliveryInFlight
+		d.NextAttemptAt = now.Add(lease)
+		out = append(out, *d)
+	}
+	return out, nil
+}
+
+func (m *memRepo) RecordAttempt(_ context.Context, a *models.DeliveryAttempt) error {
+	m.mu.Lock()
+	m.attempts = append(m.attempts, *a)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memRepo) MarkDelivered(_ context.Context, id string, attempts, statusCode int) error {
+	return m.update(id, func(d *models.Delivery) {
+		d.Status, d.Attempts, d.LastStatus = models.DeliveryDelivered, attempts, statusCode
+	})
+}
+
+func (m *memRepo) MarkRetry(_ context.Context, id string, attempts int, next time.Time, lastErr string, lastStatus int) error {
+	return m.update(id, func(d *models.Delivery) {
+		d.Status, d.Attempts, d.NextAttemptAt = models.DeliveryPending, attempts, next
+		d.LastError, d.LastStatus = lastErr, lastStatus
+	})
+}
+
+func (m *memRepo) MoveToDeadLetter(_ context.Context, id string, attempts int, lastErr string, lastStatus int) (string, error) {
+	err := m.update(id, func(d *models.Delivery) {
+		d.Status, d.Attempts, d.LastError, d.LastStatus = models.DeliveryDead, attempts, lastErr, lastStatus
+	})
+	m.mu.Lock()
+	m.deadErrs[id] = lastErr
+	m.mu.Unlock()
+	return "dl-" + id, err
+}
+
+func (m *memRepo) PruneFinished(_ context.Context, _ time.Duration, batch int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneCalls = append(m.pruneCalls, batch)
+	n := min(m.pruneRows, int64(batch))
+	m.pruneRows -= n
+	return n, nil
+}
+
+// statuses conta as entregas por status
+func (m *memRepo) statuses() map[models.DeliveryStatus]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[models.DeliveryStatus]int{}
+	for _, d := range m.rows {
+		out[d.Status]++
+	}
+	return out
+}
+
+func (m *memRepo) update(id string, fn func(*models.Delivery)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.rows[id]
+	if !ok {
+		return errFakeDB
+	}
+	fn(d)
+	return nil
+}
+
+// get retorna uma cópia da linha da outbox
+func (m *memRepo) get(id string) models.Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.rows[id]
+}
+
+// staticSecrets devolve os segredos atuais (trocáveis durante o teste)
+type staticSecrets struct {
+	mu      sync.Mutex
+	secrets []string
+}
+
+func (s *staticSecrets) SigningSecrets(context.Context, string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.secrets
+}
+
+func (s *staticSecrets) set(secrets ...string) {
+	s.mu.Lock()
+	s.secrets = secrets
+	s.mu.Unlock()
+}
+
+var errFakeDB = fakeError("outbox indisponível")
+
+type fakeError string
+
+func (e fakeError) Error() string { return string(e) }
+
+func discardLogger() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
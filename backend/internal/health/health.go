@@ -0,0 +1,231 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/breaker"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/ingest"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/migrations"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+)
+
+// Status de uma dependência: fail tira a instância do balanceamento, degraded só informa
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded"
+	StatusFail     Status = "fail"
+	// StatusDraining indica shutdown em andamento (readyz responde 503)
+	StatusDraining Status = "draining"
+)
+
+// checkTimeout limita cada verificação para o probe não travar com uma dependência lenta
+const checkTimeout = 2 * time.Second
+
+// Check é o resultado da verificação de uma dependência
+type Check struct {
+	Status    Status `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// Report agrega as verificações; Status é o pior entre elas
+type Report struct {
+	Status Status           `json:"status"`
+	Checks map[string]Check `json:"checks"`
+}
+
+// Checker verifica as dependências do serviço para /readyz e /admin/health
+type Checker struct {
+	Pool       *pgxpool.Pool
+	Deliveries repository.DeliveryRepository
+	Breaker    *breaker.Breaker
+	Ingest     *ingest.Shipper
+	HTTPClient *http.Client
+	// LIDAPIURL é a API Avisa usada na conversão LID->JID (vazio = não verificada)
+	LIDAPIURL string
+	// MaxBacklog marca a instância como não pronta acima desse número de entregas vencidas (0 desativa)
+	MaxBacklog int64
+
+	draining atomic.Bool
+}
+
+// SetDraining faz o readyz responder 503 a partir do início do shutdown
+func (h *Checker) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Ready verifica o necessário para receber tráfego: Postgres, migrations, fila e breakers
+func (h *Checker) Ready(ctx context.Context) Report {
+	return h.report(map[string]Check{
+		"postgres":   h.checkPostgres(ctx),
+		"migrations": h.checkMigrations(ctx),
+		"queue":      h.checkQueue(ctx),
+		"breakers":   h.checkBreakers(),
+	})
+}
+
+// Detailed inclui, além do Ready, dependências que não tiram a instância do balanceamento
+func (h *Checker) Detailed(ctx context.Context) Report {
+	rep := h.Ready(ctx)
+	// Falha da API LID degrada o serviço (eventos seguem sem conversão), mas não o torna indisponível
+	rep.addOptional("lid_api", h.checkLIDAPI(ctx))
+	rep.addOptional("ingest", h.checkIngest())
+	return rep
+}
+
+// report agrega as verificações no pior status; shutdown em andamento prevalece
+func (h *Checker) report(checks map[string]Check) Report {
+	rep := Report{Status: StatusOK, Checks: checks}
+	for _, c := range checks {
+		rep.Status = worst(rep.Status, c.Status)
+	}
+	if h.draining.Load() {
+		rep.Status = StatusDraining
+	}
+	return rep
+}
+
+// addOptional inclui uma verificação que no máximo degrada o relatório
+func (r *Report) addOptional(name string, c Check) {
+	r.Checks[name] = c
+	if c.Status == StatusFail {
+		r.Status = worst(r.Status, StatusDegraded)
+	}
+}
+
+// Healthy indica se o readyz deve responder 200
+func (r Report) Healthy() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+func (h *Checker) checkPostgres(ctx context.Context) Check {
+	if h.Pool == nil {
+		return Check{Status: StatusFail, Error: "pool não configurado"}
+	}
+	return timed(ctx, func(ctx context.Context) (Status, any, error) {
+		if err := h.Pool.Ping(ctx); err != nil {
+			return StatusFail, nil, err
+		}
+		stat := h.Pool.Stat()
+		return StatusOK, map[string]any{
+			"totalConns":    stat.TotalConns(),
+			"idleConns":     stat.IdleConns(),
+			"acquiredConns": stat.AcquiredConns(),
+			"maxConns":      stat.MaxConns(),
+		}, nil
+	})
+}
+
+func (h *Checker) checkMigrations(ctx context.Context) Check {
+	if h.Pool == nil {
+		return Check{Status: StatusFail, Error: "pool não configurado"}
+	}
+	return timed(ctx, func(ctx context.Context) (Status, any, error) {
+		applied, err := migrations.Applied(ctx, h.Pool)
+		if err != nil {
+			return StatusFail, nil, err
+		}
+		details := map[string]int{"applied": applied, "expected": migrations.Version()}
+		if applied < migrations.Version() {
+			return StatusFail, details, fmt.Errorf("schema desatualizado")
+		}
+		return StatusOK, details, nil
+	})
+}
+
+func (h *Checker) checkQueue(ctx context.Context) Check {
+	if h.Deliveries == nil {
+		return Check{Status: StatusOK}
+	}
+	return timed(ctx, func(ctx context.Context) (Status, any, error) {
+		due, oldest, err := h.Deliveries.Backlog(ctx)
+		if err != nil {
+			return StatusFail, nil, err
+		}
+		details := map[string]any{"due": due, "maxBacklog": h.MaxBacklog}
+		if oldest != nil {
+			details["oldestDueAgeSeconds"] = int64(time.Since(*oldest).Seconds())
+		}
+		if h.MaxBacklog > 0 && due > h.MaxBacklog {
+			return StatusFail, details, fmt.Errorf("backlog de %d entregas acima do limite %d", due, h.MaxBacklog)
+		}
+		return StatusOK, details, nil
+	})
+}
+
+// checkBreakers só degrada: circuito aberto é problema do destino, não da instância
+func (h *Checker) checkBreakers() Check {
+	if h.Breaker == nil {
+		return Check{Status: StatusOK}
+	}
+	counts := map[breaker.State]int{}
+	for _, s := range h.Breaker.Snapshot() {
+		counts[s.State]++
+	}
+	status := StatusOK
+	if counts[breaker.Open] > 0 {
+		status = StatusDegraded
+	}
+	return Check{Status: status, Details: map[string]int{
+		"closed":   counts[breaker.Closed],
+		"open":     counts[breaker.Open],
+		"halfOpen": counts[breaker.HalfOpen],
+	}}
+}
+
+// checkLIDAPI considera a API alcançável se houver qualquer resposta HTTP
+func (h *Checker) checkLIDAPI(ctx context.Context) Check {
+	if h.LIDAPIURL == "" || h.HTTPClient == nil {
+		return Check{Status: StatusOK, Details: map[string]bool{"configured": false}}
+	}
+	return timed(ctx, func(ctx context.Context) (Status, any, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.LIDAPIURL, nil)
+		if err != nil {
+			return StatusFail, nil, err
+		}
+		resp, err := h.HTTPClient.Do(req)
+		if err != nil {
+			return StatusFail, nil, err
+		}
+		resp.Body.Close()
+		return StatusOK, map[string]int{"statusCode": resp.StatusCode}, nil
+	})
+}
+
+func (h *Checker) checkIngest() Check {
+	if h.Ingest == nil {
+		return Check{Status: StatusOK, Details: map[string]bool{"configured": false}}
+	}
+	return Check{Status: StatusOK, Details: h.Ingest.Stats()}
+}
+
+// timed executa a verificação com timeout próprio e mede a latência
+func timed(ctx context.Context, fn func(ctx context.Context) (Status, any, error)) Check {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	status, details, err := fn(ctx)
+	c := Check{Status: status, LatencyMs: time.Since(start).Milliseconds(), Details: details}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+// worst retorna o status mais grave entre a e b
+func worst(a, b Status) Status {
+	rank := map[Status]int{StatusOK: 0, StatusDegraded: 1, StatusFail: 2, StatusDraining: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
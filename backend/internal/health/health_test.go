@@ -0,0 +1,208 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/breaker"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+)
+
+// backlogRepo implementa só o Backlog; os demais métodos não são usados pelo Checker
+type backlogRepo struct {
+	repository.DeliveryRepository
+	due    int64
+	oldest *time.Time
+	err    error
+}
+
+func (r backlogRepo) Backlog(context.Context) (int64, *time.Time, error) {
+	return r.due, r.oldest, r.err
+}
+
+func TestWorst(t *testing.T) {
+	tests := []struct {
+		a, b, want Status
+	}{
+		{StatusOK, StatusOK, StatusOK},
+		{StatusOK, StatusDegraded, StatusDegraded},
+		{StatusFail, StatusDegraded, StatusFail},
+		{StatusDegraded, StatusFail, StatusFail},
+		{StatusFail, StatusDraining, StatusDraining},
+		{StatusDraining, StatusOK, StatusDraining},
+	}
+	for _, tt := range tests {
+		if got := worst(tt.a, tt.b); got != tt.want {
+			t.Errorf("worst(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestReportAggregation(t *testing.T) {
+	tests := []struct {
+		name     string
+		checks   []Status
+		draining bool
+		want     Status
+		healthy  bool
+	}{
+		{"tudo ok", []Status{StatusOK, StatusOK}, false, StatusOK, true},
+		{"um degradado", []Status{StatusOK, StatusDegraded}, false, StatusDegraded, true},
+		{"falha prevalece", []Status{StatusDegraded, StatusFail, StatusOK}, false, StatusFail, false},
+		{"draining prevalece", []Status{StatusOK}, true, StatusDraining, false},
+		{"draining com falha", []Status{StatusFail}, true, StatusDraining, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Checker{}
+			if tt.draining {
+				h.SetDraining()
+			}
+			checks := map[string]Check{}
+			for i, s := range tt.checks {
+				checks[string(rune('a'+i))] = Check{Status: s}
+			}
+			rep := h.report(checks)
+			if rep.Status != tt.want {
+				t.Fatalf("status = %s, want %s", rep.Status, tt.want)
+			}
+			if rep.Healthy() != tt.healthy {
+				t.Fatalf("Healthy = %v, want %v", rep.Healthy(), tt.healthy)
+			}
+		})
+	}
+}
+
+func TestAddOptionalOnlyDegrades(t *testing.T) {
+	tests := []struct {
+		name      string
+		base, opt Status
+		want      Status
+	}{
+		{"opcional falha: degradado", StatusOK, StatusFail, StatusDegraded},
+		{"opcional ok", StatusOK, StatusOK, StatusOK},
+		{"falha obrigatória mantida", StatusFail, StatusFail, StatusFail},
+		{"draining mantido", StatusDraining, StatusFail, StatusDraining},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := Report{Status: tt.base, Checks: map[string]Check{}}
+			rep.addOptional("x", Check{Status: tt.opt})
+			if rep.Status != tt.want {
+				t.Fatalf("status = %s, want %s", rep.Status, tt.want)
+			}
+			if rep.Checks["x"].Status != tt.opt {
+				t.Fatal("verificação opcional ausente do relatório")
+			}
+		})
+	}
+}
+
+func TestReadyWithoutPool(t *testing.T) {
+	rep := (&Checker{}).Ready(context.Background())
+	if rep.Status != StatusFail || rep.Healthy() {
+		t.Fatalf("status = %s, want fail", rep.Status)
+	}
+	for _, name := range []string{"postgres", "migrations"} {
+		if rep.Checks[name].Status != StatusFail {
+			t.Errorf("%s = %s, want fail", name, rep.Checks[name].Status)
+		}
+	}
+	for _, name := range []string{"queue", "breakers"} {
+		if rep.Checks[name].Status != StatusOK {
+			t.Errorf("%s = %s, want ok (não configurado)", name, rep.Checks[name].Status)
+		}
+	}
+}
+
+func TestCheckQueue(t *testing.T) {
+	oldest := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name       string
+		repo       backlogRepo
+		maxBacklog int64
+		want       Status
+	}{
+		{"abaixo do limite", backlogRepo{due: 5, oldest: &oldest}, 10, StatusOK},
+		{"no limite", backlogRepo{due: 10, oldest: &oldest}, 10, StatusOK},
+		{"acima do limite", backlogRepo{due: 11, oldest: &oldest}, 10, StatusFail},
+		{"limite desativado", backlogRepo{due: 1e6}, 0, StatusOK},
+		{"erro do banco", backlogRepo{err: errors.New("conn refused")}, 10, StatusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := (&Checker{Deliveries: tt.repo, MaxBacklog: tt.maxBacklog}).checkQueue(context.Background())
+			if c.Status != tt.want {
+				t.Fatalf("status = %s, want %s (%s)", c.Status, tt.want, c.Error)
+			}
+			if tt.want == StatusFail && c.Error == "" {
+				t.Fatal("falha sem mensagem de erro")
+			}
+		})
+	}
+}
+
+func TestCheckBreakers(t *testing.T) {
+	b := breaker.New(breaker.Options{FailureThreshold: 1, OpenTimeout: time.Hour})
+	h := &Checker{Breaker: b}
+	b.Success("destination:a")
+	if c := h.checkBreakers(); c.Status != StatusOK {
+		t.Fatalf("status = %s, want ok", c.Status)
+	}
+	b.Failure("destination:b")
+	c := h.checkBreakers()
+	if c.Status != StatusDegraded {
+		t.Fatalf("status = %s, want degraded", c.Status)
+	}
+	if d := c.Details.(map[string]int); d["open"] != 1 || d["closed"] != 1 {
+		t.Fatalf("details = %v", d)
+	}
+}
+
+func TestCheckLIDAPI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		want Status
+	}{
+		{"qualquer resposta HTTP", srv.URL, StatusOK},
+		{"inalcançável", closed.URL, StatusFail},
+		{"não configurada", "", StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := (&Checker{HTTPClient: http.DefaultClient, LIDAPIURL: tt.url}).checkLIDAPI(context.Background())
+			if c.Status != tt.want {
+				t.Fatalf("status = %s, want %s (%s)", c.Status, tt.want, c.Error)
+			}
+		})
+	}
+}
+
+func TestDetailedLIDAPIFailureDegrades(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	b := breaker.New(breaker.Options{FailureThreshold: 1, OpenTimeout: time.Hour})
+	h := &Checker{Breaker: b, HTTPClient: http.DefaultClient, LIDAPIURL: closed.URL}
+
+	// Sem pool a instância já falha; a API LID não pode mascarar nem piorar isso
+	if rep := h.Detailed(context.Background()); rep.Status != StatusFail || rep.Checks["lid_api"].Status != StatusFail {
+		t.Fatalf("status = %s, lid_api = %s", rep.Status, rep.Checks["lid_api"].Status)
+	}
+	rep := Report{Status: StatusOK, Checks: map[string]Check{}}
+	rep.addOptional("lid_api", h.checkLIDAPI(context.Background()))
+	if rep.Status != StatusDegraded || !rep.Healthy() {
+		t.Fatalf("status = %s, want degraded", rep.Status)
+	}
+}
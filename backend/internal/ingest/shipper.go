@@ -0,0 +1,253 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tipos de evento do ciclo de vida de um webhook
+const (
+	EventReceived  = "received"
+	EventFiltered  = "filtered"
+	EventForwarded = "forwarded"
+	EventFailed    = "failed"
+	EventConverted = "converted"
+)
+
+// Event é um registro enviado ao painel admin
+type Event struct {
+	Type          string            `json:"type"`
+	Time          time.Time         `json:"time"`
+	ClientID      string            `json:"clientId,omitempty"`
+	SecretID      string            `json:"secretId,omitempty"`
+	EventID       string            `json:"eventId,omitempty"`
+	Chat          string            `json:"chat,omitempty"`
+	Reason        string            `json:"reason,omitempty"`
+	DeliveryID    string            `json:"deliveryId,omitempty"`
+	DestinationID string            `json:"destinationId,omitempty"`
+	Attempt       int               `json:"attempt,omitempty"`
+	StatusCode    int               `json:"statusCode,omitempty"`
+	LatencyMs     int64             `json:"latencyMs,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Conversions   map[string]string `json:"conversions,omitempty"`
+}
+
+type Options struct {
+	// Eventos por lote e intervalo máximo entre envios
+	BatchSize     int
+	FlushInterval time.Duration
+	// BufferSize limita a memória: com o buffer cheio o evento é descartado e contado
+	BufferSize int
+	// Tentativas por lote antes de descartá-lo
+	MaxRetries int
+}
+
+// Stats são os contadores do shipper desde o start
+type Stats struct {
+	Shipped       uint64 `json:"shipped"`
+	Dropped       uint64 `json:"dropped"`
+	FailedBatches uint64 `json:"failedBatches"`
+	Buffered      int    `json:"buffered"`
+}
+
+// Shipper agrupa eventos em lotes JSON e os envia ao ADMIN_INGEST_URL em background.
+// Um *Shipper nil é válido e ignora os eventos (ingest desativado).
+type Shipper struct {
+	url        string
+	token      string
+	httpClient *http.Client
+	opts       Options
+	logger     *slog.Logger
+
+	queue    chan Event
+	stopChan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	shipped       atomic.Uint64
+	dropped       atomic.Uint64
+	failedBatches atomic.Uint64
+}
+
+func NewShipper(url, token string, httpClient *http.Client, opts Options, logger *slog.Logger) *Shipper {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 2 * time.Second
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 10000
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 3
+	}
+	return &Shipper{
+		url:        url,
+		token:      token,
+		httpClient: httpClient,
+		opts:       opts,
+		logger:     logger,
+		queue:      make(chan Event, opts.BufferSize),
+		stopChan:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Ship enfileira o evento sem bloquear; buffer cheio descarta e incrementa o contador
+func (s *Shipper) Ship(e Event) {
+	if s == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	select {
+	case s.queue <- e:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Start sobe o loop de envio em lotes
+func (s *Shipper) Start() {
+	if s == nil {
+		return
+	}
+	go s.loop()
+}
+
+// Stop envia o que restou no buffer e encerra o loop, respeitando o prazo do ctx
+func (s *Shipper) Stop(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.stopOnce.Do(func() { close(s.stopChan) })
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats retorna os contadores atuais
+func (s *Shipper) Stats() Stats {
+	if s == nil {
+		return Stats{}
+	}
+	return Stats{
+		Shipped:       s.shipped.Load(),
+		Dropped:       s.dropped.Load(),
+		FailedBatches: s.failedBatches.Load(),
+		Buffered:      len(s.queue),
+	}
+}
+
+// Dropped retorna os eventos descartados (buffer cheio ou lote que esgotou as tentativas)
+func (s *Shipper) Dropped() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.dropped.Load()
+}
+
+func (s *Shipper) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]Event, 0, s.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.send(batch)
+		batch = make([]Event, 0, s.opts.BatchSize)
+	}
+	for {
+		select {
+		case e := <-s.queue:
+			batch = append(batch, e)
+			if len(batch) >= s.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.stopChan:
+			// Drena o buffer sem novas esperas de intervalo
+			for {
+				select {
+				case e := <-s.queue:
+					batch = append(batch, e)
+					if len(batch) >= s.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posta o lote com retry e backoff com jitter; esgotadas as tentativas, o lote é descartado
+func (s *Shipper) send(batch []Event) {
+	body, err := json.Marshal(map[string]any{"source": "ms_sdr", "events": batch})
+	if err != nil {
+		s.logger.Error("Erro ao serializar lote de ingest", "err", err)
+		s.dropped.Add(uint64(len(batch)))
+		return
+	}
+	var lastErr error
+	for attempt := 1; attempt <= s.opts.MaxRetries; attempt++ {
+		if lastErr = s.post(body); lastErr == nil {
+			s.shipped.Add(uint64(len(batch)))
+			return
+		}
+		if attempt < s.opts.MaxRetries {
+			wait := time.Duration(attempt*attempt) * 500 * time.Millisecond
+			wait += time.Duration(rand.Int63n(int64(wait/2) + 1))
+			// No shutdown as tentativas restantes seguem sem esperar o backoff
+			select {
+			case <-time.After(wait):
+			case <-s.stopChan:
+			}
+		}
+	}
+	s.failedBatches.Add(1)
+	s.dropped.Add(uint64(len(batch)))
+	s.logger.Error("Lote de ingest descartado", "attempts", s.opts.MaxRetries, "events", len(batch), "err", lastErr)
+}
+
+func (s *Shipper) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("x-admin-key", s.token)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("ingest retornou status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ingestServer registra os lotes recebidos; fail responde 500 nas primeiras N requisições
+type ingestServer struct {
+	mu      sync.Mutex
+	batches [][]Event
+	keys    []string
+	posts   int
+	fail    int
+}
+
+func (s *ingestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Source string  `json:"source"`
+		Events []Event `json:"events"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posts++
+	if s.fail < 0 || s.posts <= s.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.batches = append(s.batches, body.Events)
+	s.keys = append(s.keys, r.Header.Get("x-admin-key"))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *ingestServer) sizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]int, len(s.batches))
+	for i, b := range s.batches {
+		out[i] = len(b)
+	}
+	return out
+}
+
+func newTestShipper(t *testing.T, srv *ingestServer, opts Options) *Shipper {
+	t.Helper()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return NewShipper(ts.URL, "adm-token", ts.Client(), opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func stop(t *testing.T, s *Shipper) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
+
+func TestShipperBatchesBySize(t *testing.T) {
+	srv := &ingestServer{}
+	s := newTestShipper(t, srv, Options{BatchSize: 3, FlushInterval: time.Hour})
+	s.Start()
+	for i := 0; i < 7; i++ {
+		s.Ship(Event{Type: EventReceived, SecretID: "s1"})
+	}
+	// Dois lotes cheios saem sem esperar o intervalo; o resto sai no Stop
+	deadline := time.Now().Add(time.Second)
+	for len(srv.sizes()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := srv.sizes(); len(got) != 2 || got[0] != 3 || got[1] != 3 {
+		t.Fatalf("lotes antes do Stop = %v, want [3 3]", got)
+	}
+	stop(t, s)
+	if got := srv.sizes(); len(got) != 3 || got[2] != 1 {
+		t.Fatalf("lotes após o Stop = %v, want [3 3 1]", got)
+	}
+	st := s.Stats()
+	if st.Shipped != 7 || st.Dropped != 0 || st.FailedBatches != 0 || st.Buffered != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if srv.keys[0] != "adm-token" {
+		t.Fatalf("x-admin-key = %q", srv.keys[0])
+	}
+	if srv.batches[0][0].Time.IsZero() {
+		t.Fatal("evento sem horário")
+	}
+}
+
+func TestShipperFlushInterval(t *testing.T) {
+	srv := &ingestServer{}
+	s := newTestShipper(t, srv, Options{BatchSize: 100, FlushInterval: 20 * time.Millisecond})
+	s.Start()
+	defer stop(t, s)
+	s.Ship(Event{Type: EventForwarded})
+	s.Ship(Event{Type: EventFailed})
+
+	deadline := time.Now().Add(time.Second)
+	for len(srv.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := srv.sizes(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("lotes = %v, want [2]", got)
+	}
+}
+
+func TestShipperRetry(t *testing.T) {
+	// Falha na primeira tentativa; no shutdown o retry sai sem esperar o backoff
+	srv := &ingestServer{fail: 1}
+	s := newTestShipper(t, srv, Options{BatchSize: 10, FlushInterval: time.Hour, MaxRetries: 3})
+	s.Start()
+	s.Ship(Event{Type: EventReceived})
+	s.Ship(Event{Type: EventReceived})
+	stop(t, s)
+
+	if got := srv.sizes(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("lotes = %v, want [2]", got)
+	}
+	if st := s.Stats(); st.Shipped != 2 || st.Dropped != 0 || st.FailedBatches != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestShipperDropsAfterRetries(t *testing.T) {
+	srv := &ingestServer{fail: -1}
+	s := newTestShipper(t, srv, Options{BatchSize: 10, FlushInterval: time.Hour, MaxRetries: 2})
+	s.Start()
+	for i := 0; i < 4; i++ {
+		s.Ship(Event{Type: EventReceived})
+	}
+	stop(t, s)
+
+	if srv.posts != 2 {
+		t.Fatalf("tentativas = %d, want 2", srv.posts)
+	}
+	st := s.Stats()
+	if st.Shipped != 0 || st.Dropped != 4 || st.FailedBatches != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if s.Dropped() != 4 {
+		t.Fatalf("Dropped = %d", s.Dropped())
+	}
+}
+
+func TestShipperBufferFull(t *testing.T) {
+	srv := &ingestServer{}
+	s := newTestShipper(t, srv, Options{BufferSize: 2})
+	// Sem Start nada consome o buffer: o excedente é descartado sem bloquear
+	for i := 0; i < 5; i++ {
+		s.Ship(Event{Type: EventReceived})
+	}
+	if st := s.Stats(); st.Buffered != 2 || st.Dropped != 3 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestNilShipper(t *testing.T) {
+	var s *Shipper
+	s.Ship(Event{Type: EventReceived})
+	s.Start()
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.Stats() != (Stats{}) || s.Dropped() != 0 {
+		t.Fatal("shipper nil com contadores")
+	}
+}
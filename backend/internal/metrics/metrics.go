@@ -0,0 +1,283 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/logging"
+)
+
+// Mode controla o que é emitido além dos agregados expostos em /metrics
+type Mode string
+
+const (
+	// ModeSummary expõe apenas contadores/histogramas
+	ModeSummary Mode = "summary"
+	// ModeEvents também emite um registro estruturado por webhook
+	ModeEvents Mode = "events+summary"
+)
+
+// Valores especiais do label client e da classe de status
+const (
+	// OtherClient agrupa clientes além de MaxClientLabels
+	OtherClient = "other"
+	// AllClients é usado quando o label por cliente está desativado
+	AllClients    = "all"
+	UnknownClient = "unknown"
+	// ClassNetwork indica tentativa sem resposta HTTP (timeout, conexão recusada)
+	ClassNetwork = "network"
+)
+
+// latencyBuckets cobre do destino rápido ao timeout do HTTP client (15s)
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15}
+
+// Options configura o coletor
+type Options struct {
+	Mode Mode
+	// MaxClientLabels limita quantos clientes distintos viram label; 0 desativa o label por cliente
+	MaxClientLabels int
+}
+
+// Event é o registro estruturado de um webhook processado (modo events+summary)
+type Event struct {
+	Client    string
+	Provider  string
+	Type      string
+	MessageID string
+	Chat      string
+	IsGroup   bool
+	Outcome   string
+	Code      int
+	LatencyMs int64
+}
+
+// Metrics agrega as métricas do serviço e as expõe no formato texto do Prometheus
+type Metrics struct {
+	opts Options
+
+	received       *counterVec
+	ignored        *counterVec
+	clientNotFound *counterVec
+	forwarded      *counterVec
+	forwardFailed  *counterVec
+	latency        *histogramVec
+	lidConversions *counterVec
+
+	mu      sync.Mutex
+	clients map[string]struct{}
+	caches  map[string]func() (hits, misses uint64)
+	funcs   []counterFunc
+}
+
+func New(opts Options) *Metrics {
+	if opts.Mode != ModeEvents {
+		opts.Mode = ModeSummary
+	}
+	return &Metrics{
+		opts:           opts,
+		received:       newCounterVec("msdr_webhooks_received_total", "Webhooks recebidos por cliente.", "client"),
+		ignored:        newCounterVec("msdr_webhooks_ignored_total", "Webhooks ignorados por motivo (group, rule, duplicate, rate_limited).", "client", "reason"),
+		clientNotFound: newCounterVec("msdr_webhooks_client_not_found_total", "Webhooks com secretId desconhecido."),
+		forwarded:      newCounterVec("msdr_forwards_total", "Tentativas de entrega aceitas pelo destino por classe de status.", "client", "status_class"),
+		forwardFailed:  newCounterVec("msdr_forward_failures_total", "Tentativas de entrega com falha por classe de status (network = sem resposta).", "client", "status_class"),
+		latency:        newHistogramVec("msdr_destination_latency_seconds", "Latência das tentativas de entrega ao destino.", latencyBuckets, "client"),
+		lidConversions: newCounterVec("msdr_lid_conversions_total", "Conversões LID->JID por origem (store, api) e resultado.", "source", "result"),
+		clients:        make(map[string]struct{}),
+		caches:         make(map[string]func() (uint64, uint64)),
+	}
+}
+
+// Mode retorna o modo efetivo
+func (m *Metrics) Mode() Mode {
+	if m == nil {
+		return ModeSummary
+	}
+	return m.opts.Mode
+}
+
+// clientLabel limita a cardinalidade: os primeiros MaxClientLabels clientes têm label próprio
+func (m *Metrics) clientLabel(clientID string) string {
+	if m.opts.MaxClientLabels <= 0 {
+		return AllClients
+	}
+	if clientID == "" {
+		return UnknownClient
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.clients[clientID]; ok {
+		return clientID
+	}
+	if len(m.clients) >= m.opts.MaxClientLabels {
+		return OtherClient
+	}
+	m.clients[clientID] = struct{}{}
+	return clientID
+}
+
+// counterFunc é um contador mantido fora do pacote, lido no scrape
+type counterFunc struct {
+	name, help string
+	value      func() uint64
+}
+
+// RegisterCounterFunc expõe um contador sem labels mantido por outro componente
+func (m *Metrics) RegisterCounterFunc(name, help string, value func() uint64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.funcs = append(m.funcs, counterFunc{name: name, help: help, value: value})
+	m.mu.Unlock()
+}
+
+// RegisterCache expõe hits/misses de um cache em memória, lidos no scrape
+func (m *Metrics) RegisterCache(name string, stats func() (hits, misses uint64)) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.caches[name] = stats
+	m.mu.Unlock()
+}
+
+func (m *Metrics) WebhookReceived(clientID string) {
+	if m == nil {
+		return
+	}
+	m.received.inc(m.clientLabel(clientID))
+}
+
+func (m *Metrics) ClientNotFound() {
+	if m == nil {
+		return
+	}
+	m.clientNotFound.inc()
+}
+
+// Ignored conta eventos descartados sem encaminhamento
+func (m *Metrics) Ignored(clientID, reason string) {
+	if m == nil {
+		return
+	}
+	m.ignored.inc(m.clientLabel(clientID), reason)
+}
+
+// Delivery registra uma tentativa de entrega: statusCode 0 (ou err) conta como falha de rede
+func (m *Metrics) Delivery(clientID string, statusCode int, err error, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	client := m.clientLabel(clientID)
+	if err != nil || statusCode == 0 {
+		m.forwardFailed.inc(client, ClassNetwork)
+	} else if class := StatusClass(statusCode); statusCode >= 400 {
+		m.forwardFailed.inc(client, class)
+	} else {
+		m.forwarded.inc(client, class)
+	}
+	if latency > 0 {
+		m.latency.observe(latency.Seconds(), client)
+	}
+}
+
+// LIDConversion conta conversões LID->JID; err nil é sucesso
+func (m *Metrics) LIDConversion(source string, err error) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.lidConversions.inc(source, result)
+}
+
+// Event emite o registro estruturado do webhook (somente em events+summary), com os campos
+// da requisição presentes no logger do contexto
+func (m *Metrics) Event(ctx context.Context, e Event) {
+	if m == nil || m.opts.Mode != ModeEvents {
+		return
+	}
+	logging.FromContext(ctx).LogAttrs(ctx, slog.LevelInfo, "webhook_event",
+		slog.String("client", e.Client),
+		slog.String("provider", e.Provider),
+		slog.String("type", e.Type),
+		slog.String("message_id", e.MessageID),
+		slog.String("chat", e.Chat),
+		slog.Bool("is_group", e.IsGroup),
+		slog.String("outcome", e.Outcome),
+		slog.Int("code", e.Code),
+		slog.Int64("latency_ms", e.LatencyMs),
+	)
+}
+
+// StatusClass agrupa o status HTTP (2xx, 3xx, 4xx, 5xx)
+func StatusClass(code int) string {
+	if code < 100 || code > 599 {
+		return ClassNetwork
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// Handler expõe as métricas no formato texto do Prometheus (version 0.0.4)
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		m.received.write(&buf)
+		m.ignored.write(&buf)
+		m.clientNotFound.write(&buf)
+		m.forwarded.write(&buf)
+		m.forwardFailed.write(&buf)
+		m.latency.write(&buf)
+		m.lidConversions.write(&buf)
+		m.writeFuncs(&buf)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+// writeFuncs lê os contadores de caches e componentes externos no momento do scrape
+func (m *Metrics) writeFuncs(buf *bytes.Buffer) {
+	m.mu.Lock()
+	stats := make(map[string]func() (uint64, uint64), len(m.caches))
+	names := make([]string, 0, len(m.caches))
+	for name, fn := range m.caches {
+		stats[name] = fn
+		names = append(names, name)
+	}
+	m.mu.Unlock()
+	sort.Strings(names)
+
+	c := newCounterVec("msdr_cache_requests_total", "Consultas aos caches em memória por resultado (hit, miss).", "cache", "result")
+	for _, name := range names {
+		hits, misses := stats[name]()
+		c.add(float64(hits), name, "hit")
+		c.add(float64(misses), name, "miss")
+	}
+	c.write(buf)
+
+	m.mu.Lock()
+	funcs := append([]counterFunc(nil), m.funcs...)
+	m.mu.Unlock()
+	for _, f := range funcs {
+		fc := newCounterVec(f.name, f.help)
+		fc.add(float64(f.value()))
+		fc.write(buf)
+	}
+}
+
+// ParseMode interpreta METRICS_MODE (default summary)
+func ParseMode(v string) Mode {
+	if strings.EqualFold(strings.TrimSpace(v), string(ModeEvents)) {
+		return ModeEvents
+	}
+	return ModeSummary
+}
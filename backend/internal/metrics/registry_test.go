@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// output renderiza um counterVec/histogramVec como no scrape
+func output(v interface{ write(io.Writer) }) string {
+	var buf bytes.Buffer
+	v.write(&buf)
+	return buf.String()
+}
+
+func assertGolden(t *testing.T, got, want string) {
+	t.Helper()
+	if got != want {
+		t.Fatalf("saída diferente do esperado\n--- got ---\n%s--- want ---\n%s", got, want)
+	}
+}
+
+func TestCounterWithoutLabelsStartsAtZero(t *testing.T) {
+	c := newCounterVec("x_total", "Contador sem labels.")
+	assertGolden(t, output(c), `# HELP x_total Contador sem labels.
+# TYPE x_total counter
+x_total 0
+`)
+
+	c.inc()
+	c.add(2.5)
+	assertGolden(t, output(c), `# HELP x_total Contador sem labels.
+# TYPE x_total counter
+x_total 3.5
+`)
+}
+
+func TestCounterWithLabelsSorted(t *testing.T) {
+	c := newCounterVec("y_total", "Contador com labels.", "client", "reason")
+	// Vetor com labels e sem séries não emite linha de amostra
+	assertGolden(t, output(c), `# HELP y_total Contador com labels.
+# TYPE y_total counter
+`)
+
+	c.inc("b", "rule")
+	c.inc("a", "group")
+	c.inc("a", "group")
+	c.add(1e6, "a", "duplicate")
+	assertGolden(t, output(c), `# HELP y_total Contador com labels.
+# TYPE y_total counter
+y_total{client="a",reason="duplicate"} 1e+06
+y_total{client="a",reason="group"} 2
+y_total{client="b",reason="rule"} 1
+`)
+}
+
+func TestLabelEscaping(t *testing.T) {
+	c := newCounterVec("z_total", "Escape de labels.", "client", "reason")
+	c.inc(`a"b\c`+"\nd", "")
+	assertGolden(t, output(c), `# HELP z_total Escape de labels.
+# TYPE z_total counter
+z_total{client="a\"b\\c\nd",reason=""} 1
+`)
+}
+
+func TestHelpEscaping(t *testing.T) {
+	c := newCounterVec("h_total", "linha 1\nlinha \\ 2 \"aspas\"")
+	assertGolden(t, output(c), `# HELP h_total linha 1\nlinha \\ 2 "aspas"
+# TYPE h_total counter
+h_total 0
+`)
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogramVec("lat_seconds", "Latência.", []float64{0.1, 1, 2.5}, "client")
+	h.observe(0.05, "b")
+	h.observe(0.1, "a") // limite do bucket é inclusivo (le)
+	h.observe(0.5, "a")
+	h.observe(3, "a")
+	assertGolden(t, output(h), `# HELP lat_seconds Latência.
+# TYPE lat_seconds histogram
+lat_seconds_bucket{client="a",le="0.1"} 1
+lat_seconds_bucket{client="a",le="1"} 2
+lat_seconds_bucket{client="a",le="2.5"} 2
+lat_seconds_bucket{client="a",le="+Inf"} 3
+lat_seconds_sum{client="a"} 3.6
+lat_seconds_count{client="a"} 3
+lat_seconds_bucket{client="b",le="0.1"} 1
+lat_seconds_bucket{client="b",le="1"} 1
+lat_seconds_bucket{client="b",le="2.5"} 1
+lat_seconds_bucket{client="b",le="+Inf"} 1
+lat_seconds_sum{client="b"} 0.05
+lat_seconds_count{client="b"} 1
+`)
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	h := newHistogramVec("d_seconds", "Sem labels.", []float64{1})
+	h.observe(2)
+	assertGolden(t, output(h), `# HELP d_seconds Sem labels.
+# TYPE d_seconds histogram
+d_seconds_bucket{le="1"} 0
+d_seconds_bucket{le="+Inf"} 1
+d_seconds_sum 2
+d_seconds_count 1
+`)
+}
+
+// TestHandlerGolden compara o /metrics completo com testdata/metrics.golden
+func TestHandlerGolden(t *testing.T) {
+	m := New(Options{MaxClientLabels: 1})
+	m.WebhookReceived("c1")
+	m.WebhookReceived("c2") // além do limite: vira "other"
+	m.ClientNotFound()
+	m.Ignored("c1", "group")
+	m.Delivery("c1", 200, nil, 80*time.Millisecond)
+	m.Delivery("c1", 503, nil, 2*time.Second)
+	m.Delivery("c1", 0, errors.New("timeout"), 0)
+	m.LIDConversion("store", nil)
+	m.LIDConversion("api", errors.New("falhou"))
+	m.RegisterCache("client", func() (uint64, uint64) { return 7, 3 })
+	m.RegisterCounterFunc("msdr_outbox_pruned_total", "Linhas removidas do outbox.", func() uint64 { return 42 })
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "metrics.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, rec.Body.String(), string(want))
+}
@@ -2,13 +2,105 @@ package migrations
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// Run executa migrações mínimas para Client
+// migration é um passo numerado do schema; depois de publicada, version e stmts não mudam
+type migration struct {
+	version int
+	name    string
+	stmts   []string
+}
+
+// lockKey serializa Run entre instâncias que sobem ao mesmo tempo (pg_advisory_lock)
+const lockKey = 4127736301
+
+// Run aplica, em ordem e cada uma na sua transação, as migrations ainda não registradas em schema_migrations
 func Run(ctx context.Context, pool *pgxpool.Pool) error {
-	stmts := []string{
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INT PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );`); err != nil {
+		return err
+	}
+	done, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+	// Bancos anteriores ao controle por versão já têm parte do schema; os DDLs são idempotentes
+	// (IF NOT EXISTS), então reaplicá-los apenas registra a versão
+	for _, m := range all {
+		if done[m.version] {
+			continue
+		}
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			for _, s := range m.stmts {
+				if _, err := tx.Exec(ctx, s); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations(version, name) VALUES($1, $2)`, m.version, m.name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int]bool, error) {
+	rows, err := conn.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		done[v] = true
+	}
+	return done, nil
+}
+
+// Version é a versão de schema esperada por este binário (a última migration)
+func Version() int {
+	return all[len(all)-1].version
+}
+
+// Applied retorna a maior versão registrada no banco (0 se as migrations nunca rodaram)
+func Applied(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	var v int
+	err := pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+		// schema_migrations ainda não existe
+		return 0, nil
+	}
+	return v, err
+}
+
+// all lista as migrations em ordem crescente de versão; só acrescente no final com a próxima versão
+var all = []migration{
+	{1, "create_clients", []string{
 		`CREATE TABLE IF NOT EXISTS clients (
             id TEXT PRIMARY KEY,
             secret_id TEXT UNIQUE NOT NULL,
@@ -21,11 +113,187 @@ func Run(ctx context.Context, pool *pgxpool.Pool) error {
             updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
         );`,
 		`CREATE INDEX IF NOT EXISTS idx_clients_secret_id ON clients(secret_id);`,
-	}
-	for _, s := range stmts {
-		if _, err := pool.Exec(ctx, s); err != nil {
-			return err
-		}
-	}
-	return nil
+	}},
+	{2, "client_provider_credentials", []string{
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS provider_base_url TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS provider_api_token_enc TEXT NOT NULL DEFAULT '';`,
+	}},
+	{3, "client_signing_secrets", []string{
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS signing_secret_enc TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS previous_signing_secret_enc TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS signing_secret_rotated_at TIMESTAMPTZ;`,
+	}},
+	{4, "client_filter_rules", []string{
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS filter_rules JSONB;`,
+	}},
+	{5, "client_payload_format", []string{
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS payload_format TEXT NOT NULL DEFAULT 'raw';`,
+	}},
+	{6, "client_provider_meta", []string{
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS provider TEXT NOT NULL DEFAULT 'wuzapi';`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS meta_verify_token_enc TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS meta_app_secret_enc TEXT NOT NULL DEFAULT '';`,
+	}},
+	{7, "client_delivery_mode", []string{
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS delivery_mode TEXT NOT NULL DEFAULT 'sync';`,
+	}},
+	{8, "client_burst_window", []string{
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS burst_window_seconds INT NOT NULL DEFAULT 0;`,
+	}},
+	{9, "client_ordered_delivery", []string{
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS ordered_delivery BOOLEAN NOT NULL DEFAULT FALSE;`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS head_of_line_policy TEXT NOT NULL DEFAULT 'block';`,
+	}},
+	{10, "create_destinations", []string{
+		`CREATE TABLE IF NOT EXISTS destinations (
+            id TEXT PRIMARY KEY,
+            client_id TEXT NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+            name TEXT NOT NULL,
+            url TEXT NOT NULL,
+            selector JSONB NOT NULL DEFAULT '{}',
+            is_active BOOLEAN NOT NULL DEFAULT TRUE,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_destinations_client ON destinations(client_id);`,
+	}},
+	{11, "create_outbox", []string{
+		`CREATE TABLE IF NOT EXISTS outbox (
+            id TEXT PRIMARY KEY,
+            client_id TEXT NOT NULL DEFAULT '',
+            secret_id TEXT NOT NULL,
+            target_url TEXT NOT NULL,
+            content_type TEXT NOT NULL,
+            body BYTEA NOT NULL,
+            status TEXT NOT NULL DEFAULT 'pending',
+            attempts INT NOT NULL DEFAULT 0,
+            max_attempts INT NOT NULL DEFAULT 8,
+            next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            last_error TEXT NOT NULL DEFAULT '',
+            last_status INT NOT NULL DEFAULT 0,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            delivered_at TIMESTAMPTZ
+        );`,
+		`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS destination_id TEXT NOT NULL DEFAULT '';`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox(next_attempt_at) WHERE status IN ('pending','delivering');`,
+	}},
+	{12, "outbox_ordering", []string{
+		`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS chat_key TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS event_at TIMESTAMPTZ;`,
+		`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS ordering TEXT NOT NULL DEFAULT '';`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_chat ON outbox(chat_key, event_at) WHERE chat_key <> '' AND status IN ('pending','delivering');`,
+	}},
+	{13, "outbox_log_context", []string{
+		`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS event_id TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS chat TEXT NOT NULL DEFAULT '';`,
+	}},
+	{14, "outbox_trace_context", []string{
+		`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS trace_parent TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';`,
+	}},
+	{15, "create_delivery_attempts", []string{
+		`CREATE TABLE IF NOT EXISTS delivery_attempts (
+            id BIGSERIAL PRIMARY KEY,
+            delivery_id TEXT NOT NULL REFERENCES outbox(id) ON DELETE CASCADE,
+            attempt INT NOT NULL,
+            status_code INT NOT NULL DEFAULT 0,
+            error TEXT NOT NULL DEFAULT '',
+            latency_ms BIGINT NOT NULL DEFAULT 0,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_delivery_attempts_delivery ON delivery_attempts(delivery_id);`,
+	}},
+	{16, "create_delivery_log", []string{
+		`CREATE TABLE IF NOT EXISTS delivery_log (
+            id BIGSERIAL PRIMARY KEY,
+            delivery_id TEXT NOT NULL,
+            client_id TEXT NOT NULL DEFAULT '',
+            secret_id TEXT NOT NULL,
+            destination_id TEXT NOT NULL DEFAULT '',
+            target_url TEXT NOT NULL,
+            event_id TEXT NOT NULL DEFAULT '',
+            chat TEXT NOT NULL DEFAULT '',
+            attempt INT NOT NULL,
+            status TEXT NOT NULL,
+            status_code INT NOT NULL DEFAULT 0,
+            error TEXT NOT NULL DEFAULT '',
+            latency_ms BIGINT NOT NULL DEFAULT 0,
+            response_body TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_delivery_log_client ON delivery_log(client_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_delivery_log_secret ON delivery_log(secret_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_delivery_log_chat ON delivery_log(chat, created_at DESC) WHERE chat <> '';`,
+		`CREATE INDEX IF NOT EXISTS idx_delivery_log_event ON delivery_log(event_id) WHERE event_id <> '';`,
+		`CREATE INDEX IF NOT EXISTS idx_delivery_log_created_at ON delivery_log(created_at);`,
+	}},
+	{17, "create_dead_letters", []string{
+		`CREATE TABLE IF NOT EXISTS dead_letters (
+            id TEXT PRIMARY KEY,
+            delivery_id TEXT NOT NULL,
+            client_id TEXT NOT NULL DEFAULT '',
+            secret_id TEXT NOT NULL,
+            target_url TEXT NOT NULL,
+            content_type TEXT NOT NULL,
+            body BYTEA NOT NULL,
+            attempts INT NOT NULL,
+            last_error TEXT NOT NULL DEFAULT '',
+            last_status INT NOT NULL DEFAULT 0,
+            received_at TIMESTAMPTZ NOT NULL,
+            failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );`,
+		`ALTER TABLE dead_letters ADD COLUMN IF NOT EXISTS destination_id TEXT NOT NULL DEFAULT '';`,
+		`CREATE INDEX IF NOT EXISTS idx_dead_letters_client ON dead_letters(client_id, failed_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_dead_letters_secret ON dead_letters(secret_id, failed_at DESC);`,
+	}},
+	{18, "create_lid_mappings", []string{
+		`CREATE TABLE IF NOT EXISTS lid_mappings (
+            lid TEXT PRIMARY KEY,
+            jid TEXT NOT NULL,
+            source TEXT NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );`,
+	}},
+	{19, "create_processed_events", []string{
+		`CREATE TABLE IF NOT EXISTS processed_events (
+            secret_id TEXT NOT NULL,
+            message_id TEXT NOT NULL,
+            seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (secret_id, message_id)
+        );`,
+		`CREATE INDEX IF NOT EXISTS idx_processed_events_seen_at ON processed_events(seen_at);`,
+	}},
+	{20, "outbox_finished_index", []string{
+		`CREATE INDEX IF NOT EXISTS idx_outbox_finished ON outbox(updated_at) WHERE status IN ('delivered','dead');`,
+	}},
+	{21, "create_admin_api_keys", []string{
+		`CREATE TABLE IF NOT EXISTS admin_api_keys (
+            id TEXT PRIMARY KEY,
+            name TEXT NOT NULL,
+            prefix TEXT NOT NULL,
+            key_hash TEXT NOT NULL UNIQUE,
+            scopes TEXT[] NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            last_used_at TIMESTAMPTZ,
+            revoked_at TIMESTAMPTZ
+        );`,
+	}},
+	{22, "create_burst_buffer", []string{
+		`CREATE TABLE IF NOT EXISTS burst_buffer (
+            id BIGSERIAL PRIMARY KEY,
+            secret_id TEXT NOT NULL,
+            burst_key TEXT NOT NULL,
+            entry JSONB NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );`,
+	}},
+	{23, "outbox_signed", []string{
+		`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS signed BOOLEAN NOT NULL DEFAULT FALSE;`,
+	}},
+	// schema_version guardava só a quantidade de statements; o controle agora é schema_migrations
+	{24, "drop_schema_version", []string{
+		`DROP TABLE IF EXISTS schema_version;`,
+	}},
 }
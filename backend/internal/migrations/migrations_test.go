@@ -0,0 +1,23 @@
+package migrations
+
+import "testing"
+
+// TestMigrationsNumbered garante versões sequenciais a partir de 1 (uma versão publicada nunca muda de lugar)
+func TestMigrationsNumbered(t *testing.T) {
+	names := map[string]bool{}
+	for i, m := range all {
+		if m.version != i+1 {
+			t.Fatalf("migration %q na posição %d tem versão %d, want %d", m.name, i, m.version, i+1)
+		}
+		if m.name == "" || names[m.name] {
+			t.Fatalf("migration %d com nome vazio ou repetido: %q", m.version, m.name)
+		}
+		names[m.name] = true
+		if len(m.stmts) == 0 {
+			t.Fatalf("migration %d (%s) sem statements", m.version, m.name)
+		}
+	}
+	if Version() != len(all) {
+		t.Fatalf("Version = %d, want %d", Version(), len(all))
+	}
+}
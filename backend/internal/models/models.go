@@ -10,16 +10,143 @@ const (
 	PlanSCALE Plan = "SCALE"
 )
 
+// PayloadFormat define o que é entregue ao cliente: corpo original do provider ou evento canônico
+type PayloadFormat string
+
+const (
+	PayloadRaw        PayloadFormat = "raw"
+	PayloadNormalized PayloadFormat = "normalized"
+)
+
+// DeliveryMode define se o webhook espera o destino (sync) ou só enfileira e responde 202 (async)
+type DeliveryMode string
+
+const (
+	DeliverySync  DeliveryMode = "sync"
+	DeliveryAsync DeliveryMode = "async"
+)
+
+// HOLPolicy define o que acontece com a fila de um chat quando o evento da frente falha
+type HOLPolicy string
+
+const (
+	// HOLBlock segura o chat até o evento da frente ser entregue ou ir para dead-letter
+	HOLBlock HOLPolicy = "block"
+	// HOLSkip libera o chat após a primeira falha; o evento segue em retry fora de ordem
+	HOLSkip HOLPolicy = "skip"
+)
+
 type Client struct {
-	ID              string    `json:"id"`
-	SecretID        string    `json:"secretId"`
-	Name            string    `json:"name"`
-	WebhookURL      string    `json:"webhookUrl"`
-	Plan            Plan      `json:"plan"`
-	RateLimitPerMin int       `json:"rateLimitPerMin"`
-	IsActive        bool      `json:"isActive"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	ID              string `json:"id"`
+	SecretID        string `json:"secretId"`
+	Name            string `json:"name"`
+	WebhookURL      string `json:"webhookUrl"`
+	Plan            Plan   `json:"plan"`
+	RateLimitPerMin int    `json:"rateLimitPerMin"`
+	IsActive        bool   `json:"isActive"`
+	// Provider (Avisa/wuzapi) usado na conversão LID->JID; token fica cifrado no banco
+	ProviderBaseURL  string `json:"providerBaseUrl"`
+	ProviderAPIToken string `json:"-"`
+	HasProviderToken bool   `json:"hasProviderToken"`
+	// Segredo HMAC para assinar entregas; o anterior segue válido por uma janela após a rotação
+	SigningSecret          string     `json:"-"`
+	PreviousSigningSecret  string     `json:"-"`
+	SigningSecretRotatedAt *time.Time `json:"signingSecretRotatedAt,omitempty"`
+	// Regras de filtro avaliadas em ordem; nil usa DefaultFilterRules
+	FilterRules []FilterRule `json:"filterRules"`
+	// Provider do envelope recebido (wuzapi, evolution, zapi, meta); vazio usa wuzapi
+	Provider string `json:"provider"`
+	// Meta Cloud API: token do handshake GET e app secret do X-Hub-Signature-256 (cifrados no banco)
+	MetaVerifyToken  string `json:"-"`
+	MetaAppSecret    string `json:"-"`
+	HasMetaAppSecret bool   `json:"hasMetaAppSecret"`
+	// Formato do payload encaminhado; vazio equivale a raw
+	PayloadFormat PayloadFormat `json:"payloadFormat"`
+	// Modo de entrega; vazio equivale a sync
+	DeliveryMode DeliveryMode `json:"deliveryMode"`
+	// Janela (s) para agregar rajadas de mensagens do mesmo chat/sender; 0 desativa
+	BurstWindowSeconds int `json:"burstWindowSeconds"`
+	// Entrega ordenada por chat (sempre via fila) e política de head-of-line
+	OrderedDelivery  bool      `json:"orderedDelivery"`
+	HeadOfLinePolicy HOLPolicy `json:"headOfLinePolicy"`
+	// Destinos adicionais ativos (carregados pelo resolver do data-plane)
+	Destinations []Destination `json:"destinations,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+}
+
+// SigningSecrets retorna os segredos usados para assinar: o atual e, dentro da janela de grace, o anterior
+func (c *Client) SigningSecrets(grace time.Duration) []string {
+	var out []string
+	if c.SigningSecret != "" {
+		out = append(out, c.SigningSecret)
+	}
+	if c.PreviousSigningSecret != "" && c.SigningSecretRotatedAt != nil && time.Since(*c.SigningSecretRotatedAt) < grace {
+		out = append(out, c.PreviousSigningSecret)
+	}
+	return out
+}
+
+type RuleAction string
+
+const (
+	RuleDrop    RuleAction = "drop"
+	RuleForward RuleAction = "forward"
+	RuleRoute   RuleAction = "route"
+)
+
+// DefaultGroupRuleName identifica a regra padrão (comportamento legado de descartar grupos)
+const DefaultGroupRuleName = "default_drop_groups"
+
+// RuleMatch define os critérios da regra; campos vazios/nil não restringem.
+// Chat, Sender e EventTypes aceitam glob (ex.: "*@g.us", "*Receipt").
+type RuleMatch struct {
+	IsGroup     *bool    `json:"isGroup,omitempty"`
+	Chat        string   `json:"chat,omitempty"`
+	Sender      string   `json:"sender,omitempty"`
+	EventTypes  []string `json:"eventTypes,omitempty"`
+	FromMe      *bool    `json:"fromMe,omitempty"`
+	MessageType string   `json:"messageType,omitempty"`
+}
+
+// FilterRule decide o destino de um evento: descartar, encaminhar ou rotear para outra URL
+type FilterRule struct {
+	Name     string     `json:"name,omitempty"`
+	Match    RuleMatch  `json:"match"`
+	Action   RuleAction `json:"action"`
+	RouteURL string     `json:"routeUrl,omitempty"`
+}
+
+// DefaultFilterRules reproduz o filtro legado: grupos e broadcasts são descartados
+func DefaultFilterRules() []FilterRule {
+	isGroup := true
+	return []FilterRule{{Name: DefaultGroupRuleName, Match: RuleMatch{IsGroup: &isGroup}, Action: RuleDrop}}
+}
+
+// EffectiveFilterRules retorna as regras do cliente ou as padrão quando não configuradas
+func (c *Client) EffectiveFilterRules() []FilterRule {
+	if c.FilterRules == nil {
+		return DefaultFilterRules()
+	}
+	return c.FilterRules
+}
+
+// Destination é um endpoint extra do cliente; recebe os eventos que casam com o Selector.
+// Selector vazio recebe todos os eventos.
+type Destination struct {
+	ID        string    `json:"id"`
+	ClientID  string    `json:"clientId"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Selector  RuleMatch `json:"selector"`
+	IsActive  bool      `json:"isActive"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// IsEmpty indica que o selector não restringe nenhum campo
+func (m RuleMatch) IsEmpty() bool {
+	return m.IsGroup == nil && m.Chat == "" && m.Sender == "" && len(m.EventTypes) == 0 && m.FromMe == nil && m.MessageType == ""
 }
 
 type ResolveResponse struct {
@@ -27,3 +154,183 @@ type ResolveResponse struct {
 	RateLimitPerMin int    `json:"rateLimitPerMin"`
 	Plan            Plan   `json:"plan"`
 }
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryInFlight  DeliveryStatus = "delivering"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+// Delivery é um evento aceito aguardando entrega (tabela outbox)
+type Delivery struct {
+	ID            string `json:"id"`
+	ClientID      string `json:"clientId"`
+	SecretID      string `json:"secretId"`
+	DestinationID string `json:"destinationId,omitempty"`
+	TargetURL     string `json:"targetUrl"`
+	ContentType   string `json:"contentType"`
+	Body          []byte `json:"-"`
+	// Evento de origem (consulta no log de entregas)
+	EventID string `json:"eventId,omitempty"`
+	Chat    string `json:"chat,omitempty"`
+	// X-Request-ID da requisição de origem, repassado ao destino em todas as tentativas
+	RequestID string `json:"requestId,omitempty"`
+	// W3C traceparent da requisição de origem; retries dos workers continuam o mesmo trace
+	TraceParent string `json:"-"`
+	// Signed marca entregas de cliente com assinatura HMAC; sem segredo na tentativa, não sai sem assinatura
+	Signed bool `json:"signed"`
+	// Ordenação por chat: entregas com o mesmo ChatKey saem uma a uma, por EventAt
+	ChatKey       string         `json:"chatKey,omitempty"`
+	EventAt       *time.Time     `json:"eventAt,omitempty"`
+	Ordering      HOLPolicy      `json:"ordering,omitempty"`
+	Status        DeliveryStatus `json:"status"`
+	Attempts      int            `json:"attempts"`
+	MaxAttempts   int            `json:"maxAttempts"`
+	NextAttemptAt time.Time      `json:"nextAttemptAt"`
+	LastError     string         `json:"lastError"`
+	LastStatus    int            `json:"lastStatus"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	DeliveredAt   *time.Time     `json:"deliveredAt,omitempty"`
+}
+
+// DeliveryAttempt registra o resultado de cada tentativa de entrega
+type DeliveryAttempt struct {
+	ID         int64     `json:"id"`
+	DeliveryID string    `json:"deliveryId"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode"`
+	Error      string    `json:"error"`
+	LatencyMs  int64     `json:"latencyMs"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// DeadLetter é um evento que esgotou as tentativas de entrega
+type DeadLetter struct {
+	ID            string    `json:"id"`
+	DeliveryID    string    `json:"deliveryId"`
+	ClientID      string    `json:"clientId"`
+	SecretID      string    `json:"secretId"`
+	DestinationID string    `json:"destinationId,omitempty"`
+	TargetURL     string    `json:"targetUrl"`
+	ContentType   string    `json:"contentType"`
+	Body          []byte    `json:"-"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"lastError"`
+	LastStatus    int       `json:"lastStatus"`
+	ReceivedAt    time.Time `json:"receivedAt"`
+	FailedAt      time.Time `json:"failedAt"`
+}
+
+// DeliveryLog é o registro permanente (até a retenção) de cada tentativa de entrega,
+// usado para responder "o evento X foi enviado?" mesmo após a outbox ser limpa
+type DeliveryLog struct {
+	ID            int64          `json:"id"`
+	DeliveryID    string         `json:"deliveryId"`
+	ClientID      string         `json:"clientId"`
+	SecretID      string         `json:"secretId"`
+	DestinationID string         `json:"destinationId,omitempty"`
+	TargetURL     string         `json:"targetUrl"`
+	EventID       string         `json:"eventId,omitempty"`
+	Chat          string         `json:"chat,omitempty"`
+	Attempt       int            `json:"attempt"`
+	Status        DeliveryStatus `json:"status"`
+	StatusCode    int            `json:"statusCode"`
+	Error         string         `json:"error,omitempty"`
+	LatencyMs     int64          `json:"latencyMs"`
+	// ResponseBody é truncado pelo dispatcher
+	ResponseBody string    `json:"responseBody,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// DeliveryLogFilter seleciona registros do log de entregas
+type DeliveryLogFilter struct {
+	ClientID   string
+	SecretID   string
+	Chat       string
+	EventID    string
+	Status     DeliveryStatus
+	StatusCode int
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+	Offset     int
+}
+
+// DeadLetterFilter seleciona dead letters para listagem/replay/descarte em lote
+type DeadLetterFilter struct {
+	ClientID string
+	SecretID string
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int
+	Offset   int
+}
+
+// Origem de um mapeamento LID->JID
+const (
+	LIDSourceAPI     = "api"
+	LIDSourceLearned = "learned"
+	LIDSourceManual  = "manual"
+)
+
+// LIDMapping associa um LID (@lid) ao JID real (@s.whatsapp.net)
+type LIDMapping struct {
+	LID       string    `json:"lid"`
+	JID       string    `json:"jid"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// APIKeyScope é uma permissão das chaves da API admin (/api)
+type APIKeyScope string
+
+const (
+	// ScopeRead permite consultas (GET)
+	ScopeRead APIKeyScope = "read"
+	// ScopeWrite permite criar, alterar, remover e reprocessar
+	ScopeWrite APIKeyScope = "write"
+	// ScopeResolve permite o lookup por secretId e ver secretIds sem máscara (?includeSecrets=true)
+	ScopeResolve APIKeyScope = "resolve"
+)
+
+// APIKeyScopes são os escopos válidos, na ordem em que são exibidos
+var APIKeyScopes = []APIKeyScope{ScopeRead, ScopeWrite, ScopeResolve}
+
+// APIKey é uma chave da API admin; só o hash SHA-256 é persistido, a chave em claro é exibida uma única vez
+type APIKey struct {
+	ID         string        `json:"id"`
+	Name       string        `json:"name"`
+	Prefix     string        `json:"prefix"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	LastUsedAt *time.Time    `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time    `json:"revokedAt,omitempty"`
+}
+
+// HasScope indica se a chave concede o escopo
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	if k == nil {
+		return false
+	}
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// BufferedEvent é um evento segurado pelo agregador de rajadas até o flush
+type BufferedEvent struct {
+	ID        int64
+	SecretID  string
+	BurstKey  string
+	Entry     []byte
+	CreatedAt time.Time
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/logging"
+)
+
+// Notifier envia alertas ao Slack de forma assíncrona (Incoming Webhook ou bot)
+type Notifier struct {
+	WebhookURL string
+	BotToken   string
+	ChannelID  string
+	Logger     *slog.Logger
+
+	// envios em andamento, aguardados no shutdown
+	wg sync.WaitGroup
+}
+
+func NewNotifier(webhookURL, botToken, channelID string, logger *slog.Logger) *Notifier {
+	return &Notifier{
+		WebhookURL: strings.TrimSpace(webhookURL),
+		BotToken:   strings.TrimSpace(botToken),
+		ChannelID:  strings.TrimSpace(channelID),
+		Logger:     logger,
+	}
+}
+
+// NotifyContext anexa o requestId do contexto à mensagem, para cruzar o alerta com os logs
+func (n *Notifier) NotifyContext(ctx context.Context, msg string) {
+	if id := logging.RequestID(ctx); id != "" {
+		msg += " | requestId=" + id
+	}
+	n.Notify(msg)
+}
+
+// Notify dispara a mensagem em background; Incoming Webhook tem prioridade sobre o bot
+func (n *Notifier) Notify(msg string) {
+	if n == nil {
+		return
+	}
+	if n.WebhookURL != "" {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := PostSlack(ctx, n.WebhookURL, msg); err != nil && n.Logger != nil {
+				n.Logger.Error("Slack notify (webhook) error", "err", err)
+			}
+		}()
+	} else if n.BotToken != "" && n.ChannelID != "" {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := PostSlackWithBot(ctx, n.BotToken, n.ChannelID, msg); err != nil && n.Logger != nil {
+				n.Logger.Error("Slack notify (bot) error", "err", err)
+			}
+		}()
+	}
+}
+
+// Wait aguarda os alertas em envio, respeitando o prazo do ctx
+func (n *Notifier) Wait(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
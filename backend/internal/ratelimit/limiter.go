@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+)
+
+// Limiter aplica token bucket por chave (secretId) usando o limite/plano do cliente
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	throttled map[string]uint64
+	idleTTL   time.Duration
+	stopChan  chan struct{}
+}
+
+type bucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64 // tokens por segundo
+	last     time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets:   make(map[string]*bucket),
+		throttled: make(map[string]uint64),
+		idleTTL:   10 * time.Minute,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Burst calcula a capacidade do bucket conforme o plano.
+// FREE absorve ~10s de tráfego, PRO ~30s e SCALE o minuto inteiro.
+func Burst(plan models.Plan, perMin int) int {
+	var b int
+	switch plan {
+	case models.PlanSCALE:
+		b = perMin
+	case models.PlanPRO:
+		b = perMin / 2
+	default:
+		b = perMin / 6
+	}
+	if b < 1 {
+		b = 1
+	}
+	return b
+}
+
+// Allow consome um token da chave. Quando negado, retorna o tempo até o próximo token.
+func (l *Limiter) Allow(key string, perMin int, plan models.Plan) (bool, time.Duration) {
+	if perMin <= 0 {
+		return true, 0
+	}
+	now := time.Now()
+	rate := float64(perMin) / 60
+	capacity := float64(Burst(plan, perMin))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, capacity: capacity, rate: rate, last: now}
+		l.buckets[key] = b
+	}
+	// Limite/plano podem mudar via admin API; ajusta sem recriar o bucket
+	b.rate = rate
+	b.capacity = capacity
+
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	l.throttled[key]++
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// Throttled retorna um snapshot dos eventos barrados por chave; a contagem some junto
+// com o bucket quando a chave fica ociosa (ver StartJanitor)
+func (l *Limiter) Throttled() map[string]uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make(map[string]uint64, len(l.throttled))
+	for k, v := range l.throttled {
+		out[k] = v
+	}
+	return out
+}
+
+// Reset remove o bucket e o contador de uma chave
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	delete(l.buckets, key)
+	delete(l.throttled, key)
+	l.mu.Unlock()
+}
+
+// StartJanitor remove periodicamente os buckets ociosos (bloqueante; rode em goroutine)
+func (l *Limiter) StartJanitor() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.sweep(time.Now())
+		case <-l.stopChan:
+			return
+		}
+	}
+}
+
+// sweep remove buckets sem uso há mais de idleTTL e retorna quantos saíram. Todo contador
+// tem bucket (criado antes do primeiro bloqueio), então remover os dois juntos limita o
+// mapa às chaves ativas.
+func (l *Limiter) sweep(now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > l.idleTTL {
+			delete(l.buckets, k)
+			delete(l.throttled, k)
+			n++
+		}
+	}
+	return n
+}
+
+func (l *Limiter) StopJanitor() { close(l.stopChan) }
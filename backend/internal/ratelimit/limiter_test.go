@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+)
+
+func TestBurst(t *testing.T) {
+	tests := []struct {
+		plan   models.Plan
+		perMin int
+		want   int
+	}{
+		{models.PlanFREE, 60, 10},
+		{models.PlanPRO, 60, 30},
+		{models.PlanSCALE, 60, 60},
+		{"", 60, 10},
+		{models.PlanFREE, 3, 1},
+	}
+	for _, tt := range tests {
+		if got := Burst(tt.plan, tt.perMin); got != tt.want {
+			t.Errorf("Burst(%s, %d) = %d, want %d", tt.plan, tt.perMin, got, tt.want)
+		}
+	}
+}
+
+// drain consome tokens até o primeiro bloqueio e retorna quantos passaram
+func drain(l *Limiter, key string, perMin int, plan models.Plan) (int, time.Duration) {
+	for n := 0; ; n++ {
+		if ok, wait := l.Allow(key, perMin, plan); !ok {
+			return n, wait
+		}
+	}
+}
+
+// rewind simula a passagem do tempo desde o último Allow da chave
+func rewind(l *Limiter, key string, d time.Duration) {
+	l.mu.Lock()
+	l.buckets[key].last = l.buckets[key].last.Add(-d)
+	l.mu.Unlock()
+}
+
+func TestAllowBurstAndWait(t *testing.T) {
+	l := NewLimiter()
+	n, wait := drain(l, "s1", 60, models.PlanFREE)
+	if n != 10 {
+		t.Fatalf("burst = %d, want 10", n)
+	}
+	// 60/min = 1 token por segundo
+	if wait <= 0 || wait > time.Second {
+		t.Fatalf("wait = %s, want (0, 1s]", wait)
+	}
+	if got := l.Throttled()["s1"]; got != 1 {
+		t.Fatalf("throttled = %d, want 1", got)
+	}
+}
+
+func TestAllowRefill(t *testing.T) {
+	l := NewLimiter()
+	drain(l, "s1", 60, models.PlanFREE)
+
+	rewind(l, "s1", 2500*time.Millisecond)
+	if n, _ := drain(l, "s1", 60, models.PlanFREE); n != 2 {
+		t.Fatalf("após 2,5s passaram %d, want 2", n)
+	}
+	// Reposição limitada à capacidade do bucket
+	rewind(l, "s1", time.Hour)
+	if n, _ := drain(l, "s1", 60, models.PlanFREE); n != 10 {
+		t.Fatalf("após 1h passaram %d, want 10 (capacidade)", n)
+	}
+}
+
+func TestAllowPerKeyIsolation(t *testing.T) {
+	l := NewLimiter()
+	drain(l, "s1", 60, models.PlanFREE)
+	if ok, _ := l.Allow("s2", 60, models.PlanFREE); !ok {
+		t.Fatal("s2 barrado pelo consumo de s1")
+	}
+	throttled := l.Throttled()
+	if throttled["s1"] != 1 || throttled["s2"] != 0 {
+		t.Fatalf("throttled = %v", throttled)
+	}
+	l.Reset("s1")
+	if ok, _ := l.Allow("s1", 60, models.PlanFREE); !ok {
+		t.Fatal("s1 barrado após Reset")
+	}
+	if _, ok := l.Throttled()["s1"]; ok {
+		t.Fatal("contador de s1 mantido após Reset")
+	}
+}
+
+func TestAllowUnlimitedAndPlanChange(t *testing.T) {
+	l := NewLimiter()
+	for i := 0; i < 1000; i++ {
+		if ok, _ := l.Allow("s1", 0, models.PlanFREE); !ok {
+			t.Fatal("limite 0 deveria liberar tudo")
+		}
+	}
+	// Upgrade de plano amplia a capacidade sem recriar o bucket
+	drain(l, "s2", 60, models.PlanFREE)
+	rewind(l, "s2", time.Hour)
+	if n, _ := drain(l, "s2", 60, models.PlanSCALE); n != 60 {
+		t.Fatalf("após upgrade passaram %d, want 60", n)
+	}
+}
+
+func TestSweepIdleBuckets(t *testing.T) {
+	l := NewLimiter()
+	drain(l, "ociosa", 60, models.PlanFREE)
+	drain(l, "ativa", 60, models.PlanFREE)
+	rewind(l, "ociosa", l.idleTTL+time.Second)
+
+	if n := l.sweep(time.Now()); n != 1 {
+		t.Fatalf("sweep = %d, want 1", n)
+	}
+	l.mu.Lock()
+	_, bucket := l.buckets["ociosa"]
+	_, counter := l.throttled["ociosa"]
+	_, active := l.buckets["ativa"]
+	l.mu.Unlock()
+	if bucket || counter {
+		t.Fatal("bucket/contador da chave ociosa mantidos")
+	}
+	if !active {
+		t.Fatal("bucket ativo removido")
+	}
+	if got := l.Throttled()["ativa"]; got != 1 {
+		t.Fatalf("contador da chave ativa = %d, want 1", got)
+	}
+}
+
+func TestJanitorStops(t *testing.T) {
+	l := NewLimiter()
+	done := make(chan struct{})
+	go func() {
+		l.StartJanitor()
+		close(done)
+	}()
+	l.StopJanitor()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor não parou")
+	}
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, k *models.APIKey, keyHash string) error
+	GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	List(ctx context.Context) ([]models.APIKey, error)
+	Revoke(ctx context.Context, id string) (string, error)
+	TouchLastUsed(ctx context.Context, id string) error
+}
+
+type apiKeyRepository struct{ db *pgxpool.Pool }
+
+func NewAPIKeyRepository(db *pgxpool.Pool) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, name, prefix, scopes, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*models.APIKey, error) {
+	var k models.APIKey
+	var scopes []string
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+		return nil, err
+	}
+	for _, s := range scopes {
+		k.Scopes = append(k.Scopes, models.APIKeyScope(s))
+	}
+	return &k, nil
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, k *models.APIKey, keyHash string) error {
+	if k.ID == "" {
+		k.ID = uuid.NewString()
+	}
+	scopes := make([]string, len(k.Scopes))
+	for i, s := range k.Scopes {
+		scopes[i] = string(s)
+	}
+	row := r.db.QueryRow(ctx, `INSERT INTO admin_api_keys(id, name, prefix, key_hash, scopes) VALUES($1,$2,$3,$4,$5) RETURNING created_at`,
+		k.ID, k.Name, k.Prefix, keyHash, scopes)
+	return row.Scan(&k.CreatedAt)
+}
+
+// GetByHash busca a chave pelo hash, inclusive revogada (o chamador verifica RevokedAt)
+func (r *apiKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM admin_api_keys WHERE key_hash=$1`, keyHash))
+}
+
+func (r *apiKeyRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+apiKeyColumns+` FROM admin_api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *k)
+	}
+	return out, rows.Err()
+}
+
+// Revoke marca a chave como revogada e retorna o hash (para invalidar o cache).
+// pgx.ErrNoRows se a chave não existe ou já estava revogada.
+func (r *apiKeyRepository) Revoke(ctx context.Context, id string) (string, error) {
+	var keyHash string
+	err := r.db.QueryRow(ctx, `UPDATE admin_api_keys SET revoked_at=NOW() WHERE id=$1 AND revoked_at IS NULL RETURNING key_hash`, id).Scan(&keyHash)
+	return keyHash, err
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id string) error {
+	_, err := r.db.Exec(ctx, `UPDATE admin_api_keys SET last_used_at=NOW() WHERE id=$1`, id)
+	return err
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BurstBufferRepository guarda os eventos das rajadas ainda não enfileiradas
+type BurstBufferRepository interface {
+	Save(ctx context.Context, secretID, burstKey string, entry []byte) (int64, error)
+	List(ctx context.Context) ([]models.BufferedEvent, error)
+	Delete(ctx context.Context, ids []int64) error
+}
+
+type burstBufferRepository struct{ db *pgxpool.Pool }
+
+func NewBurstBufferRepository(db *pgxpool.Pool) BurstBufferRepository {
+	return &burstBufferRepository{db: db}
+}
+
+func (r *burstBufferRepository) Save(ctx context.Context, secretID, burstKey string, entry []byte) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, `INSERT INTO burst_buffer(secret_id, burst_key, entry) VALUES($1,$2,$3) RETURNING id`,
+		secretID, burstKey, entry).Scan(&id)
+	return id, err
+}
+
+// List retorna os eventos pendentes na ordem de chegada
+func (r *burstBufferRepository) List(ctx context.Context) ([]models.BufferedEvent, error) {
+	rows, err := r.db.Query(ctx, `SELECT id, secret_id, burst_key, entry, created_at FROM burst_buffer ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.BufferedEvent
+	for rows.Next() {
+		var e models.BufferedEvent
+		if err := rows.Scan(&e.ID, &e.SecretID, &e.BurstKey, &e.Entry, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (r *burstBufferRepository) Delete(ctx context.Context, ids []int64) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM burst_buffer WHERE id = ANY($1)`, ids)
+	return err
+}
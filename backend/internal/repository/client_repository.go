@@ -2,9 +2,13 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/secrets"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/pkg/webhooksig"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -15,11 +19,53 @@ type ClientRepository interface {
 	List(ctx context.Context, limit, offset int) ([]models.Client, error)
 	Update(ctx context.Context, c *models.Client) error
 	Delete(ctx context.Context, id string) error
+	RotateSigningSecret(ctx context.Context, id string) (string, error)
+	UpdateFilterRules(ctx context.Context, id string, rules []models.FilterRule) error
 }
 
-type clientRepository struct{ db *pgxpool.Pool }
+type clientRepository struct {
+	db  *pgxpool.Pool
+	box *secrets.Box
+}
+
+func NewClientRepository(db *pgxpool.Pool, box *secrets.Box) ClientRepository {
+	return &clientRepository{db: db, box: box}
+}
 
-func NewClientRepository(db *pgxpool.Pool) ClientRepository { return &clientRepository{db: db} }
+const clientColumns = `id, secret_id, name, webhook_url, plan, rate_limit_per_min, is_active, provider_base_url, provider_api_token_enc, signing_secret_enc, previous_signing_secret_enc, signing_secret_rotated_at, filter_rules, payload_format, provider, meta_verify_token_enc, meta_app_secret_enc, delivery_mode, burst_window_seconds, ordered_delivery, head_of_line_policy, created_at, updated_at`
+
+func (r *clientRepository) scanClient(row interface{ Scan(dest ...any) error }) (*models.Client, error) {
+	var c models.Client
+	var tokenEnc, signingEnc, prevSigningEnc, metaVerifyEnc, metaSecretEnc string
+	var rulesJSON []byte
+	if err := row.Scan(&c.ID, &c.SecretID, &c.Name, &c.WebhookURL, &c.Plan, &c.RateLimitPerMin, &c.IsActive, &c.ProviderBaseURL, &tokenEnc, &signingEnc, &prevSigningEnc, &c.SigningSecretRotatedAt, &rulesJSON, &c.PayloadFormat, &c.Provider, &metaVerifyEnc, &metaSecretEnc, &c.DeliveryMode, &c.BurstWindowSeconds, &c.OrderedDelivery, &c.HeadOfLinePolicy, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if rulesJSON != nil {
+		if err := json.Unmarshal(rulesJSON, &c.FilterRules); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if c.ProviderAPIToken, err = r.box.Decrypt(tokenEnc); err != nil {
+		return nil, err
+	}
+	if c.SigningSecret, err = r.box.Decrypt(signingEnc); err != nil {
+		return nil, err
+	}
+	if c.PreviousSigningSecret, err = r.box.Decrypt(prevSigningEnc); err != nil {
+		return nil, err
+	}
+	if c.MetaVerifyToken, err = r.box.Decrypt(metaVerifyEnc); err != nil {
+		return nil, err
+	}
+	if c.MetaAppSecret, err = r.box.Decrypt(metaSecretEnc); err != nil {
+		return nil, err
+	}
+	c.HasProviderToken = c.ProviderAPIToken != ""
+	c.HasMetaAppSecret = c.MetaAppSecret != ""
+	return &c, nil
+}
 
 func (r *clientRepository) Create(ctx context.Context, c *models.Client) error {
 	if c.ID == "" {
@@ -28,55 +74,90 @@ func (r *clientRepository) Create(ctx context.Context, c *models.Client) error {
 	if c.SecretID == "" {
 		c.SecretID = uuid.NewString()
 	}
-	if _, err := r.db.Exec(ctx, `INSERT INTO clients(id, secret_id, name, webhook_url, plan, rate_limit_per_min, is_active)
-        VALUES($1,$2,$3,$4,$5,$6,$7)`, c.ID, c.SecretID, c.Name, c.WebhookURL, c.Plan, c.RateLimitPerMin, c.IsActive); err != nil {
+	if c.SigningSecret == "" {
+		secret, err := webhooksig.NewSecret()
+		if err != nil {
+			return err
+		}
+		c.SigningSecret = secret
+	}
+	tokenEnc, err := r.box.Encrypt(c.ProviderAPIToken)
+	if err != nil {
 		return err
 	}
+	signingEnc, err := r.box.Encrypt(c.SigningSecret)
+	if err != nil {
+		return err
+	}
+	metaVerifyEnc, metaSecretEnc, err := r.encryptMeta(c)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(ctx, `INSERT INTO clients(id, secret_id, name, webhook_url, plan, rate_limit_per_min, is_active, provider_base_url, provider_api_token_enc, signing_secret_enc, payload_format, provider, meta_verify_token_enc, meta_app_secret_enc, delivery_mode, burst_window_seconds, ordered_delivery, head_of_line_policy)
+        VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18)`, c.ID, c.SecretID, c.Name, c.WebhookURL, c.Plan, c.RateLimitPerMin, c.IsActive, c.ProviderBaseURL, tokenEnc, signingEnc, c.PayloadFormat, c.Provider, metaVerifyEnc, metaSecretEnc, c.DeliveryMode, c.BurstWindowSeconds, c.OrderedDelivery, c.HeadOfLinePolicy); err != nil {
+		return err
+	}
+	c.HasProviderToken = c.ProviderAPIToken != ""
+	c.HasMetaAppSecret = c.MetaAppSecret != ""
 	return nil
 }
 
-func (r *clientRepository) GetByID(ctx context.Context, id string) (*models.Client, error) {
-	row := r.db.QueryRow(ctx, `SELECT id, secret_id, name, webhook_url, plan, rate_limit_per_min, is_active, created_at, updated_at FROM clients WHERE id=$1`, id)
-	var c models.Client
-	if err := row.Scan(&c.ID, &c.SecretID, &c.Name, &c.WebhookURL, &c.Plan, &c.RateLimitPerMin, &c.IsActive, &c.CreatedAt, &c.UpdatedAt); err != nil {
-		return nil, err
+// encryptMeta cifra as credenciais da Meta Cloud API do cliente
+func (r *clientRepository) encryptMeta(c *models.Client) (verifyEnc, secretEnc string, err error) {
+	if verifyEnc, err = r.box.Encrypt(c.MetaVerifyToken); err != nil {
+		return "", "", err
 	}
-	return &c, nil
+	if secretEnc, err = r.box.Encrypt(c.MetaAppSecret); err != nil {
+		return "", "", err
+	}
+	return verifyEnc, secretEnc, nil
+}
+
+func (r *clientRepository) GetByID(ctx context.Context, id string) (*models.Client, error) {
+	return r.scanClient(r.db.QueryRow(ctx, `SELECT `+clientColumns+` FROM clients WHERE id=$1`, id))
 }
 
 func (r *clientRepository) GetBySecretID(ctx context.Context, secretID string) (*models.Client, error) {
-	row := r.db.QueryRow(ctx, `SELECT id, secret_id, name, webhook_url, plan, rate_limit_per_min, is_active, created_at, updated_at FROM clients WHERE secret_id=$1`, secretID)
-	var c models.Client
-	if err := row.Scan(&c.ID, &c.SecretID, &c.Name, &c.WebhookURL, &c.Plan, &c.RateLimitPerMin, &c.IsActive, &c.CreatedAt, &c.UpdatedAt); err != nil {
-		return nil, err
-	}
-	return &c, nil
+	return r.scanClient(r.db.QueryRow(ctx, `SELECT `+clientColumns+` FROM clients WHERE secret_id=$1`, secretID))
 }
 
 func (r *clientRepository) List(ctx context.Context, limit, offset int) ([]models.Client, error) {
 	if limit <= 0 {
 		limit = 20
 	}
-	rows, err := r.db.Query(ctx, `SELECT id, secret_id, name, webhook_url, plan, rate_limit_per_min, is_active, created_at, updated_at FROM clients ORDER BY created_at DESC LIMIT $1 OFFSET $2`, limit, offset)
+	if offset < 0 {
+		offset = 0
+	}
+	rows, err := r.db.Query(ctx, `SELECT `+clientColumns+` FROM clients ORDER BY created_at DESC LIMIT $1 OFFSET $2`, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var out []models.Client
 	for rows.Next() {
-		var c models.Client
-		if err := rows.Scan(&c.ID, &c.SecretID, &c.Name, &c.WebhookURL, &c.Plan, &c.RateLimitPerMin, &c.IsActive, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		c, err := r.scanClient(rows)
+		if err != nil {
 			return nil, err
 		}
-		out = append(out, c)
+		out = append(out, *c)
 	}
 	return out, nil
 }
 
 func (r *clientRepository) Update(ctx context.Context, c *models.Client) error {
-	if _, err := r.db.Exec(ctx, `UPDATE clients SET name=$1, webhook_url=$2, plan=$3, rate_limit_per_min=$4, is_active=$5, updated_at=NOW() WHERE id=$6`, c.Name, c.WebhookURL, c.Plan, c.RateLimitPerMin, c.IsActive, c.ID); err != nil {
+	tokenEnc, err := r.box.Encrypt(c.ProviderAPIToken)
+	if err != nil {
 		return err
 	}
+	metaVerifyEnc, metaSecretEnc, err := r.encryptMeta(c)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(ctx, `UPDATE clients SET name=$1, webhook_url=$2, plan=$3, rate_limit_per_min=$4, is_active=$5, provider_base_url=$6, provider_api_token_enc=$7, payload_format=$8, provider=$9, meta_verify_token_enc=$10, meta_app_secret_enc=$11, delivery_mode=$12, burst_window_seconds=$13, ordered_delivery=$14, head_of_line_policy=$15, updated_at=NOW() WHERE id=$16`, c.Name, c.WebhookURL, c.Plan, c.RateLimitPerMin, c.IsActive, c.ProviderBaseURL, tokenEnc, c.PayloadFormat, c.Provider, metaVerifyEnc, metaSecretEnc, c.DeliveryMode, c.BurstWindowSeconds, c.OrderedDelivery, c.HeadOfLinePolicy, c.ID); err != nil {
+		return err
+	}
+	c.HasProviderToken = c.ProviderAPIToken != ""
+	c.HasMetaAppSecret = c.MetaAppSecret != ""
 	return nil
 }
 
@@ -86,3 +167,42 @@ func (r *clientRepository) Delete(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// RotateSigningSecret gera um novo segredo e mantém o atual como anterior (janela de grace)
+func (r *clientRepository) RotateSigningSecret(ctx context.Context, id string) (string, error) {
+	secret, err := webhooksig.NewSecret()
+	if err != nil {
+		return "", err
+	}
+	enc, err := r.box.Encrypt(secret)
+	if err != nil {
+		return "", err
+	}
+	tag, err := r.db.Exec(ctx, `UPDATE clients SET previous_signing_secret_enc=signing_secret_enc, signing_secret_enc=$1, signing_secret_rotated_at=NOW(), updated_at=NOW() WHERE id=$2`, enc, id)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		return "", pgx.ErrNoRows
+	}
+	return secret, nil
+}
+
+// UpdateFilterRules grava as regras do cliente; nil volta ao comportamento padrão (NULL)
+func (r *clientRepository) UpdateFilterRules(ctx context.Context, id string, rules []models.FilterRule) error {
+	var rulesJSON []byte
+	if rules != nil {
+		var err error
+		if rulesJSON, err = json.Marshal(rules); err != nil {
+			return err
+		}
+	}
+	tag, err := r.db.Exec(ctx, `UPDATE clients SET filter_rules=$1, updated_at=NOW() WHERE id=$2`, rulesJSON, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
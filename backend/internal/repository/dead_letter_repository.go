@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DeadLetterRepository interface {
+	GetByID(ctx context.Context, id string) (*models.DeadLetter, error)
+	List(ctx context.Context, f models.DeadLetterFilter) ([]models.DeadLetter, error)
+	Delete(ctx context.Context, id string) error
+	DeleteMany(ctx context.Context, f models.DeadLetterFilter) (int64, error)
+}
+
+type deadLetterRepository struct{ db *pgxpool.Pool }
+
+func NewDeadLetterRepository(db *pgxpool.Pool) DeadLetterRepository {
+	return &deadLetterRepository{db: db}
+}
+
+const deadLetterColumns = `id, delivery_id, client_id, secret_id, destination_id, target_url, content_type, body, attempts, last_error, last_status, received_at, failed_at`
+
+func scanDeadLetter(row interface{ Scan(dest ...any) error }) (*models.DeadLetter, error) {
+	var dl models.DeadLetter
+	if err := row.Scan(&dl.ID, &dl.DeliveryID, &dl.ClientID, &dl.SecretID, &dl.DestinationID, &dl.TargetURL, &dl.ContentType, &dl.Body, &dl.Attempts, &dl.LastError, &dl.LastStatus, &dl.ReceivedAt, &dl.FailedAt); err != nil {
+		return nil, err
+	}
+	return &dl, nil
+}
+
+// deadLetterWhere monta o WHERE a partir do filtro
+func deadLetterWhere(f models.DeadLetterFilter) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.ClientID != "" {
+		add("client_id=$%d", f.ClientID)
+	}
+	if f.SecretID != "" {
+		add("secret_id=$%d", f.SecretID)
+	}
+	if f.Since != nil {
+		add("failed_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("failed_at < $%d", *f.Until)
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func (r *deadLetterRepository) GetByID(ctx context.Context, id string) (*models.DeadLetter, error) {
+	return scanDeadLetter(r.db.QueryRow(ctx, `SELECT `+deadLetterColumns+` FROM dead_letters WHERE id=$1`, id))
+}
+
+func (r *deadLetterRepository) List(ctx context.Context, f models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	if f.Limit <= 0 {
+		f.Limit = 20
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	where, args := deadLetterWhere(f)
+	args = append(args, f.Limit, f.Offset)
+	q := fmt.Sprintf(`SELECT %s FROM dead_letters%s ORDER BY failed_at DESC LIMIT $%d OFFSET $%d`, deadLetterColumns, where, len(args)-1, len(args))
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.DeadLetter
+	for rows.Next() {
+		dl, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *dl)
+	}
+	return out, rows.Err()
+}
+
+func (r *deadLetterRepository) Delete(ctx context.Context, id string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM dead_letters WHERE id=$1`, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteMany descarta em lote; exige ao menos client_id ou secret_id para não apagar tudo por engano
+func (r *deadLetterRepository) DeleteMany(ctx context.Context, f models.DeadLetterFilter) (int64, error) {
+	if f.ClientID == "" && f.SecretID == "" {
+		return 0, fmt.Errorf("clientId ou secretId é obrigatório")
+	}
+	where, args := deadLetterWhere(f)
+	tag, err := r.db.Exec(ctx, `DELETE FROM dead_letters`+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DeliveryLogRepository interface {
+	Record(ctx context.Context, l *models.DeliveryLog) error
+	List(ctx context.Context, f models.DeliveryLogFilter) ([]models.DeliveryLog, error)
+	Prune(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+type deliveryLogRepository struct{ db *pgxpool.Pool }
+
+func NewDeliveryLogRepository(db *pgxpool.Pool) DeliveryLogRepository {
+	return &deliveryLogRepository{db: db}
+}
+
+const deliveryLogColumns = `id, delivery_id, client_id, secret_id, destination_id, target_url, event_id, chat, attempt, status, status_code, error, latency_ms, response_body, created_at`
+
+func (r *deliveryLogRepository) Record(ctx context.Context, l *models.DeliveryLog) error {
+	row := r.db.QueryRow(ctx, `INSERT INTO delivery_log(delivery_id, client_id, secret_id, destination_id, target_url, event_id, chat, attempt, status, status_code, error, latency_ms, response_body)
+        VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING id, created_at`,
+		l.DeliveryID, l.ClientID, l.SecretID, l.DestinationID, l.TargetURL, l.EventID, l.Chat, l.Attempt, l.Status, l.StatusCode, l.Error, l.LatencyMs, l.ResponseBody)
+	return row.Scan(&l.ID, &l.CreatedAt)
+}
+
+// deliveryLogWhere monta o WHERE a partir do filtro
+func deliveryLogWhere(f models.DeliveryLogFilter) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.ClientID != "" {
+		add("client_id=$%d", f.ClientID)
+	}
+	if f.SecretID != "" {
+		add("secret_id=$%d", f.SecretID)
+	}
+	if f.Chat != "" {
+		add("chat=$%d", f.Chat)
+	}
+	if f.EventID != "" {
+		add("event_id=$%d", f.EventID)
+	}
+	if f.Status != "" {
+		add("status=$%d", f.Status)
+	}
+	if f.StatusCode != 0 {
+		add("status_code=$%d", f.StatusCode)
+	}
+	if f.Since != nil {
+		add("created_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("created_at < $%d", *f.Until)
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func (r *deliveryLogRepository) List(ctx context.Context, f models.DeliveryLogFilter) ([]models.DeliveryLog, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	where, args := deliveryLogWhere(f)
+	args = append(args, f.Limit, f.Offset)
+	q := fmt.Sprintf(`SELECT %s FROM delivery_log%s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, deliveryLogColumns, where, len(args)-1, len(args))
+	rows, err := r.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.DeliveryLog
+	for rows.Next() {
+		var l models.DeliveryLog
+		if err := rows.Scan(&l.ID, &l.DeliveryID, &l.ClientID, &l.SecretID, &l.DestinationID, &l.TargetURL, &l.EventID, &l.Chat, &l.Attempt, &l.Status, &l.StatusCode, &l.Error, &l.LatencyMs, &l.ResponseBody, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// Prune remove registros mais antigos que a retenção
+func (r *deliveryLogRepository) Prune(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM delivery_log WHERE created_at < NOW() - make_interval(secs => $1)`, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DeliveryRepository interface {
+	Enqueue(ctx context.Context, d *models.Delivery) error
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.Delivery, error)
+	GetByID(ctx context.Context, id string) (*models.Delivery, error)
+	RecordAttempt(ctx context.Context, a *models.DeliveryAttempt) error
+	ListAttempts(ctx context.Context, deliveryID string) ([]models.DeliveryAttempt, error)
+	MarkDelivered(ctx context.Context, id string, attempts, statusCode int) error
+	MarkRetry(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, lastErr string, lastStatus int) error
+	MoveToDeadLetter(ctx context.Context, id string, attempts int, lastErr string, lastStatus int) (string, error)
+	Backlog(ctx context.Context) (due int64, oldestDueAt *time.Time, err error)
+	PruneFinished(ctx context.Context, olderThan time.Duration, batch int) (int64, error)
+}
+
+type deliveryRepository struct{ db *pgxpool.Pool }
+
+func NewDeliveryRepository(db *pgxpool.Pool) DeliveryRepository {
+	return &deliveryRepository{db: db}
+}
+
+const deliveryColumns = `id, client_id, secret_id, destination_id, target_url, content_type, body, event_id, chat, request_id, trace_parent, signed, chat_key, event_at, ordering, status, attempts, max_attempts, next_attempt_at, last_error, last_status, created_at, updated_at, delivered_at`
+
+func scanDelivery(row interface{ Scan(dest ...any) error }) (*models.Delivery, error) {
+	var d models.Delivery
+	if err := row.Scan(&d.ID, &d.ClientID, &d.SecretID, &d.DestinationID, &d.TargetURL, &d.ContentType, &d.Body, &d.EventID, &d.Chat, &d.RequestID, &d.TraceParent, &d.Signed, &d.ChatKey, &d.EventAt, &d.Ordering, &d.Status, &d.Attempts, &d.MaxAttempts, &d.NextAttemptAt, &d.LastError, &d.LastStatus, &d.CreatedAt, &d.UpdatedAt, &d.DeliveredAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Enqueue persiste a entrega. Status/NextAttemptAt definidos pelo chamador (ex.: "delivering" com lease p/ envio inline)
+func (r *deliveryRepository) Enqueue(ctx context.Context, d *models.Delivery) error {
+	if d.ID == "" {
+		d.ID = uuid.NewString()
+	}
+	if d.Status == "" {
+		d.Status = models.DeliveryPending
+	}
+	if d.NextAttemptAt.IsZero() {
+		d.NextAttemptAt = time.Now()
+	}
+	row := r.db.QueryRow(ctx, `INSERT INTO outbox(id, client_id, secret_id, destination_id, target_url, content_type, body, status, max_attempts, next_attempt_at, chat_key, event_at, ordering, event_id, chat, trace_parent, request_id, signed)
+        VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,COALESCE($12, NOW()),$13,$14,$15,$16,$17,$18) RETURNING created_at, updated_at`,
+		d.ID, d.ClientID, d.SecretID, d.DestinationID, d.TargetURL, d.ContentType, d.Body, d.Status, d.MaxAttempts, d.NextAttemptAt, d.ChatKey, d.EventAt, d.Ordering, d.EventID, d.Chat, d.TraceParent, d.RequestID, d.Signed)
+	return row.Scan(&d.CreatedAt, &d.UpdatedAt)
+}
+
+// ClaimDue reserva entregas vencidas (inclusive "delivering" com lease expirado após restart).
+// Entregas ordenadas (chat_key) só saem quando são a frente do chat: nenhuma anterior pendente
+// que bloqueie (política block, ou skip ainda sem falha) e nenhuma do mesmo chat em voo.
+func (r *deliveryRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.Delivery, error) {
+	if limit <= 0 {
+		limit = 1
+	}
+	rows, err := r.db.Query(ctx, `UPDATE outbox SET status='delivering', next_attempt_at=NOW()+make_interval(secs => $2), updated_at=NOW()
+        WHERE id IN (
+            SELECT o.id FROM outbox o
+            WHERE o.status IN ('pending','delivering') AND o.next_attempt_at <= NOW()
+              AND (o.chat_key = '' OR NOT EXISTS (
+                  SELECT 1 FROM outbox p
+                  WHERE p.chat_key = o.chat_key AND p.id <> o.id AND p.status IN ('pending','delivering')
+                    AND (
+                        (p.status = 'delivering' AND p.next_attempt_at > NOW())
+                        OR ((p.event_at, p.created_at) < (o.event_at, o.created_at) AND (p.ordering <> 'skip' OR p.attempts = 0))
+                    )
+              ))
+            ORDER BY o.next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING `+deliveryColumns, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *d)
+	}
+	return out, rows.Err()
+}
+
+func (r *deliveryRepository) GetByID(ctx context.Context, id string) (*models.Delivery, error) {
+	return scanDelivery(r.db.QueryRow(ctx, `SELECT `+deliveryColumns+` FROM outbox WHERE id=$1`, id))
+}
+
+func (r *deliveryRepository) RecordAttempt(ctx context.Context, a *models.DeliveryAttempt) error {
+	row := r.db.QueryRow(ctx, `INSERT INTO delivery_attempts(delivery_id, attempt, status_code, error, latency_ms)
+        VALUES($1,$2,$3,$4,$5) RETURNING id, created_at`, a.DeliveryID, a.Attempt, a.StatusCode, a.Error, a.LatencyMs)
+	return row.Scan(&a.ID, &a.CreatedAt)
+}
+
+func (r *deliveryRepository) ListAttempts(ctx context.Context, deliveryID string) ([]models.DeliveryAttempt, error) {
+	rows, err := r.db.Query(ctx, `SELECT id, delivery_id, attempt, status_code, error, latency_ms, created_at FROM delivery_attempts WHERE delivery_id=$1 ORDER BY attempt`, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.DeliveryAttempt
+	for rows.Next() {
+		var a models.DeliveryAttempt
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.Attempt, &a.StatusCode, &a.Error, &a.LatencyMs, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (r *deliveryRepository) MarkDelivered(ctx context.Context, id string, attempts, statusCode int) error {
+	_, err := r.db.Exec(ctx, `UPDATE outbox SET status='delivered', attempts=$2, last_status=$3, last_error='', delivered_at=NOW(), updated_at=NOW() WHERE id=$1`, id, attempts, statusCode)
+	return err
+}
+
+func (r *deliveryRepository) MarkRetry(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, lastErr string, lastStatus int) error {
+	_, err := r.db.Exec(ctx, `UPDATE outbox SET status='pending', attempts=$2, next_attempt_at=$3, last_error=$4, last_status=$5, updated_at=NOW() WHERE id=$1`, id, attempts, nextAttemptAt, lastErr, lastStatus)
+	return err
+}
+
+// MoveToDeadLetter encerra a entrega na outbox e copia o evento original para dead_letters
+func (r *deliveryRepository) MoveToDeadLetter(ctx context.Context, id string, attempts int, lastErr string, lastStatus int) (string, error) {
+	dlID := uuid.NewString()
+	_, err := r.db.Exec(ctx, `WITH moved AS (
+            UPDATE outbox SET status='dead', attempts=$2, last_error=$3, last_status=$4, updated_at=NOW()
+            WHERE id=$1
+            RETURNING id, client_id, secret_id, destination_id, target_url, content_type, body, attempts, last_error, last_status, created_at
+        )
+        INSERT INTO dead_letters(id, delivery_id, client_id, secret_id, destination_id, target_url, content_type, body, attempts, last_error, last_status, received_at)
+        SELECT $5, id, client_id, secret_id, destination_id, target_url, content_type, body, attempts, last_error, last_status, created_at FROM moved`,
+		id, attempts, lastErr, lastStatus, dlID)
+	if err != nil {
+		return "", err
+	}
+	return dlID, nil
+}
+
+// Backlog conta as entregas vencidas aguardando worker e a mais antiga delas (nil com a fila vazia)
+func (r *deliveryRepository) Backlog(ctx context.Context) (int64, *time.Time, error) {
+	var due int64
+	var oldest *time.Time
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*), MIN(next_attempt_at) FROM outbox
+        WHERE status IN ('pending','delivering') AND next_attempt_at <= NOW()`).Scan(&due, &oldest)
+	return due, oldest, err
+}
+
+// PruneFinished remove até batch entregas delivered/dead finalizadas há mais de olderThan;
+// as tentativas saem por cascade. O chamador repete enquanto remover o lote cheio.
+func (r *deliveryRepository) PruneFinished(ctx context.Context, olderThan time.Duration, batch int) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM outbox WHERE id IN (
+            SELECT id FROM outbox
+            WHERE status IN ('delivered','dead') AND updated_at < NOW() - make_interval(secs => $1)
+            LIMIT $2
+        )`, olderThan.Seconds(), batch)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
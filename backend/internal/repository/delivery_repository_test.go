@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/migrations"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+)
+
+// testPool conecta no Postgres de TEST_DATABASE_URL num schema descartável; sem a variável o teste é pulado
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL não definida")
+	}
+	ctx := context.Background()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	if _, err := admin.Exec(ctx, `CREATE SCHEMA `+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(context.Background(), `DROP SCHEMA `+schema+` CASCADE`) })
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	if err := migrations.Run(ctx, pool); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// claimIDs reserva o que estiver vencido e devolve os IDs
+func claimIDs(t *testing.T, r DeliveryRepository) map[string]bool {
+	t.Helper()
+	items, err := r.ClaimDue(context.Background(), 100, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]bool{}
+	for _, d := range items {
+		out[d.ID] = true
+	}
+	return out
+}
+
+func enqueueOrdered(t *testing.T, r DeliveryRepository, id, chatKey string, policy models.HOLPolicy, eventAt time.Time) {
+	t.Helper()
+	d := &models.Delivery{ID: id, SecretID: "s1", TargetURL: "http://x", ContentType: "application/json", Body: []byte(`{}`),
+		MaxAttempts: 5, ChatKey: chatKey, EventAt: &eventAt, Ordering: policy}
+	if err := r.Enqueue(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimDueHeadOfLine(t *testing.T) {
+	pool := testPool(t)
+	r := NewDeliveryRepository(pool)
+	ctx := context.Background()
+	t0 := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+
+	// block: o segundo evento do chat espera o primeiro ser entregue, mesmo com ele em retry
+	enqueueOrdered(t, r, "b2", "s1||chat-b", models.HOLBlock, t0.Add(time.Second))
+	enqueueOrdered(t, r, "b1", "s1||chat-b", models.HOLBlock, t0)
+	// skip: após a primeira falha da frente, o próximo evento é liberado
+	enqueueOrdered(t, r, "s1", "s1||chat-s", models.HOLSkip, t0)
+	enqueueOrdered(t, r, "s2", "s1||chat-s", models.HOLSkip, t0.Add(time.Second))
+	// sem ordenação: sempre elegível
+	if err := r.Enqueue(ctx, &models.Delivery{ID: "u1", SecretID: "s1", TargetURL: "http://x", ContentType: "application/json", Body: []byte(`{}`), MaxAttempts: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := claimIDs(t, r)
+	if len(got) != 3 || !got["b1"] || !got["s1"] || !got["u1"] {
+		t.Fatalf("1ª reserva = %v, want b1, s1, u1", got)
+	}
+	// Frentes em voo seguram os chats
+	if got := claimIDs(t, r); len(got) != 0 {
+		t.Fatalf("reserva com as frentes em voo = %v, want nada", got)
+	}
+
+	// Frentes falham e voltam para a fila com retry no futuro
+	for _, id := range []string{"b1", "s1"} {
+		if err := r.MarkRetry(ctx, id, 1, later, "destino retornou status 503", 503); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := claimIDs(t, r); len(got) != 1 || !got["s2"] {
+		t.Fatalf("reserva após a falha das frentes = %v, want só s2 (block segura b2)", got)
+	}
+
+	// Frente entregue libera o próximo do chat block
+	if err := r.MarkDelivered(ctx, "b1", 2, 200); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimIDs(t, r); len(got) != 1 || !got["b2"] {
+		t.Fatalf("reserva após entregar b1 = %v, want b2", got)
+	}
+
+	// Evento anterior que chega atrasado não passa na frente de um em voo do mesmo chat
+	enqueueOrdered(t, r, "b0", "s1||chat-b", models.HOLBlock, t0.Add(-time.Second))
+	if got := claimIDs(t, r); got["b0"] {
+		t.Fatalf("b0 reservado com b2 em voo: %v", got)
+	}
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DestinationRepository interface {
+	Create(ctx context.Context, dst *models.Destination) error
+	GetByID(ctx context.Context, clientID, id string) (*models.Destination, error)
+	ListByClient(ctx context.Context, clientID string) ([]models.Destination, error)
+	Update(ctx context.Context, dst *models.Destination) error
+	Delete(ctx context.Context, clientID, id string) error
+}
+
+type destinationRepository struct{ db *pgxpool.Pool }
+
+func NewDestinationRepository(db *pgxpool.Pool) DestinationRepository {
+	return &destinationRepository{db: db}
+}
+
+const destinationColumns = `id, client_id, name, url, selector, is_active, created_at, updated_at`
+
+func scanDestination(row interface{ Scan(dest ...any) error }) (*models.Destination, error) {
+	var dst models.Destination
+	var selector []byte
+	if err := row.Scan(&dst.ID, &dst.ClientID, &dst.Name, &dst.URL, &selector, &dst.IsActive, &dst.CreatedAt, &dst.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(selector, &dst.Selector); err != nil {
+		return nil, err
+	}
+	return &dst, nil
+}
+
+func (r *destinationRepository) Create(ctx context.Context, dst *models.Destination) error {
+	if dst.ID == "" {
+		dst.ID = uuid.NewString()
+	}
+	selector, err := json.Marshal(dst.Selector)
+	if err != nil {
+		return err
+	}
+	row := r.db.QueryRow(ctx, `INSERT INTO destinations(id, client_id, name, url, selector, is_active)
+        VALUES($1,$2,$3,$4,$5,$6) RETURNING created_at, updated_at`, dst.ID, dst.ClientID, dst.Name, dst.URL, selector, dst.IsActive)
+	return row.Scan(&dst.CreatedAt, &dst.UpdatedAt)
+}
+
+func (r *destinationRepository) GetByID(ctx context.Context, clientID, id string) (*models.Destination, error) {
+	return scanDestination(r.db.QueryRow(ctx, `SELECT `+destinationColumns+` FROM destinations WHERE client_id=$1 AND id=$2`, clientID, id))
+}
+
+func (r *destinationRepository) ListByClient(ctx context.Context, clientID string) ([]models.Destination, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+destinationColumns+` FROM destinations WHERE client_id=$1 ORDER BY created_at`, clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Destination
+	for rows.Next() {
+		dst, err := scanDestination(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *dst)
+	}
+	return out, rows.Err()
+}
+
+func (r *destinationRepository) Update(ctx context.Context, dst *models.Destination) error {
+	selector, err := json.Marshal(dst.Selector)
+	if err != nil {
+		return err
+	}
+	tag, err := r.db.Exec(ctx, `UPDATE destinations SET name=$1, url=$2, selector=$3, is_active=$4, updated_at=NOW() WHERE client_id=$5 AND id=$6`,
+		dst.Name, dst.URL, selector, dst.IsActive, dst.ClientID, dst.ID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *destinationRepository) Delete(ctx context.Context, clientID, id string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM destinations WHERE client_id=$1 AND id=$2`, clientID, id); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LIDMappingRepository interface {
+	Get(ctx context.Context, lid string) (*models.LIDMapping, error)
+	Upsert(ctx context.Context, m *models.LIDMapping) (bool, error)
+	Delete(ctx context.Context, lid string) error
+}
+
+type lidMappingRepository struct{ db *pgxpool.Pool }
+
+func NewLIDMappingRepository(db *pgxpool.Pool) LIDMappingRepository {
+	return &lidMappingRepository{db: db}
+}
+
+func (r *lidMappingRepository) Get(ctx context.Context, lid string) (*models.LIDMapping, error) {
+	row := r.db.QueryRow(ctx, `SELECT lid, jid, source, created_at, updated_at FROM lid_mappings WHERE lid=$1`, lid)
+	var m models.LIDMapping
+	if err := row.Scan(&m.LID, &m.JID, &m.Source, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Upsert grava o mapeamento; correções manuais só são sobrescritas por outra correção manual.
+// Retorna false quando o registro existente foi preservado.
+func (r *lidMappingRepository) Upsert(ctx context.Context, m *models.LIDMapping) (bool, error) {
+	tag, err := r.db.Exec(ctx, `INSERT INTO lid_mappings(lid, jid, source) VALUES($1,$2,$3)
+        ON CONFLICT (lid) DO UPDATE SET jid=EXCLUDED.jid, source=EXCLUDED.source, updated_at=NOW()
+        WHERE lid_mappings.source <> 'manual' OR EXCLUDED.source = 'manual'`, m.LID, m.JID, m.Source)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *lidMappingRepository) Delete(ctx context.Context, lid string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM lid_mappings WHERE lid=$1`, lid); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ProcessedEventRepository interface {
+	MarkProcessed(ctx context.Context, secretID, messageID string, window time.Duration) (bool, error)
+	Release(ctx context.Context, secretID, messageID string) error
+	Prune(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+type processedEventRepository struct{ db *pgxpool.Pool }
+
+func NewProcessedEventRepository(db *pgxpool.Pool) ProcessedEventRepository {
+	return &processedEventRepository{db: db}
+}
+
+// MarkProcessed registra a mensagem do cliente. Retorna false se já foi vista dentro da janela.
+func (r *processedEventRepository) MarkProcessed(ctx context.Context, secretID, messageID string, window time.Duration) (bool, error) {
+	tag, err := r.db.Exec(ctx, `INSERT INTO processed_events(secret_id, message_id) VALUES($1,$2)
+        ON CONFLICT (secret_id, message_id) DO UPDATE SET seen_at=NOW()
+        WHERE processed_events.seen_at < NOW() - make_interval(secs => $3)`, secretID, messageID, window.Seconds())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Release esquece a mensagem para que o retry do provider seja processado
+func (r *processedEventRepository) Release(ctx context.Context, secretID, messageID string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM processed_events WHERE secret_id=$1 AND message_id=$2`, secretID, messageID)
+	return err
+}
+
+func (r *processedEventRepository) Prune(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM processed_events WHERE seen_at < NOW() - make_interval(secs => $1)`, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
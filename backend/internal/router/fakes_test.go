@@ -0,0 +1,115 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/service"
+)
+
+// stubClients resolve um único cliente, como se viesse do Postgres
+type stubClients struct {
+	service.ClientService
+	client *models.Client
+}
+
+func (s stubClients) GetBySecretID(_ context.Context, secretID string) (*models.Client, error) {
+	if secretID != s.client.SecretID {
+		return nil, nil
+	}
+	cli := *s.client
+	return &cli, nil
+}
+
+func (s stubClients) GetByID(_ context.Context, id string) (*models.Client, error) {
+	if id != s.client.ID {
+		return nil, pgx.ErrNoRows
+	}
+	cli := *s.client
+	return &cli, nil
+}
+
+// memOutbox guarda as entregas em memória; cobre a entrega inline, o retry e o DLQ
+type memOutbox struct {
+	repository.DeliveryRepository
+	// failEnqueue simula a outbox indisponível
+	failEnqueue bool
+
+	mu        sync.Mutex
+	enqueued  []*models.Delivery
+	delivered []string
+	retried   []string
+	dead      []string
+}
+
+func (m *memOutbox) Enqueue(_ context.Context, d *models.Delivery) error {
+	if m.failEnqueue {
+		return errors.New("outbox indisponível")
+	}
+	d.ID = uuid.NewString()
+	m.mu.Lock()
+	m.enqueued = append(m.enqueued, d)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memOutbox) RecordAttempt(context.Context, *models.DeliveryAttempt) error { return nil }
+
+func (m *memOutbox) MarkDelivered(_ context.Context, id string, _, _ int) error {
+	m.mu.Lock()
+	m.delivered = append(m.delivered, id)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memOutbox) MarkRetry(_ context.Context, id string, _ int, _ time.Time, _ string, _ int) error {
+	m.mu.Lock()
+	m.retried = append(m.retried, id)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memOutbox) MoveToDeadLetter(_ context.Context, id string, _ int, _ string, _ int) (string, error) {
+	m.mu.Lock()
+	m.dead = append(m.dead, id)
+	m.mu.Unlock()
+	return "dl-" + id, nil
+}
+
+// countingLIDStore conta as consultas ao store de mapeamentos LID->JID
+type countingLIDStore struct {
+	service.LIDMappingService
+	mu      sync.Mutex
+	lookups int
+	saves   int
+}
+
+func (s *countingLIDStore) Lookup(context.Context, string) (string, bool) {
+	s.mu.Lock()
+	s.lookups++
+	s.mu.Unlock()
+	return "", false
+}
+
+func (s *countingLIDStore) Save(context.Context, string, string, string) {
+	s.mu.Lock()
+	s.saves++
+	s.mu.Unlock()
+}
+
+// mapLIDStore resolve LIDs a partir de um mapa fixo
+type mapLIDStore map[string]string
+
+func (s mapLIDStore) Lookup(_ context.Context, lid string) (string, bool) {
+	jid, ok := s[lid]
+	return jid, ok
+}
+
+func (s mapLIDStore) Save(context.Context, string, string, string) {}
@@ -0,0 +1,244 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/aggregate"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/delivery"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/logging"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
+)
+
+// forwardTarget é um endpoint que recebe o evento; DestinationID vazio = WebhookURL do cliente ou rota de regra
+type forwardTarget struct {
+	DestinationID string
+	Name          string
+	URL           string
+}
+
+// selectTargets escolhe os endpoints do evento. Rota de regra tem prioridade; sem destinos
+// configurados (ou sem nenhum que case), o evento vai para a WebhookURL do cliente.
+func selectTargets(client *models.Client, evt *webhook.EventInfo, routeURL string) []forwardTarget {
+	if routeURL != "" {
+		return []forwardTarget{{Name: "route", URL: routeURL}}
+	}
+	var out []forwardTarget
+	for _, dst := range client.Destinations {
+		if dst.Selector.IsEmpty() || webhook.MatchRule(dst.Selector, evt) {
+			out = append(out, forwardTarget{DestinationID: dst.ID, Name: dst.Name, URL: dst.URL})
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, forwardTarget{Name: "default", URL: client.WebhookURL})
+	}
+	return out
+}
+
+func newDelivery(client *models.Client, t forwardTarget, evt *webhook.EventInfo, contentType string, body []byte) *models.Delivery {
+	del := &models.Delivery{
+		ClientID:      client.ID,
+		SecretID:      client.SecretID,
+		DestinationID: t.DestinationID,
+		TargetURL:     t.URL,
+		ContentType:   contentType,
+		Body:          body,
+	}
+	if evt != nil {
+		del.EventID, del.Chat = evt.ID, evt.Chat
+	}
+	return del
+}
+
+// outcome é a resposta do pipeline para um evento; Body preenchido repassa a resposta do destino
+type outcome struct {
+	Code        int
+	JSON        gin.H
+	ContentType string
+	Body        []byte
+	// Stored indica que o evento foi entregue ou ficou registrado (outbox ou DLQ) em algum destino;
+	// false libera o dedup para o retry do provider
+	Stored bool
+}
+
+// stored indica se a entrega foi feita ou persistida; failed é tentativa sem outbox que falhou
+func stored(res *delivery.Result) bool {
+	return res.Status != models.DeliveryFailed
+}
+
+func (o outcome) write(c *gin.Context) {
+	if o.JSON == nil {
+		c.Data(o.Code, o.ContentType, o.Body)
+		return
+	}
+	c.JSON(o.Code, o.JSON)
+}
+
+// summary resume o resultado para respostas com vários eventos
+func (o outcome) summary() gin.H {
+	h := gin.H{"code": o.Code}
+	for k, v := range o.JSON {
+		h[k] = v
+	}
+	if o.JSON == nil {
+		h["status"] = "forwarded"
+	}
+	return h
+}
+
+// applyOrdering marca a entrega para sair em ordem na fila do chat (por cliente e destino)
+func applyOrdering(del *models.Delivery, client *models.Client, eventAt *time.Time) {
+	if !client.OrderedDelivery || del.Chat == "" {
+		return
+	}
+	del.ChatKey = client.SecretID + "|" + del.DestinationID + "|" + del.Chat
+	del.EventAt = eventAt
+	del.Ordering = client.HeadOfLinePolicy
+	if del.Ordering == "" {
+		del.Ordering = models.HOLBlock
+	}
+}
+
+// forwardOne entrega a um único destino e repassa a resposta dele ao provider
+func forwardOne(c *gin.Context, d Dependencies, client *models.Client, t forwardTarget, evt *webhook.EventInfo, contentType string, body []byte) outcome {
+	// Grava na outbox e faz a primeira tentativa inline; falhas transitórias seguem para os workers
+	del := newDelivery(client, t, evt, contentType, body)
+	res := d.Dispatcher.DeliverNow(c.Request.Context(), del)
+	switch {
+	case res.Status == models.DeliveryPending:
+		logging.FromContext(c.Request.Context()).Warn("Erro ao encaminhar (retry agendado)", "delivery_id", del.ID, "status", res.StatusCode, "err", res.Err)
+		return outcome{Code: http.StatusAccepted, JSON: gin.H{"status": "queued", "deliveryId": del.ID}, Stored: true}
+	case res.Err != nil:
+		logging.FromContext(c.Request.Context()).Error("Erro ao encaminhar", "delivery_id", del.ID, "err", res.Err)
+		d.Notifier.NotifyContext(c.Request.Context(), fmt.Sprintf(":warning: Forward falhou para %s | secretId=%s | err=%v", t.URL, client.SecretID, res.Err))
+		return outcome{Code: http.StatusBadGateway, JSON: gin.H{"error": "destino indisponível"}, Stored: stored(res)}
+	default:
+		// Repassa a resposta do destino (sucesso ou erro definitivo, já no DLQ)
+		return outcome{Code: res.StatusCode, ContentType: res.ContentType, Body: res.Body, Stored: stored(res)}
+	}
+}
+
+// forwardMany entrega em paralelo a vários destinos; cada entrega é rastreada separadamente.
+// Responde 200 se todas foram entregues, 202 se alguma ficou na fila e 502 se nenhuma saiu.
+func forwardMany(c *gin.Context, d Dependencies, client *models.Client, targets []forwardTarget, evt *webhook.EventInfo, contentType string, body []byte) outcome {
+	type destOutcome struct {
+		Destination string `json:"destination"`
+		DeliveryID  string `json:"deliveryId"`
+		Status      string `json:"status"`
+		StatusCode  int    `json:"statusCode,omitempty"`
+	}
+	results := make([]destOutcome, len(targets))
+	raw := make([]*delivery.Result, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t forwardTarget) {
+			defer wg.Done()
+			del := newDelivery(client, t, evt, contentType, body)
+			res := d.Dispatcher.DeliverNow(c.Request.Context(), del)
+			raw[i] = res
+			results[i] = destOutcome{Destination: t.Name, DeliveryID: del.ID, Status: string(res.Status), StatusCode: res.StatusCode}
+		}(i, t)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	failed := 0
+	anyStored := false
+	for i, res := range raw {
+		anyStored = anyStored || stored(res)
+		switch res.Status {
+		case models.DeliveryPending:
+			if code == http.StatusOK {
+				code = http.StatusAccepted
+			}
+		case models.DeliveryDelivered:
+		default:
+			failed++
+			if res.Err != nil {
+				logging.FromContext(c.Request.Context()).Error("Erro ao encaminhar", "destination", targets[i].Name, "err", res.Err)
+				d.Notifier.NotifyContext(c.Request.Context(), fmt.Sprintf(":warning: Forward falhou para %s | secretId=%s | err=%v", targets[i].URL, client.SecretID, res.Err))
+			}
+		}
+	}
+	if failed == len(targets) {
+		code = http.StatusBadGateway
+	}
+	return outcome{Code: code, JSON: gin.H{"status": "forwarded", "deliveries": results}, Stored: anyStored}
+}
+
+// enqueueAll (modo async) grava as entregas na outbox e responde 202 sem esperar os destinos;
+// os workers do dispatcher fazem a entrega. Se a outbox falhar, aquele destino é entregue inline.
+func enqueueAll(c *gin.Context, d Dependencies, client *models.Client, targets []forwardTarget, evt *webhook.EventInfo, contentType string, body []byte, eventAt *time.Time) outcome {
+	results := make([]gin.H, 0, len(targets))
+	anyStored := false
+	for _, t := range targets {
+		del := newDelivery(client, t, evt, contentType, body)
+		applyOrdering(del, client, eventAt)
+		status := "queued"
+		if err := d.Dispatcher.Enqueue(c.Request.Context(), del); err != nil {
+			logging.FromContext(c.Request.Context()).Error("Erro ao enfileirar (modo async), entregando inline", "destination", t.Name, "err", err)
+			res := d.Dispatcher.DeliverNow(c.Request.Context(), del)
+			status = string(res.Status)
+			anyStored = anyStored || stored(res)
+		} else {
+			anyStored = true
+		}
+		results = append(results, gin.H{"destination": t.Name, "deliveryId": del.ID, "status": status})
+	}
+	return outcome{Code: http.StatusAccepted, JSON: gin.H{"status": "accepted", "deliveries": results}, Stored: anyStored}
+}
+
+// burstable indica mensagens recebidas com conteúdo (texto/mídia); recibos e envios próprios não agregam
+func burstable(evt *webhook.EventInfo, normalized *webhook.Event) bool {
+	if evt == nil || normalized == nil || evt.IsFromMe || evt.Chat == "" {
+		return false
+	}
+	return normalized.Text != "" || normalized.Media != nil
+}
+
+// flushBurst enfileira a rajada consolidada para os destinos do cliente. Erro faz o agregador
+// tentar de novo a rajada inteira (destinos já enfileirados podem receber em duplicidade).
+func flushBurst(d Dependencies, b aggregate.Batch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	logger := d.Logger.With("secret_id", b.SecretID, "chat", b.Chat)
+	client, ok := d.Resolver.Resolve(ctx, b.SecretID)
+	if !ok {
+		return errors.New("cliente não encontrado")
+	}
+	body, err := json.Marshal(gin.H{
+		"type":   "MessageBurst",
+		"chat":   b.Chat,
+		"sender": b.Sender,
+		"count":  len(b.Events),
+		"events": b.Events,
+	})
+	if err != nil {
+		return fmt.Errorf("serializar rajada: %w", err)
+	}
+	var failed error
+	for _, t := range selectTargets(client, b.Info, b.RouteURL) {
+		del := newDelivery(client, t, b.Info, "application/json", body)
+		// A rajada não tem um evento único: o log registra só o chat
+		del.EventID, del.Chat = "", b.Chat
+		applyOrdering(del, client, b.EventAt)
+		if err := d.Dispatcher.Enqueue(ctx, del); err != nil {
+			logger.Error("Erro ao enfileirar rajada, entregando inline", "destination", t.Name, "err", err)
+			if res := d.Dispatcher.DeliverNow(ctx, del); !stored(res) {
+				failed = fmt.Errorf("rajada não registrada para %s: %w", t.Name, err)
+			}
+			continue
+		}
+		logger.Info("burst_flushed", "count", len(b.Events), "delivery_id", del.ID)
+	}
+	return failed
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/delivery"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
+)
+
+func TestApplyOrdering(t *testing.T) {
+	at := time.Date(2024, 5, 10, 13, 45, 12, 0, time.UTC)
+	tests := []struct {
+		name     string
+		client   models.Client
+		chat     string
+		dst      string
+		key      string
+		ordering models.HOLPolicy
+	}{
+		{"sem entrega ordenada", models.Client{SecretID: "s1"}, "5511@s.whatsapp.net", "", "", ""},
+		{"sem chat", models.Client{SecretID: "s1", OrderedDelivery: true}, "", "", "", ""},
+		{"política padrão block", models.Client{SecretID: "s1", OrderedDelivery: true}, "5511@s.whatsapp.net", "", "s1||5511@s.whatsapp.net", models.HOLBlock},
+		{"política skip", models.Client{SecretID: "s1", OrderedDelivery: true, HeadOfLinePolicy: models.HOLSkip}, "5511@s.whatsapp.net", "", "s1||5511@s.whatsapp.net", models.HOLSkip},
+		{"fila por destino", models.Client{SecretID: "s1", OrderedDelivery: true}, "5511@s.whatsapp.net", "d1", "s1|d1|5511@s.whatsapp.net", models.HOLBlock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			del := &models.Delivery{DestinationID: tt.dst, Chat: tt.chat}
+			applyOrdering(del, &tt.client, &at)
+			if del.ChatKey != tt.key || del.Ordering != tt.ordering {
+				t.Fatalf("chatKey=%q ordering=%q, want %q %q", del.ChatKey, del.Ordering, tt.key, tt.ordering)
+			}
+			if tt.key != "" && (del.EventAt == nil || !del.EventAt.Equal(at)) {
+				t.Fatalf("eventAt = %v, want %v", del.EventAt, at)
+			}
+			if tt.key == "" && del.EventAt != nil {
+				t.Fatal("eventAt preenchido sem ordenação")
+			}
+		})
+	}
+}
+
+// O chat_key usa o JID convertido: o mesmo contato chegando como LID ou JID cai na mesma fila
+func TestOrderingUsesConvertedChat(t *testing.T) {
+	const lid, jid = "112233445566778@lid", "5511987654321@s.whatsapp.net"
+	var hits atomic.Int32
+	client := &models.Client{
+		ID:               "c1",
+		SecretID:         testSecretID,
+		WebhookURL:       okDestination(t, &hits),
+		ProviderAPIToken: "tok",
+		OrderedDelivery:  true,
+		IsActive:         true,
+	}
+	outbox := &memOutbox{}
+	r := newWebhookRouterWith(t, client, outbox, delivery.Options{}, func(d *Dependencies) {
+		d.LIDConverters = webhook.NewLIDConverters(mapLIDStore{lid: jid}, nil, nil)
+	})
+
+	for i, chat := range []string{lid, jid} {
+		jsonData := `{"type":"Message","event":{"Info":{"Chat":"` + chat + `","Sender":"` + chat + `","IsFromMe":false,"IsGroup":false,"ID":"MSG` + strconv.Itoa(i) + `","Type":"text","Timestamp":"2024-05-10T13:45:12-03:00"},"Message":{"conversation":"oi"}}}`
+		if rec := postEvent(r, url.Values{"jsonData": {jsonData}}.Encode()); rec.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+		}
+	}
+	if len(outbox.enqueued) != 2 {
+		t.Fatalf("enfileiradas = %d, want 2", len(outbox.enqueued))
+	}
+	want := testSecretID + "||" + jid
+	for i, del := range outbox.enqueued {
+		if del.ChatKey != want || del.Chat != jid {
+			t.Fatalf("entrega %d: chatKey=%q chat=%q, want %q", i, del.ChatKey, del.Chat, want)
+		}
+	}
+}
+
+// Regras de chat/sender casam com o JID convertido, não com o LID recebido
+func TestRulesUseConvertedChat(t *testing.T) {
+	const lid, jid = "112233445566778@lid", "5511987654321@s.whatsapp.net"
+	var hits atomic.Int32
+	client := &models.Client{
+		ID:               "c1",
+		SecretID:         testSecretID,
+		WebhookURL:       okDestination(t, &hits),
+		ProviderAPIToken: "tok",
+		FilterRules:      []models.FilterRule{{Name: "bloqueado", Match: models.RuleMatch{Chat: "5511987654321@*"}, Action: models.RuleDrop}},
+		IsActive:         true,
+	}
+	r := newWebhookRouterWith(t, client, &memOutbox{}, delivery.Options{}, func(d *Dependencies) {
+		d.LIDConverters = webhook.NewLIDConverters(mapLIDStore{lid: jid}, nil, nil)
+	})
+
+	jsonData := `{"type":"Message","event":{"Info":{"Chat":"` + lid + `","Sender":"` + lid + `","IsFromMe":false,"IsGroup":false,"ID":"MSG1","Type":"text"},"Message":{"conversation":"oi"}}}`
+	rec := postEvent(r, url.Values{"jsonData": {jsonData}}.Encode())
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ignored_by_rule") {
+		t.Fatalf("status = %d, body = %s; want descartado pela regra", rec.Code, rec.Body.String())
+	}
+	if hits.Load() != 0 {
+		t.Fatal("evento encaminhado apesar da regra")
+	}
+}
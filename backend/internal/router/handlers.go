@@ -1,36 +1,71 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/logging"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/service"
 )
 
 // ---- Handlers de Client ----
 
 func createClient(c *gin.Context, d Dependencies) {
+	reveal, ok := revealSecrets(c)
+	if !ok {
+		return
+	}
 	var in struct {
-		Name            string      `json:"name"`
-		SecretID        string      `json:"secretId"`
-		WebhookURL      string      `json:"webhookUrl"`
-		Plan            models.Plan `json:"plan"`
-		RateLimitPerMin int         `json:"rateLimitPerMin"`
-		IsActive        *bool       `json:"isActive"`
+		Name             string      `json:"name"`
+		SecretID         string      `json:"secretId"`
+		WebhookURL       string      `json:"webhookUrl"`
+		Plan             models.Plan `json:"plan"`
+		RateLimitPerMin  int         `json:"rateLimitPerMin"`
+		IsActive         *bool       `json:"isActive"`
+		ProviderBaseURL  string      `json:"providerBaseUrl"`
+		ProviderAPIToken string      `json:"providerApiToken"`
+		// raw (padrão) ou normalized
+		PayloadFormat models.PayloadFormat `json:"payloadFormat"`
+		// wuzapi (padrão), evolution, zapi ou meta
+		Provider string `json:"provider"`
+		// Meta Cloud API: token do handshake GET e app secret da assinatura
+		MetaVerifyToken string `json:"metaVerifyToken"`
+		MetaAppSecret   string `json:"metaAppSecret"`
+		// sync (padrão) ou async
+		DeliveryMode models.DeliveryMode `json:"deliveryMode"`
+		// 0 (padrão) desativa a agregação de rajadas
+		BurstWindowSeconds int `json:"burstWindowSeconds"`
+		// Entrega ordenada por chat; política block (padrão) ou skip
+		OrderedDelivery  bool             `json:"orderedDelivery"`
+		HeadOfLinePolicy models.HOLPolicy `json:"headOfLinePolicy"`
 	}
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "payload inválido"})
 		return
 	}
 	client := &models.Client{
-		SecretID:        in.SecretID,
-		Name:            in.Name,
-		WebhookURL:      in.WebhookURL,
-		Plan:            in.Plan,
-		RateLimitPerMin: in.RateLimitPerMin,
-		IsActive:        true,
+		SecretID:           in.SecretID,
+		Name:               in.Name,
+		WebhookURL:         in.WebhookURL,
+		Plan:               in.Plan,
+		RateLimitPerMin:    in.RateLimitPerMin,
+		IsActive:           true,
+		ProviderBaseURL:    in.ProviderBaseURL,
+		ProviderAPIToken:   in.ProviderAPIToken,
+		PayloadFormat:      in.PayloadFormat,
+		Provider:           in.Provider,
+		MetaVerifyToken:    in.MetaVerifyToken,
+		MetaAppSecret:      in.MetaAppSecret,
+		DeliveryMode:       in.DeliveryMode,
+		BurstWindowSeconds: in.BurstWindowSeconds,
+		OrderedDelivery:    in.OrderedDelivery,
+		HeadOfLinePolicy:   in.HeadOfLinePolicy,
 	}
 	if in.IsActive != nil {
 		client.IsActive = *in.IsActive
@@ -39,63 +74,187 @@ func createClient(c *gin.Context, d Dependencies) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"client": client})
+	if !reveal {
+		client.SecretID = maskSecret(client.SecretID)
+	}
+	// O segredo de assinatura só é exibido na criação e na rotação
+	c.JSON(http.StatusCreated, gin.H{"client": client, "signingSecret": client.SigningSecret})
+}
+
+func rotateSigningSecret(c *gin.Context, d Dependencies) {
+	id := c.Param("id")
+	secret, err := d.ClientSvc.RotateSigningSecret(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	invalidateClientCache(c, d, id)
+	c.JSON(http.StatusOK, gin.H{
+		"signingSecret":      secret,
+		"previousValidUntil": time.Now().Add(d.Config.SigningSecretGrace),
+	})
 }
 
 func listClients(c *gin.Context, d Dependencies) {
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, offset, err := parsePage(c, 20, 100)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reveal, ok := revealSecrets(c)
+	if !ok {
+		return
+	}
 	items, err := d.ClientSvc.List(c.Request.Context(), limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "erro ao listar"})
 		return
 	}
+	if !reveal {
+		for i := range items {
+			items[i].SecretID = maskSecret(items[i].SecretID)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"items": items})
 }
 
+// maskSecret mantém só as pontas do secretId, o suficiente para identificar o cliente nas respostas
+func maskSecret(secretID string) string {
+	if len(secretID) <= 8 {
+		return strings.Repeat("*", len(secretID))
+	}
+	return secretID[:4] + "****" + secretID[len(secretID)-4:]
+}
+
+// revealSecrets trata ?includeSecrets=true nas respostas com secretId: exige o escopo resolve e
+// responde 403 sem ele. ok=false indica que a resposta já foi enviada.
+func revealSecrets(c *gin.Context) (reveal, ok bool) {
+	if include, _ := strconv.ParseBool(c.Query("includeSecrets")); !include {
+		return false, true
+	}
+	if !requestAPIKey(c).HasScope(models.ScopeResolve) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "includeSecrets requer o escopo resolve", "requiredScope": models.ScopeResolve})
+		return false, false
+	}
+	return true, true
+}
+
 func getClient(c *gin.Context, d Dependencies) {
+	reveal, ok := revealSecrets(c)
+	if !ok {
+		return
+	}
 	id := c.Param("id")
 	cli, err := d.ClientSvc.GetByID(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
 		return
 	}
+	if !reveal {
+		cli.SecretID = maskSecret(cli.SecretID)
+	}
 	c.JSON(http.StatusOK, gin.H{"client": cli})
 }
 
+// updateClient aplica só os campos enviados; os omitidos mantêm o valor atual
 func updateClient(c *gin.Context, d Dependencies) {
+	reveal, ok := revealSecrets(c)
+	if !ok {
+		return
+	}
 	id := c.Param("id")
 	var in struct {
-		Name            string      `json:"name"`
-		WebhookURL      string      `json:"webhookUrl"`
-		Plan            models.Plan `json:"plan"`
-		RateLimitPerMin int         `json:"rateLimitPerMin"`
-		IsActive        *bool       `json:"isActive"`
+		// nil mantém o valor atual
+		Name            *string      `json:"name"`
+		WebhookURL      *string      `json:"webhookUrl"`
+		Plan            *models.Plan `json:"plan"`
+		RateLimitPerMin *int         `json:"rateLimitPerMin"`
+		IsActive        *bool        `json:"isActive"`
+		ProviderBaseURL *string      `json:"providerBaseUrl"`
+		// nil mantém o token atual; "" remove
+		ProviderAPIToken *string `json:"providerApiToken"`
+		// vazio mantém o formato/provider/modo atual
+		PayloadFormat models.PayloadFormat `json:"payloadFormat"`
+		Provider      string               `json:"provider"`
+		DeliveryMode  models.DeliveryMode  `json:"deliveryMode"`
+		// nil mantém a janela/ordenação atual
+		BurstWindowSeconds *int             `json:"burstWindowSeconds"`
+		OrderedDelivery    *bool            `json:"orderedDelivery"`
+		HeadOfLinePolicy   models.HOLPolicy `json:"headOfLinePolicy"`
+		// nil mantém o valor atual; "" remove
+		MetaVerifyToken *string `json:"metaVerifyToken"`
+		MetaAppSecret   *string `json:"metaAppSecret"`
 	}
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "payload inválido"})
 		return
 	}
-	cli := &models.Client{
-		ID:              id,
-		Name:            in.Name,
-		WebhookURL:      in.WebhookURL,
-		Plan:            in.Plan,
-		RateLimitPerMin: in.RateLimitPerMin,
-		IsActive:        true,
+	existing, err := d.ClientSvc.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	cli := *existing
+	if in.Name != nil {
+		cli.Name = *in.Name
+	}
+	if in.WebhookURL != nil {
+		cli.WebhookURL = *in.WebhookURL
+	}
+	if in.Plan != nil {
+		cli.Plan = *in.Plan
+	}
+	if in.RateLimitPerMin != nil {
+		cli.RateLimitPerMin = *in.RateLimitPerMin
 	}
 	if in.IsActive != nil {
 		cli.IsActive = *in.IsActive
 	}
-	if err := d.ClientSvc.Update(c.Request.Context(), cli); err != nil {
+	if in.ProviderBaseURL != nil {
+		cli.ProviderBaseURL = *in.ProviderBaseURL
+	}
+	if in.ProviderAPIToken != nil {
+		cli.ProviderAPIToken = *in.ProviderAPIToken
+	}
+	if in.PayloadFormat != "" {
+		cli.PayloadFormat = in.PayloadFormat
+	}
+	if in.Provider != "" {
+		cli.Provider = in.Provider
+	}
+	if in.DeliveryMode != "" {
+		cli.DeliveryMode = in.DeliveryMode
+	}
+	if in.BurstWindowSeconds != nil {
+		cli.BurstWindowSeconds = *in.BurstWindowSeconds
+	}
+	if in.OrderedDelivery != nil {
+		cli.OrderedDelivery = *in.OrderedDelivery
+	}
+	if in.HeadOfLinePolicy != "" {
+		cli.HeadOfLinePolicy = in.HeadOfLinePolicy
+	}
+	if in.MetaVerifyToken != nil {
+		cli.MetaVerifyToken = *in.MetaVerifyToken
+	}
+	if in.MetaAppSecret != nil {
+		cli.MetaAppSecret = *in.MetaAppSecret
+	}
+	if err := d.ClientSvc.Update(c.Request.Context(), &cli); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// Limite/plano/token novos valem imediatamente no data-plane
+	d.Resolver.Invalidate(existing.SecretID)
+	if !reveal {
+		cli.SecretID = maskSecret(cli.SecretID)
+	}
 	c.JSON(http.StatusOK, gin.H{"client": cli})
 }
 
 func deleteClient(c *gin.Context, d Dependencies) {
 	id := c.Param("id")
+	invalidateClientCache(c, d, id)
 	if err := d.ClientSvc.Delete(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -106,8 +265,8 @@ func deleteClient(c *gin.Context, d Dependencies) {
 func resolveBySecret(c *gin.Context, d Dependencies) {
 	secretID := c.Param("secretId")
 	// ENV primeiro
-	if url, ok := resolveClientWebhookURLFromEnv(secretID); ok {
-		c.JSON(http.StatusOK, models.ResolveResponse{WebhookURL: url, RateLimitPerMin: 60, Plan: models.PlanFREE})
+	if cli, ok := service.ClientFromEnv(secretID); ok {
+		c.JSON(http.StatusOK, models.ResolveResponse{WebhookURL: cli.WebhookURL, RateLimitPerMin: cli.RateLimitPerMin, Plan: cli.Plan})
 		return
 	}
 	cli, err := d.ClientSvc.GetBySecretID(c.Request.Context(), secretID)
@@ -121,3 +280,470 @@ func resolveBySecret(c *gin.Context, d Dependencies) {
 		Plan:            cli.Plan,
 	})
 }
+
+// ---- Handlers de regras de filtro ----
+
+func getFilterRules(c *gin.Context, d Dependencies) {
+	cli, err := d.ClientSvc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": cli.EffectiveFilterRules(), "isDefault": cli.FilterRules == nil})
+}
+
+// putFilterRules substitui as regras; "rules": null volta ao padrão (descartar grupos)
+func putFilterRules(c *gin.Context, d Dependencies) {
+	id := c.Param("id")
+	var in struct {
+		Rules []models.FilterRule `json:"rules"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload inválido"})
+		return
+	}
+	if err := d.ClientSvc.UpdateFilterRules(c.Request.Context(), id, in.Rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	invalidateClientCache(c, d, id)
+	getFilterRules(c, d)
+}
+
+// ---- Handlers de Destinations ----
+
+func listDestinations(c *gin.Context, d Dependencies) {
+	items, err := d.Destinations.ListByClient(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func createDestination(c *gin.Context, d Dependencies) {
+	id := c.Param("id")
+	var in struct {
+		Name     string           `json:"name"`
+		URL      string           `json:"url"`
+		Selector models.RuleMatch `json:"selector"`
+		IsActive *bool            `json:"isActive"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload inválido"})
+		return
+	}
+	if _, err := d.ClientSvc.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	dst := &models.Destination{ClientID: id, Name: in.Name, URL: in.URL, Selector: in.Selector, IsActive: true}
+	if in.IsActive != nil {
+		dst.IsActive = *in.IsActive
+	}
+	if err := d.Destinations.Create(c.Request.Context(), dst); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	invalidateClientCache(c, d, id)
+	c.JSON(http.StatusCreated, gin.H{"destination": dst})
+}
+
+func updateDestination(c *gin.Context, d Dependencies) {
+	id := c.Param("id")
+	var in struct {
+		Name     *string           `json:"name"`
+		URL      *string           `json:"url"`
+		Selector *models.RuleMatch `json:"selector"`
+		IsActive *bool             `json:"isActive"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload inválido"})
+		return
+	}
+	dst, err := d.Destinations.GetByID(c.Request.Context(), id, c.Param("destId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	if in.Name != nil {
+		dst.Name = *in.Name
+	}
+	if in.URL != nil {
+		dst.URL = *in.URL
+	}
+	if in.Selector != nil {
+		dst.Selector = *in.Selector
+	}
+	if in.IsActive != nil {
+		dst.IsActive = *in.IsActive
+	}
+	if err := d.Destinations.Update(c.Request.Context(), dst); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	invalidateClientCache(c, d, id)
+	c.JSON(http.StatusOK, gin.H{"destination": dst})
+}
+
+func deleteDestination(c *gin.Context, d Dependencies) {
+	id := c.Param("id")
+	if err := d.Destinations.Delete(c.Request.Context(), id, c.Param("destId")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	invalidateClientCache(c, d, id)
+	c.Status(http.StatusNoContent)
+}
+
+// ---- Handlers de Delivery ----
+
+func getDelivery(c *gin.Context, d Dependencies) {
+	reveal, ok := revealSecrets(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	del, err := d.Deliveries.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	attempts, err := d.Deliveries.ListAttempts(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "erro ao listar tentativas"})
+		return
+	}
+	if !reveal {
+		del.SecretID = maskSecret(del.SecretID)
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery": del, "attempts": attempts})
+}
+
+// ---- Handlers do log de entregas ----
+
+// listDeliveryLog busca tentativas (filtros: clientId, secretId, chat, eventId, status, statusCode, since, until, limit, offset)
+func listDeliveryLog(c *gin.Context, d Dependencies) {
+	f := models.DeliveryLogFilter{
+		ClientID: c.Query("clientId"),
+		SecretID: c.Query("secretId"),
+		Chat:     c.Query("chat"),
+		EventID:  c.Query("eventId"),
+		Status:   models.DeliveryStatus(c.Query("status")),
+	}
+	if id := c.Param("id"); id != "" {
+		f.ClientID = id
+	}
+	if v := c.Query("statusCode"); v != "" {
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "statusCode inválido"})
+			return
+		}
+		f.StatusCode = code
+	}
+	var err error
+	if f.Limit, f.Offset, err = parsePage(c, 50, 500); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for param, dst := range map[string]**time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " inválido (use RFC3339)"})
+				return
+			}
+			*dst = &t
+		}
+	}
+	reveal, ok := revealSecrets(c)
+	if !ok {
+		return
+	}
+	items, err := d.DeliveryLog.List(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "erro ao listar"})
+		return
+	}
+	if !reveal {
+		for i := range items {
+			items[i].SecretID = maskSecret(items[i].SecretID)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// ---- Handlers de Dead Letters ----
+
+// parsePage lê limit (1..max, acima de max é reduzido) e offset (>= 0) da query
+func parsePage(c *gin.Context, defLimit, maxLimit int) (limit, offset int, err error) {
+	limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defLimit)))
+	if err != nil || limit < 1 {
+		return 0, 0, fmt.Errorf("limit inválido (inteiro a partir de 1)")
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("offset inválido (inteiro a partir de 0)")
+	}
+	return limit, offset, nil
+}
+
+// parseDeadLetterFilter lê clientId/secretId/since/until (RFC3339)/limit/offset da query
+func parseDeadLetterFilter(c *gin.Context) (models.DeadLetterFilter, error) {
+	f := models.DeadLetterFilter{
+		ClientID: c.Query("clientId"),
+		SecretID: c.Query("secretId"),
+	}
+	var err error
+	if f.Limit, f.Offset, err = parsePage(c, 20, 500); err != nil {
+		return f, err
+	}
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, fmt.Errorf("since inválido (use RFC3339)")
+		}
+		f.Since = &t
+	}
+	if v := c.Query("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, fmt.Errorf("until inválido (use RFC3339)")
+		}
+		f.Until = &t
+	}
+	return f, nil
+}
+
+func listDeadLetters(c *gin.Context, d Dependencies) {
+	f, err := parseDeadLetterFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if id := c.Param("id"); id != "" {
+		f.ClientID = id
+	}
+	reveal, ok := revealSecrets(c)
+	if !ok {
+		return
+	}
+	items, err := d.DeadLetters.List(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "erro ao listar"})
+		return
+	}
+	if !reveal {
+		for i := range items {
+			items[i].SecretID = maskSecret(items[i].SecretID)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func getDeadLetter(c *gin.Context, d Dependencies) {
+	reveal, ok := revealSecrets(c)
+	if !ok {
+		return
+	}
+	dl, err := d.DeadLetters.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	if !reveal {
+		dl.SecretID = maskSecret(dl.SecretID)
+	}
+	c.JSON(http.StatusOK, gin.H{"deadLetter": dl, "body": string(dl.Body)})
+}
+
+func replayDeadLetter(c *gin.Context, d Dependencies) {
+	reveal, ok := revealSecrets(c)
+	if !ok {
+		return
+	}
+	dl, err := d.DeadLetters.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	del, err := replayOne(c, d, dl)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !reveal {
+		del.SecretID = maskSecret(del.SecretID)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"delivery": del})
+}
+
+func replayDeadLetters(c *gin.Context, d Dependencies) {
+	f, err := parseDeadLetterFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if id := c.Param("id"); id != "" {
+		f.ClientID = id
+	}
+	if f.ClientID == "" && f.SecretID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "clientId ou secretId é obrigatório"})
+		return
+	}
+	items, err := d.DeadLetters.List(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "erro ao listar"})
+		return
+	}
+	replayed := make([]string, 0, len(items))
+	failed := map[string]string{}
+	for i := range items {
+		if _, err := replayOne(c, d, &items[i]); err != nil {
+			failed[items[i].ID] = err.Error()
+			continue
+		}
+		replayed = append(replayed, items[i].ID)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"replayed": replayed, "failed": failed})
+}
+
+// replayOne reenfileira o dead letter para a WebhookURL atual do cliente e o remove do DLQ
+func replayOne(c *gin.Context, d Dependencies, dl *models.DeadLetter) (*models.Delivery, error) {
+	ctx := c.Request.Context()
+	var cli *models.Client
+	if dl.ClientID != "" {
+		cli, _ = d.ClientSvc.GetByID(ctx, dl.ClientID)
+	} else {
+		cli, _ = service.ClientFromEnv(dl.SecretID)
+	}
+	if cli == nil || !cli.IsActive {
+		return nil, fmt.Errorf("cliente não encontrado ou inativo")
+	}
+	// Entregas de um destino voltam para a URL atual dele; destino removido/inativo cai na WebhookURL
+	target := cli.WebhookURL
+	if dl.DestinationID != "" {
+		if dst, err := d.Destinations.GetByID(ctx, cli.ID, dl.DestinationID); err == nil && dst.IsActive {
+			target = dst.URL
+		}
+	}
+	del, err := d.Dispatcher.Replay(ctx, dl, target)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao reenfileirar: %w", err)
+	}
+	if err := d.DeadLetters.Delete(ctx, dl.ID); err != nil {
+		logging.FromContext(ctx).Error("Erro ao remover dead letter após replay", "dead_letter_id", dl.ID, "err", err)
+	}
+	return del, nil
+}
+
+func deleteDeadLetter(c *gin.Context, d Dependencies) {
+	if err := d.DeadLetters.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func discardDeadLetters(c *gin.Context, d Dependencies) {
+	f, err := parseDeadLetterFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if id := c.Param("id"); id != "" {
+		f.ClientID = id
+	}
+	n, err := d.DeadLetters.DeleteMany(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"discarded": n})
+}
+
+// ---- Handlers de mapeamentos LID ----
+
+func getLIDMapping(c *gin.Context, d Dependencies) {
+	m, err := d.LIDMappings.Get(c.Request.Context(), c.Param("lid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mapping": m})
+}
+
+func putLIDMapping(c *gin.Context, d Dependencies) {
+	var in struct {
+		JID string `json:"jid"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload inválido"})
+		return
+	}
+	m, err := d.LIDMappings.Correct(c.Request.Context(), c.Param("lid"), in.JID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mapping": m})
+}
+
+func deleteLIDMapping(c *gin.Context, d Dependencies) {
+	if err := d.LIDMappings.Delete(c.Request.Context(), c.Param("lid")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// invalidateClientCache remove o cliente do cache do data-plane pelo id
+func invalidateClientCache(c *gin.Context, d Dependencies, id string) {
+	if cli, err := d.ClientSvc.GetByID(c.Request.Context(), id); err == nil && cli != nil {
+		d.Resolver.Invalidate(cli.SecretID)
+	}
+}
+
+// ---- Handlers de chaves da API admin ----
+
+// createAPIKey devolve a chave em claro uma única vez; só o hash fica no banco
+func createAPIKey(c *gin.Context, d Dependencies) {
+	var in struct {
+		Name   string               `json:"name"`
+		Scopes []models.APIKeyScope `json:"scopes"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload inválido"})
+		return
+	}
+	key, token, err := d.APIKeys.Create(c.Request.Context(), in.Name, in.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	logging.FromContext(c.Request.Context()).Info("Chave da API admin criada", "api_key_id", key.ID, "name", key.Name, "scopes", key.Scopes)
+	c.JSON(http.StatusCreated, gin.H{"apiKey": key, "key": token})
+}
+
+func listAPIKeys(c *gin.Context, d Dependencies) {
+	items, err := d.APIKeys.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "erro ao listar"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func revokeAPIKey(c *gin.Context, d Dependencies) {
+	id := c.Param("id")
+	if err := d.APIKeys.Revoke(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "não encontrado"})
+		return
+	}
+	logging.FromContext(c.Request.Context()).Info("Chave da API admin revogada", "api_key_id", id)
+	c.Status(http.StatusNoContent)
+}
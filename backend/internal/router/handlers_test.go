@@ -0,0 +1,88 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+)
+
+func TestGetClientMasksSecretID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secretID = "9f0c7a52-2f4e-4b7a-9a43-0d8f3a1e6b11"
+	d := Dependencies{ClientSvc: stubClients{client: &models.Client{ID: "c1", SecretID: secretID}}}
+
+	tests := []struct {
+		name   string
+		scopes []models.APIKeyScope
+		query  string
+		code   int
+		want   string
+	}{
+		{"read: mascarado", []models.APIKeyScope{models.ScopeRead}, "", http.StatusOK, "9f0c****6b11"},
+		{"resolve sem pedido explícito: mascarado", []models.APIKeyScope{models.ScopeRead, models.ScopeResolve}, "", http.StatusOK, "9f0c****6b11"},
+		{"resolve com includeSecrets: completo", []models.APIKeyScope{models.ScopeRead, models.ScopeResolve}, "?includeSecrets=true", http.StatusOK, secretID},
+		{"read com includeSecrets: 403", []models.APIKeyScope{models.ScopeRead}, "?includeSecrets=true", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/api/clients/:id", func(c *gin.Context) {
+				c.Set(apiKeyContextKey, &models.APIKey{ID: "k1", Scopes: tt.scopes})
+				getClient(c, d)
+			})
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/clients/c1"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.code, rec.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var out struct {
+				Client models.Client `json:"client"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Client.SecretID != tt.want {
+				t.Fatalf("secretId = %q, want %q", out.Client.SecretID, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireAdminKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		config string
+		header string
+		code   int
+	}{
+		{"chave correta", "adm-token", "adm-token", http.StatusOK},
+		{"chave errada", "adm-token", "adm-tokeX", http.StatusUnauthorized},
+		{"prefixo da chave", "adm-token", "adm", http.StatusUnauthorized},
+		{"sem header", "adm-token", "", http.StatusUnauthorized},
+		{"token não configurado", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/admin/x", RequireAdminKey(tt.config), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req := httptest.NewRequest(http.MethodGet, "/admin/x", nil)
+			if tt.header != "" {
+				req.Header.Set("x-admin-key", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.code)
+			}
+		})
+	}
+}
@@ -1,45 +1,190 @@
 package router
 
 import (
-	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/logging"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/notify"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/service"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func RequestLogger() gin.HandlerFunc {
-	// Força logs de requisições como nível informativo (stdout)
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+// maxRequestIDLen limita o X-Request-ID aceito do chamador
+const maxRequestIDLen = 128
+
+// RequestID aceita o X-Request-ID recebido (se válido) ou gera um UUID, guarda no contexto
+// e devolve no header da resposta
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := strings.TrimSpace(c.GetHeader(logging.HeaderRequestID))
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header(logging.HeaderRequestID, requestID)
+		c.Next()
+	}
+}
+
+// validRequestID evita que um ID arbitrário polua logs e alertas: até 128 caracteres [A-Za-z0-9._:-]
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RequestLogger coloca no contexto um logger com o request_id (ver RequestID)
+// e registra cada requisição em nível info
+func RequestLogger(base *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
+		logger := base
+		if requestID := logging.RequestID(c.Request.Context()); requestID != "" {
+			logger = logger.With("request_id", requestID)
+		}
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), logger))
 		c.Next()
-		latency := time.Since(start)
-		logger.Printf("%s %s %d %s", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), latency)
+		// Usa o logger final do contexto para incluir secret_id/client_id quando resolvidos
+		logging.FromContext(c.Request.Context()).Info("request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds(),
+		)
 	}
 }
 
+// Tracing abre o span de entrada continuando o traceparent recebido e
+// adiciona o trace_id ao logger do contexto
+func Tracing(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.HTTPRoute(c.FullPath()),
+		))
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			ctx = logging.With(ctx, "trace_id", traceID)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		span.End()
+	}
+}
+
+// RequireAdminKey protege rotas operacionais com o header x-admin-key
+func RequireAdminKey(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("x-admin-key")
+		if strings.TrimSpace(adminToken) == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// apiKeyContextKey guarda no gin.Context a chave autenticada por RequireAPIKey
+const apiKeyContextKey = "apiKey"
+
+// resolveRoute é o lookup do data-plane, que exige o escopo resolve
+const resolveRoute = "/api/clients/by-secret/:secretId"
+
+// serviceTokenKey representa o ADMIN_SERVICE_TOKEN na API admin: todos os escopos
+var serviceTokenKey = &models.APIKey{ID: "service-token", Name: "ADMIN_SERVICE_TOKEN", Scopes: models.APIKeyScopes}
+
+// RequireAPIKey autentica a API admin com uma chave (Authorization: Bearer ou x-api-key) e exige
+// o escopo da rota: resolve no lookup por secretId, read nos GET e write nos demais métodos.
+// O x-admin-key com o ADMIN_SERVICE_TOKEN segue aceito, com todos os escopos.
+func RequireAPIKey(keys service.APIKeyService, adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := serviceTokenKey
+		if adminKey := c.GetHeader("x-admin-key"); adminKey == "" || strings.TrimSpace(adminToken) == "" ||
+			subtle.ConstantTimeCompare([]byte(adminKey), []byte(adminToken)) != 1 {
+			token := strings.TrimSpace(c.GetHeader("x-api-key"))
+			if auth := c.GetHeader("Authorization"); token == "" && len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+				token = strings.TrimSpace(auth[7:])
+			}
+			if token == "" || keys == nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				return
+			}
+			var err error
+			key, err = keys.Authenticate(c.Request.Context(), token)
+			if errors.Is(err, service.ErrInvalidAPIKey) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				return
+			}
+			if err != nil {
+				logging.FromContext(c.Request.Context()).Error("Erro ao validar chave da API", "err", err)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "erro ao validar chave"})
+				return
+			}
+		}
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "api_key_id", key.ID))
+		scope := routeScope(c)
+		if !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "escopo insuficiente", "requiredScope": scope})
+			return
+		}
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// routeScope define o escopo exigido pela rota
+func routeScope(c *gin.Context) models.APIKeyScope {
+	switch {
+	case c.FullPath() == resolveRoute:
+		return models.ScopeResolve
+	case c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead:
+		return models.ScopeRead
+	default:
+		return models.ScopeWrite
+	}
+}
+
+// requestAPIKey retorna a chave autenticada da requisição (nil fora da API admin)
+func requestAPIKey(c *gin.Context) *models.APIKey {
+	k, _ := c.Get(apiKeyContextKey)
+	key, _ := k.(*models.APIKey)
+	return key
+}
+
 // Envia alerta no Slack em caso de panic
-func RecoveryWithSlack(slackURL string, botToken string, channelID string, errLogger *log.Logger) gin.HandlerFunc {
+func RecoveryWithSlack(notifier *notify.Notifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if r := recover(); r != nil {
-				errLogger.Printf("panic: %v", r)
-				msg := fmt.Sprintf(":rotating_light: Panic em %s %s: %v", c.Request.Method, c.FullPath(), r)
-				go func() {
-					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-					defer cancel()
-					if slackURL != "" {
-						_ = notify.PostSlack(ctx, slackURL, msg)
-						return
-					}
-					if botToken != "" && channelID != "" {
-						_ = notify.PostSlackWithBot(ctx, botToken, channelID, msg)
-					}
-				}()
+				logging.FromContext(c.Request.Context()).Error("panic", "method", c.Request.Method, "path", c.FullPath(), "panic", fmt.Sprint(r))
+				// Via Notifier o alerta entra na espera do shutdown
+				notifier.NotifyContext(c.Request.Context(), fmt.Sprintf(":rotating_light: Panic em %s %s: %v", c.Request.Method, c.FullPath(), r))
 				c.AbortWithStatus(500)
 			}
 		}()
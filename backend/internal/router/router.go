@@ -1,39 +1,95 @@
 package router
 
 import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 
-	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/aggregate"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/breaker"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/config"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/dedup"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/delivery"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/health"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/ingest"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/metrics"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/notify"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/ratelimit"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/service"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
 )
 
 type Dependencies struct {
-	Config      config.Config
-	Cache       *cache.MemoryCache[string, string]
-	HTTPClient  *http.Client
-	ClientSvc   service.ClientService
-	InfoLogger  *log.Logger
-	ErrorLogger *log.Logger
+	Config       config.Config
+	Resolver     *service.ClientResolver
+	Limiter      *ratelimit.Limiter
+	Deduper      *dedup.Deduper
+	Aggregator   *aggregate.Aggregator
+	Dispatcher   *delivery.Dispatcher
+	Breaker      *breaker.Breaker
+	Metrics      *metrics.Metrics
+	Ingest       *ingest.Shipper
+	Health       *health.Checker
+	Notifier     *notify.Notifier
+	HTTPClient   *http.Client
+	ClientSvc    service.ClientService
+	Destinations service.DestinationService
+	LIDMappings  service.LIDMappingService
+	APIKeys      service.APIKeyService
+	Deliveries   repository.DeliveryRepository
+	DeadLetters  repository.DeadLetterRepository
+	DeliveryLog  repository.DeliveryLogRepository
+	Logger       *slog.Logger
+
+	// LIDConverters reaproveita os conversores LID->JID; nil monta um a partir de LIDMappings/Metrics,
+	// sem cache de falhas da API
+	LIDConverters *webhook.LIDConverters
 }
 
 func Register(r *gin.Engine, d Dependencies) {
+	if d.LIDConverters == nil {
+		var store webhook.LIDStore
+		if d.LIDMappings != nil {
+			store = d.LIDMappings
+		}
+		var onConvert func(string, error)
+		if d.Metrics != nil {
+			onConvert = d.Metrics.LIDConversion
+		}
+		d.LIDConverters = webhook.NewLIDConverters(store, onConvert, nil)
+	}
+	// Rajadas agregadas saem pela fila de entregas
+	if d.Aggregator != nil && d.Aggregator.Flush == nil {
+		d.Aggregator.Flush = func(b aggregate.Batch) error { return flushBurst(d, b) }
+	}
+
 	// Healthcheck simples para orquestradores (Railway, etc.)
 	r.GET("/healthz", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
+	// Liveness: o processo responde (sem checar dependências, para não reiniciar por falha do banco)
+	r.GET("/livez", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	// Readiness: 503 com Postgres/migrations/fila com falha ou durante o shutdown
+	if d.Health != nil {
+		r.GET("/readyz", func(c *gin.Context) {
+			rep := d.Health.Ready(c.Request.Context())
+			code := http.StatusOK
+			if !rep.Healthy() {
+				code = http.StatusServiceUnavailable
+			}
+			c.JSON(code, rep)
+		})
+	}
+
+	// Métricas Prometheus (formato texto)
+	if d.Metrics != nil {
+		r.GET("/metrics", gin.WrapH(d.Metrics.Handler()))
+	}
 
 	// Raiz informativa
 	r.GET("/", func(c *gin.Context) {
@@ -42,6 +98,10 @@ func Register(r *gin.Engine, d Dependencies) {
 			"status":  "ok",
 			"endpoints": []string{
 				"GET /healthz",
+				"GET /livez",
+				"GET /readyz",
+				"GET /metrics",
+				"GET /webhook/:secretId",
 				"POST /webhook/:secretId",
 				"GET /api/clients",
 				"POST /api/clients",
@@ -49,271 +109,139 @@ func Register(r *gin.Engine, d Dependencies) {
 				"PATCH /api/clients/:id",
 				"DELETE /api/clients/:id",
 				"GET /api/clients/by-secret/:secretId",
+				"POST /api/clients/:id/signing-secret/rotate",
+				"GET /api/clients/:id/rules",
+				"PUT /api/clients/:id/rules",
+				"GET /api/clients/:id/destinations",
+				"POST /api/clients/:id/destinations",
+				"PATCH /api/clients/:id/destinations/:destId",
+				"DELETE /api/clients/:id/destinations/:destId",
+				"GET /api/deliveries/:id",
+				"GET /api/delivery-log",
+				"GET /api/clients/:id/delivery-log",
+				"GET /api/lid-mappings/:lid",
+				"PUT /api/lid-mappings/:lid",
+				"DELETE /api/lid-mappings/:lid",
+				"GET /api/dead-letters",
+				"GET /api/dead-letters/:id",
+				"POST /api/dead-letters/:id/replay",
+				"POST /api/dead-letters/replay",
+				"DELETE /api/dead-letters/:id",
+				"DELETE /api/dead-letters",
+				"GET /api/clients/:id/dead-letters",
+				"POST /api/clients/:id/dead-letters/replay",
+				"DELETE /api/clients/:id/dead-letters",
+				"GET /admin/ratelimit",
+				"GET /admin/dedup",
+				"GET /admin/breakers",
+				"GET /admin/ingest",
+				"GET /admin/health",
+				"POST /admin/api-keys",
+				"GET /admin/api-keys",
+				"DELETE /admin/api-keys/:id",
 			},
 		})
 	})
 
 	// Webhook (data-plane)
-	r.POST("/webhook/:secretId", func(c *gin.Context) {
-		secretID := strings.TrimSpace(c.Param("secretId"))
-		if secretID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "secret id ausente"})
-			return
-		}
-
-		contentType := c.Request.Header.Get("Content-Type")
-		ctLower := strings.ToLower(contentType)
-		if !(strings.HasPrefix(ctLower, "application/x-www-form-urlencoded") ||
-			strings.HasPrefix(ctLower, "multipart/form-data") ||
-			strings.HasPrefix(ctLower, "application/json")) {
-			d.InfoLogger.Printf("Aviso: Content-Type inesperado: %s", contentType)
-		}
+	r.POST("/webhook/:secretId", Tracing("webhook.ingest"), func(c *gin.Context) { handleWebhook(c, d) })
+	// Handshake de verificação da Meta Cloud API
+	r.GET("/webhook/:secretId", func(c *gin.Context) { handleWebhookVerify(c, d) })
 
-		// Helper p/ notificar erros no Slack (assíncrono)
-		notifySlack := func(msg string) {
-			if url := strings.TrimSpace(d.Config.SlackWebhookURL); url != "" {
-				go func() {
-					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-					defer cancel()
-					if err := notify.PostSlack(ctx, url, msg); err != nil {
-						d.ErrorLogger.Printf("Slack notify (webhook) error: %v", err)
-					}
-				}()
-			} else if bt, ch := strings.TrimSpace(d.Config.SlackBotToken), strings.TrimSpace(d.Config.SlackChannelID); bt != "" && ch != "" {
-				go func() {
-					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-					defer cancel()
-					if err := notify.PostSlackWithBot(ctx, bt, ch, msg); err != nil {
-						d.ErrorLogger.Printf("Slack notify (bot) error: %v", err)
-					}
-				}()
-			}
-		}
-
-		// Leia e preserve o corpo original para suportar multipart/json/urlencoded
-		bodyBytes, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			d.ErrorLogger.Printf("Erro ao ler corpo: %v", err)
-			notifySlack(fmt.Sprintf(":warning: Erro ao ler corpo | secretId=%s | err=%v", secretID, err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "corpo inválido"})
-			return
-		}
-		// Restaura o Body para futuras leituras (FormValue/forward)
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-
-		var jsonDataStr string
-		switch {
-		case strings.HasPrefix(ctLower, "application/json"):
-			// Tentar dois formatos comuns:
-			// 1) Corpo inteiro já é o JSON do evento
-			// 2) Corpo é um envelope { "jsonData": "{...}" }
-			bodyTrim := strings.TrimSpace(string(bodyBytes))
-			if bodyTrim != "" {
-				// Primeiro tenta extrair de envelope (prioridade)
-				type kv map[string]any
-				var m kv
-				if err := json.Unmarshal(bodyBytes, &m); err == nil {
-					// Debug: mostra as chaves encontradas
-					d.InfoLogger.Printf("🔍 [DEBUG] Chaves no envelope: %v", getMapKeysFromAny(m))
-
-					// Verifica se existe campo jsonData ou body.jsonData
-					if v, ok := m["jsonData"].(string); ok && strings.TrimSpace(v) != "" {
-						d.InfoLogger.Printf("🔍 [DEBUG] Extraindo de m[jsonData]")
-						jsonDataStr = v
-					} else if body, ok := m["body"].(map[string]interface{}); ok {
-						d.InfoLogger.Printf("🔍 [DEBUG] Tentando extrair de m[body] - chaves: %v", getMapKeysFromAny(body))
-
-						// Caso especial: body tem uma chave que é o JSON inteiro
-						for key, value := range body {
-							if strings.HasPrefix(key, "{") && strings.HasSuffix(key, "}") {
-								d.InfoLogger.Printf("🔍 [DEBUG] Encontrou JSON como chave em body")
-								jsonDataStr = key
-								break
-							}
-							if v, ok := value.(string); ok && strings.HasPrefix(v, "{") {
-								d.InfoLogger.Printf("🔍 [DEBUG] Encontrou JSON como valor em body")
-								jsonDataStr = v
-								break
-							}
-						}
-
-						// Fallback: procura jsonData tradicional
-						if jsonDataStr == "" {
-							if v, ok := body["jsonData"].(string); ok && strings.TrimSpace(v) != "" {
-								d.InfoLogger.Printf("🔍 [DEBUG] Extraindo de m[body][jsonData]")
-								jsonDataStr = v
-							} else {
-								d.InfoLogger.Printf("🔍 [DEBUG] body sem JSON válido, usando corpo inteiro")
-								jsonDataStr = bodyTrim
-							}
-						}
-					} else {
-						d.InfoLogger.Printf("🔍 [DEBUG] Nem jsonData nem body encontrados, usando corpo inteiro")
-						// Se não tem envelope, usa corpo inteiro como evento
-						jsonDataStr = bodyTrim
-					}
-				} else {
-					d.InfoLogger.Printf("🔍 [DEBUG] Erro no parse do envelope: %v", err)
-					jsonDataStr = bodyTrim
-				}
-			}
-
-		case strings.HasPrefix(ctLower, "multipart/form-data"):
-			// Parse explícito para garantir acesso a campos
-			_ = c.Request.ParseMultipartForm(10 << 20) // 10 MiB
-			jsonDataStr = c.Request.FormValue("jsonData")
-
-		default: // urlencoded e outros
-			_ = c.Request.ParseForm()
-			jsonDataStr = c.Request.FormValue("jsonData")
-		}
-		// Debug: Log do que foi extraído
-		if jsonDataStr != "" {
-			preview := jsonDataStr
-			if len(preview) > 100 {
-				preview = preview[:100] + "..."
-			}
-			d.InfoLogger.Printf("🔍 [DEBUG] jsonData extraído: %s", preview)
-		}
-
-		if strings.TrimSpace(jsonDataStr) == "" {
-			// Log auxiliar para depuração em ambientes reais
-			bodyPreview := string(bodyBytes)
-			if len(bodyPreview) > 256 {
-				bodyPreview = bodyPreview[:256] + "..."
-			}
-			d.ErrorLogger.Printf("Campo 'jsonData' ausente ou vazio | CT=%q | body_len=%d | preview=%q", contentType, len(bodyBytes), bodyPreview)
-			notifySlack(fmt.Sprintf(":warning: jsonData ausente | secretId=%s | CT=%s | len=%d", secretID, contentType, len(bodyBytes)))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "jsonData ausente"})
-			return
-		}
-
-		// Valida cliente/secret
-		targetURL, ok := resolveClientWebhookCached(c, d, secretID)
-		if !ok {
-			d.InfoLogger.Printf("{\"event\":\"client_not_found\",\"secret_id\":%q}", secretID)
-			notifySlack(fmt.Sprintf(":warning: client_not_found | secretId=%s", secretID))
-			c.JSON(http.StatusNotFound, gin.H{"error": "Cliente não encontrado"})
-			return
-		}
-
-		// Filtro imediato por substring no bruto (cobre JSON double-escapado)
-		rawLower := strings.ToLower(jsonDataStr)
-		rawCompact := strings.Map(func(r rune) rune {
-			switch r {
-			case ' ', '\n', '\r', '\t':
-				return -1
-			default:
-				return r
-			}
-		}, rawLower)
-		if strings.Contains(rawLower, "@g.us") || strings.Contains(rawLower, "@broadcast") || strings.Contains(rawLower, "status@broadcast") || strings.Contains(rawCompact, "\"isgroup\":true") || strings.Contains(rawCompact, "\\\"isgroup\\\":true") {
-			d.InfoLogger.Printf("{\"event\":\"rejected_group\",\"reason\":\"filter_raw_match\"}")
-			c.JSON(http.StatusOK, gin.H{"status": "ignored_group_message"})
-			return
-		}
-
-		// Dados para envio (sempre os dados originais)
-		dataToSend := bodyBytes
-
-		d.InfoLogger.Printf("{\"event\":\"webhook_send\",\"secret_id\":%q,\"size\":%d}", secretID, len(dataToSend))
-
-		// Encaminha dados ao destino preservando Content-Type
-		req, err := http.NewRequest(http.MethodPost, targetURL, bytes.NewReader(dataToSend))
-		if err != nil {
-			d.ErrorLogger.Printf("Erro criar req: %v", err)
-			notifySlack(fmt.Sprintf(":warning: Erro criar req para %s | secretId=%s | err=%v", targetURL, secretID, err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "erro interno"})
-			return
-		}
-		if contentType != "" {
-			req.Header.Set("Content-Type", contentType)
-		} else {
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		}
-
-		resp, err := d.HTTPClient.Do(req)
-		if err != nil {
-			d.ErrorLogger.Printf("Erro ao encaminhar: %v", err)
-			notifySlack(fmt.Sprintf(":warning: Forward falhou para %s | secretId=%s | err=%v", targetURL, secretID, err))
-			c.JSON(http.StatusBadGateway, gin.H{"error": "destino indisponível"})
-			return
-		}
-		defer resp.Body.Close()
-
-		c.Status(resp.StatusCode)
-		c.Writer.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
-		_, _ = io.Copy(c.Writer, resp.Body)
-	})
-
-	// Admin API - Clients
-	g := r.Group("/api")
+	// Admin API - Clients (chave com escopo read/write/resolve ou x-admin-key)
+	g := r.Group("/api", RequireAPIKey(d.APIKeys, d.Config.AdminServiceToken))
 	{
 		g.POST("/clients", func(c *gin.Context) { createClient(c, d) })
 		g.GET("/clients", func(c *gin.Context) { listClients(c, d) })
 		g.GET("/clients/:id", func(c *gin.Context) { getClient(c, d) })
 		g.PATCH("/clients/:id", func(c *gin.Context) { updateClient(c, d) })
 		g.DELETE("/clients/:id", func(c *gin.Context) { deleteClient(c, d) })
+		g.POST("/clients/:id/signing-secret/rotate", func(c *gin.Context) { rotateSigningSecret(c, d) })
+		g.GET("/clients/:id/rules", func(c *gin.Context) { getFilterRules(c, d) })
+		g.PUT("/clients/:id/rules", func(c *gin.Context) { putFilterRules(c, d) })
+		g.GET("/clients/:id/destinations", func(c *gin.Context) { listDestinations(c, d) })
+		g.POST("/clients/:id/destinations", func(c *gin.Context) { createDestination(c, d) })
+		g.PATCH("/clients/:id/destinations/:destId", func(c *gin.Context) { updateDestination(c, d) })
+		g.DELETE("/clients/:id/destinations/:destId", func(c *gin.Context) { deleteDestination(c, d) })
 
 		// Resolver mínimo para data-plane (cache/ENV fallback ocorre no handler de webhook)
 		g.GET("/clients/by-secret/:secretId", func(c *gin.Context) { resolveBySecret(c, d) })
+
+		// Fila de entregas
+		g.GET("/deliveries/:id", func(c *gin.Context) { getDelivery(c, d) })
+
+		// Log de entregas (filtros: clientId, secretId, chat, eventId, status, statusCode, since, until, limit, offset)
+		g.GET("/delivery-log", func(c *gin.Context) { listDeliveryLog(c, d) })
+		g.GET("/clients/:id/delivery-log", func(c *gin.Context) { listDeliveryLog(c, d) })
+
+		// Mapeamentos LID->JID (consulta/correção manual)
+		g.GET("/lid-mappings/:lid", func(c *gin.Context) { getLIDMapping(c, d) })
+		g.PUT("/lid-mappings/:lid", func(c *gin.Context) { putLIDMapping(c, d) })
+		g.DELETE("/lid-mappings/:lid", func(c *gin.Context) { deleteLIDMapping(c, d) })
+
+		// Dead letters (filtros: clientId, secretId, since, until, limit, offset)
+		g.GET("/dead-letters", func(c *gin.Context) { listDeadLetters(c, d) })
+		g.POST("/dead-letters/replay", func(c *gin.Context) { replayDeadLetters(c, d) })
+		g.DELETE("/dead-letters", func(c *gin.Context) { discardDeadLetters(c, d) })
+		g.GET("/dead-letters/:id", func(c *gin.Context) { getDeadLetter(c, d) })
+		g.POST("/dead-letters/:id/replay", func(c *gin.Context) { replayDeadLetter(c, d) })
+		g.DELETE("/dead-letters/:id", func(c *gin.Context) { deleteDeadLetter(c, d) })
+		g.GET("/clients/:id/dead-letters", func(c *gin.Context) { listDeadLetters(c, d) })
+		g.POST("/clients/:id/dead-letters/replay", func(c *gin.Context) { replayDeadLetters(c, d) })
+		g.DELETE("/clients/:id/dead-letters", func(c *gin.Context) { discardDeadLetters(c, d) })
 	}
 
-	// Opcional: Purge de cache por secretId protegido por token
-	r.POST("/admin/cache/purge/:secretId", func(c *gin.Context) {
-		token := c.Request.Header.Get("x-admin-key")
-		if strings.TrimSpace(d.Config.AdminServiceToken) == "" || token != d.Config.AdminServiceToken {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-			return
-		}
-		secretID := strings.TrimSpace(c.Param("secretId"))
-		if secretID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "secretId requerido"})
-			return
-		}
-		d.Cache.Delete(secretID)
-		c.Status(http.StatusNoContent)
-	})
-}
+	// Endpoints operacionais protegidos por token (x-admin-key)
+	admin := r.Group("/admin", RequireAdminKey(d.Config.AdminServiceToken))
+	{
+		// Opcional: Purge de cache por secretId
+		admin.POST("/cache/purge/:secretId", func(c *gin.Context) {
+			secretID := strings.TrimSpace(c.Param("secretId"))
+			if secretID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "secretId requerido"})
+				return
+			}
+			d.Resolver.Invalidate(secretID)
+			c.Status(http.StatusNoContent)
+		})
 
-// resolveClientWebhookCached tenta cache -> ENV -> repo
-func resolveClientWebhookCached(c *gin.Context, d Dependencies, secretID string) (string, bool) {
-	if v, ok := d.Cache.Get(secretID); ok {
-		return v, true
-	}
-	// ENV compatível
-	if url, ok := resolveClientWebhookURLFromEnv(secretID); ok {
-		d.Cache.Set(secretID, url)
-		return url, true
-	}
-	// Repo
-	client, err := d.ClientSvc.GetBySecretID(c, secretID)
-	if err == nil && client != nil && client.IsActive {
-		d.Cache.Set(secretID, client.WebhookURL)
-		return client.WebhookURL, true
-	}
-	return "", false
-}
+		// Eventos barrados por rate limit por secretId (chaves ociosas por 10 min são descartadas)
+		admin.GET("/ratelimit", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"throttled": d.Limiter.Throttled()})
+		})
 
-// ENV compatível com formato CLIENT_{UUID}
-func resolveClientWebhookURLFromEnv(secretID string) (string, bool) {
-	underscored := strings.ReplaceAll(secretID, "-", "_")
-	lower := "CLIENT_" + strings.ToLower(underscored)
-	upper := "CLIENT_" + strings.ToUpper(underscored)
-	if v := strings.TrimSpace(os.Getenv(lower)); v != "" {
-		return v, true
-	}
-	if v := strings.TrimSpace(os.Getenv(upper)); v != "" {
-		return v, true
-	}
-	return "", false
-}
+		// Duplicados barrados desde o start, por secretId
+		admin.GET("/dedup", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"duplicates": d.Deduper.Hits()})
+		})
+
+		// Estado dos circuit breakers por destino (ID cadastrado ou host+path, sem query)
+		admin.GET("/breakers", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"circuits": d.Breaker.Snapshot()})
+		})
+
+		// Contadores do shipper de ingest (enviados, descartados, em buffer)
+		admin.GET("/ingest", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"enabled": d.Ingest != nil, "stats": d.Ingest.Stats()})
+		})
 
-// getMapKeysFromAny retorna as chaves de um map para debug
-func getMapKeysFromAny(m map[string]interface{}) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+		// Status e latência por dependência (inclui API LID e ingest, que só degradam)
+		if d.Health != nil {
+			admin.GET("/health", func(c *gin.Context) {
+				rep := d.Health.Detailed(c.Request.Context())
+				code := http.StatusOK
+				if !rep.Healthy() {
+					code = http.StatusServiceUnavailable
+				}
+				c.JSON(code, rep)
+			})
+		}
+
+		// Chaves da API admin (/api): emissão, listagem e revogação
+		if d.APIKeys != nil {
+			admin.POST("/api-keys", func(c *gin.Context) { createAPIKey(c, d) })
+			admin.GET("/api-keys", func(c *gin.Context) { listAPIKeys(c, d) })
+			admin.DELETE("/api-keys/:id", func(c *gin.Context) { revokeAPIKey(c, d) })
+		}
 	}
-	return keys
 }
@@ -0,0 +1,138 @@
+package router
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/delivery"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/ratelimit"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/service"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/tracing"
+)
+
+// TestWebhookSpans percorre o pipeline completo (ingest -> parse -> normalize -> LID -> lookup
+// -> forward) e confere os spans exportados e o traceparent recebido pelo destino
+func TestWebhookSpans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown := tracing.SetupWithExporter(exporter, nil)
+	t.Cleanup(func() { _ = shutdown(context.Background()) })
+
+	lidAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user/parselid" || r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unexpected", http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(w, `{"status":true,"data":{"success":true,"jid":"5511987654321@s.whatsapp.net"}}`)
+	}))
+	defer lidAPI.Close()
+
+	var gotTraceParent, gotBody string
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceParent = r.Header.Get(tracing.HeaderTraceParent)
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer dest.Close()
+
+	client := &models.Client{
+		ID:               "c1",
+		SecretID:         "9f0c7a52-2f4e-4b7a-9a43-0d8f3a1e6b11",
+		WebhookURL:       dest.URL,
+		ProviderAPIToken: "tok",
+		ProviderBaseURL:  lidAPI.URL,
+		IsActive:         true,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	outbox := &memOutbox{}
+	resolverCache := cache.NewMemoryCache[string, *models.Client](time.Minute)
+
+	r := gin.New()
+	Register(r, Dependencies{
+		Resolver:   service.NewClientResolver(resolverCache, stubClients{client: client}, nil),
+		Limiter:    ratelimit.NewLimiter(),
+		Dispatcher: delivery.NewDispatcher(outbox, http.DefaultClient, nil, nil, delivery.Options{}, logger),
+		HTTPClient: http.DefaultClient,
+		Logger:     logger,
+	})
+
+	jsonData := `{"type":"Message","event":{"Info":{"Chat":"112233445566778@lid","Sender":"5511987654321@s.whatsapp.net","IsFromMe":false,"IsGroup":false,"ID":"3EB0TRACE","Type":"text","Timestamp":"2024-05-10T13:45:12-03:00"},"Message":{"conversation":"oi"}}}`
+	form := url.Values{"jsonData": {jsonData}}.Encode()
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodPost, "/webhook/"+client.SecretID, strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(tracing.HeaderTraceParent, parent)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if len(outbox.delivered) != 1 {
+		t.Fatalf("entregas = %d, want 1", len(outbox.delivered))
+	}
+	if !strings.Contains(gotBody, url.QueryEscape("5511987654321@s.whatsapp.net")) || strings.Contains(gotBody, url.QueryEscape("112233445566778@lid")) {
+		t.Fatalf("LID não convertido no corpo encaminhado: %s", gotBody)
+	}
+
+	// O BatchSpanProcessor exporta em lote: força o envio antes de ler (Shutdown limparia o exporter)
+	if err := otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := map[string]tracetest.SpanStub{}
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+	ingest, ok := spans["webhook.ingest"]
+	if !ok {
+		t.Fatalf("span webhook.ingest ausente; exportados: %v", spanNames(exporter.GetSpans()))
+	}
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	if got := ingest.SpanContext.TraceID().String(); got != traceID {
+		t.Fatalf("webhook.ingest trace = %s, want %s (traceparent recebido)", got, traceID)
+	}
+	if got := ingest.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Fatalf("webhook.ingest parent = %s, want o span do traceparent recebido", got)
+	}
+	for _, name := range []string{"webhook.parse", "webhook.normalize", "lid.convert", "client_resolver.db_lookup", "delivery.forward"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("span %s ausente; exportados: %v", name, spanNames(exporter.GetSpans()))
+		}
+		if s.SpanContext.TraceID() != ingest.SpanContext.TraceID() {
+			t.Errorf("%s fora do trace da requisição", name)
+		}
+		if s.Parent.SpanID() != ingest.SpanContext.SpanID() {
+			t.Errorf("%s não é filho de webhook.ingest", name)
+		}
+	}
+
+	forward := spans["delivery.forward"]
+	want := "00-" + traceID + "-" + forward.SpanContext.SpanID().String() + "-01"
+	if gotTraceParent != want {
+		t.Fatalf("traceparent no destino = %q, want %q", gotTraceParent, want)
+	}
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, 0, len(spans))
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	return names
+}
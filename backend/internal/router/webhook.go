@@ -0,0 +1,333 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/aggregate"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/ingest"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/logging"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/metrics"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/tracing"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
+)
+
+// handleWebhook recebe eventos do provider, valida o cliente e encaminha ao destino via outbox
+func handleWebhook(c *gin.Context, d Dependencies) {
+	secretID := strings.TrimSpace(c.Param("secretId"))
+	if secretID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "secret id ausente"})
+		return
+	}
+	// Campos da requisição em todos os logs do pipeline
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "secret_id", secretID))
+	logger := logging.FromContext(c.Request.Context())
+
+	contentType := c.Request.Header.Get("Content-Type")
+	ctLower := strings.ToLower(contentType)
+	if !(strings.HasPrefix(ctLower, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(ctLower, "multipart/form-data") ||
+		strings.HasPrefix(ctLower, "application/json")) {
+		logger.Warn("Content-Type inesperado", "content_type", contentType)
+	}
+
+	// Helper p/ notificar erros no Slack (assíncrono)
+	notifySlack := func(msg string) { d.Notifier.NotifyContext(c.Request.Context(), msg) }
+
+	// Valida cliente/secret antes de qualquer trabalho com o corpo
+	client, ok := d.Resolver.Resolve(c.Request.Context(), secretID)
+	if !ok {
+		logger.Warn("client_not_found")
+		d.Metrics.ClientNotFound()
+		notifySlack(fmt.Sprintf(":warning: client_not_found | secretId=%s", secretID))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cliente não encontrado"})
+		return
+	}
+
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "client_id", client.ID))
+	logger = logging.FromContext(c.Request.Context())
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("client.id", client.ID))
+	d.Metrics.WebhookReceived(client.ID)
+	d.Ingest.Ship(ingest.Event{Type: ingest.EventReceived, ClientID: client.ID, SecretID: secretID})
+
+	// Rate limit por secretId conforme limite/plano do cliente
+	if allowed, wait := d.Limiter.Allow(secretID, client.RateLimitPerMin, client.Plan); !allowed {
+		d.Metrics.Ignored(client.ID, "rate_limited")
+		d.Ingest.Ship(ingest.Event{Type: ingest.EventFiltered, ClientID: client.ID, SecretID: secretID, Reason: "rate_limited"})
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		logger.Info("rate_limited", "limit_per_min", client.RateLimitPerMin, "plan", client.Plan)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit excedido"})
+		return
+	}
+
+	// Leia e preserve o corpo original para suportar multipart/json/urlencoded
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logger.Error("Erro ao ler corpo", "err", err)
+		notifySlack(fmt.Sprintf(":warning: Erro ao ler corpo | secretId=%s | err=%v", secretID, err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "corpo inválido"})
+		return
+	}
+	// Restaura o Body para futuras leituras (FormValue/forward)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+	// Meta Cloud API assina o corpo com o app secret do cliente; sem app secret não há como
+	// distinguir um POST forjado, então o evento é recusado
+	if client.Provider == webhook.MetaProvider {
+		if client.MetaAppSecret == "" {
+			logger.Error("meta_app_secret_missing", "provider", client.Provider)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "app secret da Meta não configurado"})
+			return
+		}
+		if !webhook.VerifyMetaSignature(client.MetaAppSecret, bodyBytes, c.GetHeader(webhook.MetaSignatureHeader)) {
+			logger.Warn("invalid_signature", "provider", client.Provider)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "assinatura inválida"})
+			return
+		}
+	}
+
+	// Envelope conforme o provider configurado no cliente (padrão: wuzapi/Avisa)
+	extractor := webhook.ExtractorFor(client.Provider)
+	_, parseSpan := tracing.Start(c.Request.Context(), "webhook.parse", trace.WithAttributes(attribute.String("webhook.provider", extractor.Name())))
+	jsonDataStr, err := extractor.Extract(bodyBytes, contentType)
+	tracing.End(parseSpan, err)
+	if err != nil {
+		// Log auxiliar para depuração em ambientes reais
+		bodyPreview := string(bodyBytes)
+		if len(bodyPreview) > 256 {
+			bodyPreview = bodyPreview[:256] + "..."
+		}
+		logger.Error("Evento ausente no corpo", "provider", extractor.Name(), "content_type", contentType, "body_len", len(bodyBytes), "preview", bodyPreview)
+		notifySlack(fmt.Sprintf(":warning: evento ausente | secretId=%s | provider=%s | CT=%s | len=%d", secretID, extractor.Name(), contentType, len(bodyBytes)))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Payloads com vários eventos (ex.: Meta Cloud API) passam pelo pipeline um a um
+	if sp, ok := extractor.(webhook.Splitter); ok {
+		parts, err := sp.Split(jsonDataStr)
+		if err == nil && len(parts) > 1 {
+			results := make([]gin.H, 0, len(parts))
+			for _, part := range parts {
+				results = append(results, processEvent(c, d, client, extractor, []byte(part), "application/json", part).summary())
+			}
+			c.JSON(http.StatusOK, gin.H{"status": "processed", "events": results})
+			return
+		}
+	}
+	processEvent(c, d, client, extractor, bodyBytes, contentType, jsonDataStr).write(c)
+}
+
+// processEvent aplica conversão LID->JID, regras, dedup e encaminhamento a um único evento
+func processEvent(c *gin.Context, d Dependencies, client *models.Client, extractor webhook.Extractor, bodyBytes []byte, contentType, jsonDataStr string) (out outcome) {
+	secretID := client.SecretID
+	logger := logging.FromContext(c.Request.Context())
+	// Regras de filtro do cliente avaliadas sobre o evento parseado
+	var routeURL string
+	var evt *webhook.EventInfo
+	start := time.Now()
+	defer func() { recordEvent(c.Request.Context(), d, client, extractor, evt, out, start) }()
+	_, normSpan := tracing.Start(c.Request.Context(), "webhook.normalize")
+	normalized, err := extractor.Normalize(jsonDataStr)
+	tracing.End(normSpan, err)
+	if err != nil {
+		logger.Warn("Falha no parse do evento, regras não aplicadas", "provider", extractor.Name(), "err", err)
+	} else {
+		evt = normalized.Info()
+	}
+
+	// Dados para envio (originais, exceto pela conversão LID->JID)
+	dataToSend := bodyBytes
+	eventData := jsonDataStr
+
+	// Conversão LID->JID (só no envelope wuzapi/Avisa e quando o evento traz algum @lid): mapeamentos
+	// conhecidos primeiro, API do provider no fallback (só quando o cliente tem token; sem ele, LIDs
+	// fora do store seguem como vieram)
+	if extractor.Name() == webhook.DefaultProvider && (client.ProviderAPIToken != "" || d.LIDMappings != nil) && webhook.HasLID(jsonDataStr) {
+		baseURL := client.ProviderBaseURL
+		if baseURL == "" {
+			baseURL = d.Config.AvisaAPIURL
+		}
+		converter := d.LIDConverters.For(baseURL)
+		_, _, _, _, conversions, eventInfo := webhook.ExtractEventInfoWithConversion(c.Request.Context(), jsonDataStr, converter, client.ProviderAPIToken, logger)
+		if errMsg, ok := conversions["conversion_error"]; ok {
+			logger.Warn("Conversão LID->JID falhou, encaminhando original", "err", errMsg)
+		}
+		if eventInfo != nil && eventInfo.HasConversions() {
+			converted, err := eventInfo.ApplyConversionsToJSON()
+			if err == nil {
+				dataToSend, err = webhook.ReplaceJSONData(bodyBytes, contentType, jsonDataStr, converted)
+			}
+			if err != nil {
+				logger.Error("Erro ao aplicar conversões LID->JID, encaminhando original", "err", err)
+				dataToSend = bodyBytes
+			} else {
+				eventData = converted
+				// Chat/sender convertidos valem daqui em diante: chat_key da ordenação, rajadas e log de entregas
+				if normalized, err = extractor.Normalize(eventData); err == nil {
+					evt = normalized.Info()
+				} else {
+					normalized = nil
+				}
+				shipped := ingest.Event{Type: ingest.EventConverted, ClientID: client.ID, SecretID: secretID, Chat: eventInfo.GetFinalChat(), Conversions: eventInfo.Conversions}
+				if evt != nil {
+					shipped.EventID = evt.ID
+				}
+				d.Ingest.Ship(shipped)
+			}
+		}
+	}
+
+	// Regras avaliadas sobre o evento já convertido: padrões de chat/sender usam o JID real
+	if evt != nil {
+		if rule, ok := webhook.EvaluateRules(client.EffectiveFilterRules(), evt); ok {
+			switch rule.Action {
+			case models.RuleDrop:
+				logger.Info("filtered", "rule", rule.Name, "type", evt.Type, "chat", evt.Chat)
+				status, reason := "ignored_by_rule", "rule"
+				if rule.Name == models.DefaultGroupRuleName {
+					status, reason = "ignored_group_message", "group"
+				}
+				d.Metrics.Ignored(client.ID, reason)
+				d.Ingest.Ship(ingest.Event{Type: ingest.EventFiltered, ClientID: client.ID, SecretID: secretID, EventID: evt.ID, Chat: evt.Chat, Reason: reason})
+				return outcome{Code: http.StatusOK, JSON: gin.H{"status": status, "rule": rule.Name}}
+			case models.RuleRoute:
+				routeURL = rule.RouteURL
+			}
+		}
+	}
+
+	// Dedup por ID da mensagem: retries do provider são confirmados sem reencaminhar
+	if evt != nil && d.Deduper != nil && d.Deduper.Seen(c.Request.Context(), secretID, evt.ID) {
+		logger.Info("duplicate_ignored", "message_id", evt.ID)
+		d.Metrics.Ignored(client.ID, "duplicate")
+		d.Ingest.Ship(ingest.Event{Type: ingest.EventFiltered, ClientID: client.ID, SecretID: secretID, EventID: evt.ID, Chat: evt.Chat, Reason: "duplicate"})
+		return outcome{Code: http.StatusOK, JSON: gin.H{"status": "duplicate_ignored"}}
+	}
+	// Evento não entregue nem gravado na outbox/DLQ: libera o dedup para o retry do provider não ser descartado.
+	// O status HTTP não basta: um 5xx do destino pode já estar no DLQ e o retry duplicaria a mensagem.
+	if evt != nil && d.Deduper != nil {
+		defer func() {
+			if !out.Stored {
+				logger.Warn("dedup_released", "message_id", evt.ID, "status", out.Code)
+				d.Deduper.Release(context.WithoutCancel(c.Request.Context()), secretID, evt.ID)
+			}
+		}()
+	}
+
+	if contentType == "" {
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	// Clientes com payloadFormat normalized recebem o evento canônico em JSON
+	if client.PayloadFormat == models.PayloadNormalized {
+		var payload []byte
+		var err error
+		if normalized == nil {
+			normalized, err = extractor.Normalize(eventData)
+		}
+		if err == nil {
+			payload, err = json.Marshal(normalized)
+		}
+		if err != nil {
+			logger.Error("Erro ao normalizar evento, encaminhando original", "err", err)
+		} else {
+			dataToSend = payload
+			contentType = "application/json"
+		}
+	}
+
+	// Rajadas do mesmo chat/sender são seguradas pela janela do cliente e saem num único payload
+	if client.BurstWindowSeconds > 0 && d.Aggregator != nil && burstable(evt, normalized) {
+		entry := aggregate.Entry{SecretID: secretID, JSONData: eventData, Info: evt, RouteURL: routeURL}
+		if client.PayloadFormat == models.PayloadNormalized && json.Valid(dataToSend) {
+			entry.Payload = dataToSend
+		} else {
+			entry.Payload = json.RawMessage(webhook.DecodeJSONData(eventData))
+		}
+		if normalized.Timestamp != nil {
+			entry.Timestamp = *normalized.Timestamp
+		}
+		if json.Valid(entry.Payload) && d.Aggregator.Add(c.Request.Context(), entry, time.Duration(client.BurstWindowSeconds)*time.Second) {
+			logger.Debug("burst_buffered", "chat", evt.Chat)
+			return outcome{Code: http.StatusAccepted, JSON: gin.H{"status": "aggregated"}, Stored: true}
+		}
+	}
+
+	// Fan-out: rota explícita da regra ou destinos cujo selector casa com o evento
+	targets := selectTargets(client, evt, routeURL)
+	logger.Info("webhook_send", "size", len(dataToSend), "targets", len(targets))
+	// Entrega ordenada sempre passa pela fila: o worker só libera a frente de cada chat
+	if client.DeliveryMode == models.DeliveryAsync || (client.OrderedDelivery && evt != nil && evt.Chat != "") {
+		var eventAt *time.Time
+		if normalized != nil {
+			eventAt = normalized.Timestamp
+		}
+		return enqueueAll(c, d, client, targets, evt, contentType, dataToSend, eventAt)
+	}
+	if len(targets) == 1 {
+		return forwardOne(c, d, client, targets[0], evt, contentType, dataToSend)
+	}
+	return forwardMany(c, d, client, targets, evt, contentType, dataToSend)
+}
+
+// recordEvent emite o registro estruturado do evento processado (METRICS_MODE=events+summary)
+func recordEvent(ctx context.Context, d Dependencies, client *models.Client, extractor webhook.Extractor, evt *webhook.EventInfo, out outcome, start time.Time) {
+	if d.Metrics.Mode() != metrics.ModeEvents {
+		return
+	}
+	rec := metrics.Event{
+		Client:    client.ID,
+		Provider:  extractor.Name(),
+		Outcome:   "forwarded",
+		Code:      out.Code,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if status, ok := out.JSON["status"].(string); ok {
+		rec.Outcome = status
+	} else if _, ok := out.JSON["error"]; ok {
+		rec.Outcome = "error"
+	}
+	if evt != nil {
+		rec.Type, rec.MessageID, rec.Chat, rec.IsGroup = evt.Type, evt.ID, evt.Chat, evt.IsGroup
+	}
+	d.Metrics.Event(ctx, rec)
+}
+
+// handleWebhookVerify responde ao handshake GET da Meta Cloud API (hub.mode, hub.verify_token, hub.challenge)
+func handleWebhookVerify(c *gin.Context, d Dependencies) {
+	secretID := strings.TrimSpace(c.Param("secretId"))
+	client, ok := d.Resolver.Resolve(c.Request.Context(), secretID)
+	// Handshake só existe para clientes Meta: os demais respondem como se a rota não existisse
+	if !ok || client.Provider != webhook.MetaProvider {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cliente não encontrado"})
+		return
+	}
+	token := c.Query("hub.verify_token")
+	if c.Query("hub.mode") != "subscribe" || client.MetaVerifyToken == "" ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(client.MetaVerifyToken)) != 1 {
+		logging.FromContext(c.Request.Context()).Warn("verify_failed", "secret_id", secretID, "mode", c.Query("hub.mode"))
+		c.JSON(http.StatusForbidden, gin.H{"error": "verificação falhou"})
+		return
+	}
+	logging.FromContext(c.Request.Context()).Info("verify_ok", "secret_id", secretID)
+	c.String(http.StatusOK, c.Query("hub.challenge"))
+}
@@ -0,0 +1,207 @@
+package router
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/dedup"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/delivery"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/ratelimit"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/service"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
+)
+
+const testSecretID = "9f0c7a52-2f4e-4b7a-9a43-0d8f3a1e6b11"
+
+// testEvent é um evento wuzapi mínimo (form urlencoded com jsonData)
+func testEvent(messageID string) string {
+	jsonData := `{"type":"Message","event":{"Info":{"Chat":"5511987654321@s.whatsapp.net","Sender":"5511987654321@s.whatsapp.net","IsFromMe":false,"IsGroup":false,"ID":"` + messageID + `","Type":"text"},"Message":{"conversation":"oi"}}}`
+	return url.Values{"jsonData": {jsonData}}.Encode()
+}
+
+// newWebhookRouter monta o data-plane com dedup em memória e a outbox fake
+func newWebhookRouter(t *testing.T, client *models.Client, outbox *memOutbox, opts delivery.Options) *gin.Engine {
+	t.Helper()
+	return newWebhookRouterWith(t, client, outbox, opts, func(*Dependencies) {})
+}
+
+// newWebhookRouterWith permite ajustar as dependências antes do Register
+func newWebhookRouterWith(t *testing.T, client *models.Client, outbox *memOutbox, opts delivery.Options, configure func(*Dependencies)) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d := Dependencies{
+		Resolver:   service.NewClientResolver(cache.NewMemoryCache[string, *models.Client](time.Minute), stubClients{client: client}, nil),
+		Limiter:    ratelimit.NewLimiter(),
+		Deduper:    dedup.NewDeduper(nil, time.Hour, logger),
+		Dispatcher: delivery.NewDispatcher(outbox, http.DefaultClient, nil, nil, opts, logger),
+		HTTPClient: http.DefaultClient,
+		Logger:     logger,
+	}
+	configure(&d)
+	r := gin.New()
+	Register(r, d)
+	return r
+}
+
+// okDestination responde 200 e conta as chamadas
+func okDestination(t *testing.T, hits *atomic.Int32) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func postEvent(r *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook/"+testSecretID, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+// failingDestination responde 500 e conta as chamadas
+func failingDestination(t *testing.T, hits *atomic.Int32) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// Um 5xx que já foi para o DLQ não pode liberar o dedup: o retry do provider duplicaria a mensagem
+func TestDedupKeptWhenDeadLettered(t *testing.T) {
+	tests := []struct {
+		name     string
+		dests    int
+		wantCode int
+	}{
+		// forwardOne repassa o 500 do destino após mover para o DLQ (max_attempts=1)
+		{"um destino", 0, http.StatusInternalServerError},
+		// forwardMany responde 502 quando todos os destinos falham, mesmo já no DLQ
+		{"vários destinos", 2, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			client := &models.Client{ID: "c1", SecretID: testSecretID, WebhookURL: failingDestination(t, &hits), IsActive: true}
+			for i := 0; i < tt.dests; i++ {
+				client.Destinations = append(client.Destinations, models.Destination{ID: "d" + strconv.Itoa(i), Name: "dest", URL: client.WebhookURL, IsActive: true})
+			}
+			outbox := &memOutbox{}
+			r := newWebhookRouter(t, client, outbox, delivery.Options{MaxAttempts: 1})
+
+			if rec := postEvent(r, testEvent("3EB0DLQ")); rec.Code != tt.wantCode {
+				t.Fatalf("1ª entrega: status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			wantDead := max(tt.dests, 1)
+			if len(outbox.dead) != wantDead {
+				t.Fatalf("dead letters = %d, want %d", len(outbox.dead), wantDead)
+			}
+
+			rec := postEvent(r, testEvent("3EB0DLQ"))
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "duplicate_ignored") {
+				t.Fatalf("retry do provider: status = %d, body = %s; want duplicate_ignored", rec.Code, rec.Body.String())
+			}
+			if got := int(hits.Load()); got != wantDead {
+				t.Fatalf("destino recebeu %d chamadas, want %d", got, wantDead)
+			}
+			if len(outbox.dead) != wantDead {
+				t.Fatalf("retry gerou novo dead letter: %d", len(outbox.dead))
+			}
+		})
+	}
+}
+
+// Sem outbox e com o destino falhando nada ficou registrado: o retry do provider deve passar
+func TestDedupReleasedWhenNothingStored(t *testing.T) {
+	var hits atomic.Int32
+	client := &models.Client{ID: "c1", SecretID: testSecretID, WebhookURL: failingDestination(t, &hits), IsActive: true}
+	r := newWebhookRouter(t, client, &memOutbox{failEnqueue: true}, delivery.Options{MaxAttempts: 1})
+
+	for i := 1; i <= 2; i++ {
+		if rec := postEvent(r, testEvent("3EB0LOST")); rec.Code != http.StatusInternalServerError {
+			t.Fatalf("tentativa %d: status = %d, want 500 (%s)", i, rec.Code, rec.Body.String())
+		}
+	}
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("destino recebeu %d chamadas, want 2 (dedup não liberado)", got)
+	}
+}
+
+// Eventos sem @lid e de outros providers não passam pelo conversor nem consultam o store
+func TestLIDConversionSkipped(t *testing.T) {
+	var hits atomic.Int32
+	store := &countingLIDStore{}
+	tests := []struct {
+		name        string
+		provider    string
+		contentType string
+		body        string
+	}{
+		{"wuzapi sem LID", "", "application/x-www-form-urlencoded", testEvent("3EB0NOLID")},
+		{"zapi com LID", "zapi", "application/json", `{"type":"ReceivedCallback","messageId":"Z1","phone":"5511987654321","senderLid":"81896604192873@lid","text":{"message":"oi"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &models.Client{ID: "c1", SecretID: testSecretID, WebhookURL: okDestination(t, &hits), Provider: tt.provider, ProviderAPIToken: "tok", IsActive: true}
+			r := newWebhookRouterWith(t, client, &memOutbox{}, delivery.Options{}, func(d *Dependencies) { d.LIDMappings = store })
+			req := httptest.NewRequest(http.MethodPost, "/webhook/"+testSecretID, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+			}
+			if store.lookups != 0 || store.saves != 0 {
+				t.Fatalf("store consultado: lookups=%d saves=%d", store.lookups, store.saves)
+			}
+		})
+	}
+}
+
+func TestWebhookVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		secretID string
+		token    string
+		code     int
+	}{
+		{"meta com token correto", webhook.MetaProvider, testSecretID, "vt", http.StatusOK},
+		{"meta com token errado", webhook.MetaProvider, testSecretID, "errado", http.StatusForbidden},
+		{"cliente wuzapi: rota inexistente", webhook.DefaultProvider, testSecretID, "vt", http.StatusNotFound},
+		{"provider vazio (wuzapi)", "", testSecretID, "vt", http.StatusNotFound},
+		{"secretId desconhecido", webhook.MetaProvider, "outro", "vt", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &models.Client{ID: "c1", SecretID: testSecretID, Provider: tt.provider, MetaVerifyToken: "vt", IsActive: true}
+			r := newWebhookRouter(t, client, &memOutbox{}, delivery.Options{})
+			q := url.Values{"hub.mode": {"subscribe"}, "hub.verify_token": {tt.token}, "hub.challenge": {"42"}}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/webhook/"+tt.secretID+"?"+q.Encode(), nil))
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.code, rec.Body.String())
+			}
+			if tt.code == http.StatusOK && rec.Body.String() != "42" {
+				t.Fatalf("challenge = %q", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	prefix      = "v1:"
+	plainPrefix = "plain:"
+)
+
+// ErrNoKey indica que ENCRYPTION_KEY não foi configurada
+var ErrNoKey = errors.New("ENCRYPTION_KEY não configurada")
+
+// Box cifra/decifra segredos de clientes com AES-256-GCM
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox aceita chave de 32 bytes em base64 ou hex. Chave vazia gera um Box sem cifra
+// (valores gravados com prefixo "plain:"); o main só aceita isso com APP_ENV=development.
+func NewBox(key string) (*Box, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return &Box{}, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		raw, err = hex.DecodeString(key)
+	}
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("ENCRYPTION_KEY deve ter 32 bytes (base64 ou hex)")
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Enabled indica se há chave configurada
+func (b *Box) Enabled() bool { return b != nil && b.aead != nil }
+
+// Encrypt retorna "v1:" + base64(nonce|ciphertext). Texto vazio continua vazio.
+func (b *Box) Encrypt(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	if !b.Enabled() {
+		return plainPrefix + plain, nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverte Encrypt
+func (b *Box) Decrypt(enc string) (string, error) {
+	if enc == "" {
+		return "", nil
+	}
+	// Valores gravados sem chave continuam legíveis após configurar ENCRYPTION_KEY
+	if strings.HasPrefix(enc, plainPrefix) {
+		return strings.TrimPrefix(enc, plainPrefix), nil
+	}
+	if !b.Enabled() {
+		return "", ErrNoKey
+	}
+	if !strings.HasPrefix(enc, prefix) {
+		return "", fmt.Errorf("formato de segredo desconhecido")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(enc, prefix))
+	if err != nil {
+		return "", err
+	}
+	ns := b.aead.NonceSize()
+	if len(raw) < ns {
+		return "", fmt.Errorf("segredo truncado")
+	}
+	plain, err := b.aead.Open(nil, raw[:ns], raw[ns:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	key1 = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	key2 = hex.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+)
+
+func mustBox(t *testing.T, key string) *Box {
+	t.Helper()
+	b, err := NewBox(key)
+	if err != nil {
+		t.Fatalf("NewBox: %v", err)
+	}
+	return b
+}
+
+func TestNewBoxKeyFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		enabled bool
+		wantErr bool
+	}{
+		{"base64", key1, true, false},
+		{"hex", key2, true, false},
+		{"vazia", "  ", false, false},
+		{"curta", base64.StdEncoding.EncodeToString([]byte("curta")), false, true},
+		{"inválida", "não-é-chave", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBox(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && b.Enabled() != tt.enabled {
+				t.Fatalf("Enabled = %v, want %v", b.Enabled(), tt.enabled)
+			}
+		})
+	}
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	b := mustBox(t, key1)
+	for _, plain := range []string{"tok-123", "app secret com espaços e acentuação", strings.Repeat("x", 4096)} {
+		enc, err := b.Encrypt(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(enc, prefix) || strings.Contains(enc, plain) {
+			t.Fatalf("valor não cifrado: %q", enc)
+		}
+		got, err := b.Decrypt(enc)
+		if err != nil || got != plain {
+			t.Fatalf("Decrypt = %q, %v; want %q", got, err, plain)
+		}
+	}
+
+	// Nonce aleatório: o mesmo texto gera cifras diferentes
+	a, _ := b.Encrypt("tok")
+	c, _ := b.Encrypt("tok")
+	if a == c {
+		t.Fatal("cifras idênticas para o mesmo texto")
+	}
+
+	if enc, _ := b.Encrypt(""); enc != "" {
+		t.Fatalf("texto vazio cifrado: %q", enc)
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	enc, err := mustBox(t, key1).Encrypt("tok-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mustBox(t, key2).Decrypt(enc); err == nil {
+		t.Fatal("Decrypt com outra chave deveria falhar")
+	}
+	if _, err := mustBox(t, "").Decrypt(enc); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("Decrypt sem chave = %v, want ErrNoKey", err)
+	}
+}
+
+func TestDecryptTamperedOrUnknown(t *testing.T) {
+	b := mustBox(t, key1)
+	enc, _ := b.Encrypt("tok-123")
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(enc, prefix))
+	raw[len(raw)-1] ^= 0xff
+	tampered := prefix + base64.StdEncoding.EncodeToString(raw)
+
+	for _, enc := range []string{tampered, prefix + "AAAA", "v2:abc", "texto-solto"} {
+		if _, err := b.Decrypt(enc); err == nil {
+			t.Errorf("Decrypt(%q) deveria falhar", enc)
+		}
+	}
+}
+
+// Valores gravados sem chave (prefixo plain:) continuam legíveis depois de configurar a chave
+func TestDecryptLegacyPlain(t *testing.T) {
+	enc, err := mustBox(t, "").Encrypt("tok-legado")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc != plainPrefix+"tok-legado" {
+		t.Fatalf("Encrypt sem chave = %q", enc)
+	}
+	for _, key := range []string{"", key1} {
+		if got, err := mustBox(t, key).Decrypt(enc); err != nil || got != "tok-legado" {
+			t.Fatalf("Decrypt(plain:) com chave %q = %q, %v", key, got, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+)
+
+// apiKeyPrefix identifica as chaves do serviço (ex.: em scanners de segredos)
+const apiKeyPrefix = "msdr_"
+
+// apiKeyDisplayLen é quanto da chave fica visível na listagem para identificá-la
+const apiKeyDisplayLen = 12
+
+// ErrInvalidAPIKey indica chave inexistente ou revogada
+var ErrInvalidAPIKey = errors.New("chave inválida")
+
+// APIKeyService emite, autentica e revoga chaves da API admin. O cache (por hash) evita
+// ir ao banco a cada requisição; a revogação o invalida na própria instância e as demais
+// deixam de aceitar a chave quando a entrada expira.
+type APIKeyService interface {
+	Create(ctx context.Context, name string, scopes []models.APIKeyScope) (*models.APIKey, string, error)
+	Authenticate(ctx context.Context, token string) (*models.APIKey, error)
+	List(ctx context.Context) ([]models.APIKey, error)
+	Revoke(ctx context.Context, id string) error
+}
+
+type apiKeyService struct {
+	repo   repository.APIKeyRepository
+	cache  *cache.MemoryCache[string, *models.APIKey]
+	logger *slog.Logger
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, c *cache.MemoryCache[string, *models.APIKey], logger *slog.Logger) APIKeyService {
+	return &apiKeyService{repo: repo, cache: c, logger: logger}
+}
+
+// HashAPIKey é o hash persistido; chaves têm 256 bits aleatórios, então SHA-256 sem salt basta
+func HashAPIKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeScopes(scopes []models.APIKeyScope) ([]models.APIKeyScope, error) {
+	requested := map[models.APIKeyScope]bool{}
+	for _, s := range scopes {
+		s = models.APIKeyScope(strings.ToLower(strings.TrimSpace(string(s))))
+		valid := false
+		for _, v := range models.APIKeyScopes {
+			valid = valid || s == v
+		}
+		if !valid {
+			return nil, fmt.Errorf("escopo inválido: %q (use read, write ou resolve)", s)
+		}
+		requested[s] = true
+	}
+	var out []models.APIKeyScope
+	for _, v := range models.APIKeyScopes {
+		if requested[v] {
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("informe ao menos um escopo")
+	}
+	return out, nil
+}
+
+// Create gera a chave e retorna o texto em claro, que não é armazenado nem pode ser recuperado depois
+func (s *apiKeyService) Create(ctx context.Context, name string, scopes []models.APIKeyScope) (*models.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("name é obrigatório")
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	token := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	k := &models.APIKey{Name: name, Prefix: token[:apiKeyDisplayLen], Scopes: scopes}
+	if err := s.repo.Create(ctx, k, HashAPIKey(token)); err != nil {
+		return nil, "", err
+	}
+	return k, token, nil
+}
+
+// Authenticate retorna a chave ativa correspondente ao token ou ErrInvalidAPIKey
+func (s *apiKeyService) Authenticate(ctx context.Context, token string) (*models.APIKey, error) {
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	hash := HashAPIKey(token)
+	if k, ok := s.cache.Get(hash); ok {
+		return k, nil
+	}
+	k, err := s.repo.GetByHash(ctx, hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if k.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	// last_used_at é atualizado só na carga do cache, no máximo uma vez por TTL
+	if err := s.repo.TouchLastUsed(ctx, k.ID); err != nil {
+		s.logger.Warn("Erro ao atualizar last_used_at da chave", "api_key_id", k.ID, "err", err)
+	}
+	s.cache.Set(hash, k)
+	return k, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.List(ctx)
+}
+
+// Revoke desativa a chave; pgx.ErrNoRows se não existe ou já estava revogada
+func (s *apiKeyService) Revoke(ctx context.Context, id string) error {
+	hash, err := s.repo.Revoke(ctx, strings.TrimSpace(id))
+	if err != nil {
+		return err
+	}
+	s.cache.Delete(hash)
+	return nil
+}
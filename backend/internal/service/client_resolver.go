@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/tracing"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// ClientResolver resolve o cliente do data-plane: cache -> ENV -> repo
+type ClientResolver struct {
+	Cache          *cache.MemoryCache[string, *models.Client]
+	ClientSvc      ClientService
+	DestinationSvc DestinationService
+	// SigningGrace mantém o segredo anterior válido após a rotação
+	SigningGrace time.Duration
+}
+
+func NewClientResolver(c *cache.MemoryCache[string, *models.Client], svc ClientService, dstSvc DestinationService) *ClientResolver {
+	return &ClientResolver{Cache: c, ClientSvc: svc, DestinationSvc: dstSvc}
+}
+
+// Resolve retorna o cliente ativo para o secretId. O valor retornado é compartilhado pelo cache e não deve ser alterado.
+func (r *ClientResolver) Resolve(ctx context.Context, secretID string) (*models.Client, bool) {
+	if v, ok := r.Cache.Get(secretID); ok {
+		return v, true
+	}
+	if cli, ok := ClientFromEnv(secretID); ok {
+		r.Cache.Set(secretID, cli)
+		return cli, true
+	}
+	return r.lookup(ctx, secretID)
+}
+
+// lookup busca cliente e destinos no Postgres (cache miss) e cacheia o resultado
+func (r *ClientResolver) lookup(ctx context.Context, secretID string) (*models.Client, bool) {
+	ctx, span := tracing.Start(ctx, "client_resolver.db_lookup")
+	defer span.End()
+	cli, err := r.ClientSvc.GetBySecretID(ctx, secretID)
+	if err != nil || cli == nil || !cli.IsActive {
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return nil, false
+	}
+	if r.DestinationSvc != nil {
+		dsts, err := r.DestinationSvc.ListByClient(ctx, cli.ID)
+		if err != nil {
+			span.RecordError(err)
+			// Sem destinos extras não cacheia, para tentar de novo na próxima requisição
+			cli.Destinations = nil
+			return cli, true
+		}
+		for _, dst := range dsts {
+			if dst.IsActive {
+				cli.Destinations = append(cli.Destinations, dst)
+			}
+		}
+	}
+	r.Cache.Set(secretID, cli)
+	return cli, true
+}
+
+// Invalidate remove o secretId do cache (ex.: após update/delete via admin)
+func (r *ClientResolver) Invalidate(secretID string) {
+	r.Cache.Delete(secretID)
+}
+
+// SigningSecrets retorna os segredos HMAC vigentes do cliente (vazio = entrega sem assinatura)
+func (r *ClientResolver) SigningSecrets(ctx context.Context, secretID string) []string {
+	cli, ok := r.Resolve(ctx, secretID)
+	if !ok {
+		return nil
+	}
+	return cli.SigningSecrets(r.SigningGrace)
+}
+
+// ClientFromEnv monta um cliente sintético a partir do formato CLIENT_{UUID}
+func ClientFromEnv(secretID string) (*models.Client, bool) {
+	url, ok := webhookURLFromEnv(secretID)
+	if !ok {
+		return nil, false
+	}
+	return &models.Client{
+		SecretID:        secretID,
+		WebhookURL:      url,
+		Plan:            models.PlanFREE,
+		RateLimitPerMin: 60,
+		IsActive:        true,
+	}, true
+}
+
+func webhookURLFromEnv(secretID string) (string, bool) {
+	underscored := strings.ReplaceAll(secretID, "-", "_")
+	lower := "CLIENT_" + strings.ToLower(underscored)
+	upper := "CLIENT_" + strings.ToUpper(underscored)
+	if v := strings.TrimSpace(os.Getenv(lower)); v != "" {
+		return v, true
+	}
+	if v := strings.TrimSpace(os.Getenv(upper)); v != "" {
+		return v, true
+	}
+	return "", false
+}
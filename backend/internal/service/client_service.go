@@ -3,9 +3,12 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
 )
 
 type ClientService interface {
@@ -15,6 +18,8 @@ type ClientService interface {
 	List(ctx context.Context, limit, offset int) ([]models.Client, error)
 	Update(ctx context.Context, c *models.Client) error
 	Delete(ctx context.Context, id string) error
+	RotateSigningSecret(ctx context.Context, id string) (string, error)
+	UpdateFilterRules(ctx context.Context, id string, rules []models.FilterRule) error
 }
 
 type clientService struct {
@@ -25,7 +30,8 @@ func NewClientService(repo repository.ClientRepository) ClientService {
 	return &clientService{repo: repo}
 }
 
-func (s *clientService) Create(ctx context.Context, c *models.Client) error {
+// validateClient aplica os defaults e valida os campos comuns a Create e Update
+func validateClient(c *models.Client) error {
 	if c.Name == "" || c.WebhookURL == "" {
 		return errors.New("name e webhookUrl são obrigatórios")
 	}
@@ -35,10 +41,83 @@ func (s *clientService) Create(ctx context.Context, c *models.Client) error {
 	if c.Plan == "" {
 		c.Plan = models.PlanFREE
 	}
+	if err := normalizePayloadFormat(c); err != nil {
+		return err
+	}
+	if err := normalizeProvider(c); err != nil {
+		return err
+	}
+	if err := requireMetaAppSecret(c); err != nil {
+		return err
+	}
+	if err := normalizeDeliveryMode(c); err != nil {
+		return err
+	}
+	if c.BurstWindowSeconds < 0 || c.BurstWindowSeconds > 60 {
+		return errors.New("burstWindowSeconds deve estar entre 0 e 60")
+	}
+	switch c.HeadOfLinePolicy {
+	case "":
+		c.HeadOfLinePolicy = models.HOLBlock
+	case models.HOLBlock, models.HOLSkip:
+	default:
+		return errors.New("headOfLinePolicy inválida (block|skip)")
+	}
+	return nil
+}
+
+func (s *clientService) Create(ctx context.Context, c *models.Client) error {
+	if err := validateClient(c); err != nil {
+		return err
+	}
 	c.IsActive = true
 	return s.repo.Create(ctx, c)
 }
 
+// normalizePayloadFormat aplica o default (raw) e rejeita formatos desconhecidos
+func normalizePayloadFormat(c *models.Client) error {
+	switch c.PayloadFormat {
+	case "":
+		c.PayloadFormat = models.PayloadRaw
+	case models.PayloadRaw, models.PayloadNormalized:
+	default:
+		return errors.New("payloadFormat inválido (raw|normalized)")
+	}
+	return nil
+}
+
+// normalizeDeliveryMode aplica o default (sync) e rejeita modos desconhecidos
+func normalizeDeliveryMode(c *models.Client) error {
+	switch c.DeliveryMode {
+	case "":
+		c.DeliveryMode = models.DeliverySync
+	case models.DeliverySync, models.DeliveryAsync:
+	default:
+		return errors.New("deliveryMode inválido (sync|async)")
+	}
+	return nil
+}
+
+// normalizeProvider aplica o provider padrão e exige um extractor registrado
+func normalizeProvider(c *models.Client) error {
+	c.Provider = strings.ToLower(strings.TrimSpace(c.Provider))
+	if c.Provider == "" {
+		c.Provider = webhook.DefaultProvider
+	}
+	if _, ok := webhook.LookupExtractor(c.Provider); !ok {
+		return fmt.Errorf("provider inválido (%s)", strings.Join(webhook.Providers(), "|"))
+	}
+	return nil
+}
+
+// requireMetaAppSecret exige o app secret da Meta: sem ele o X-Hub-Signature-256 não é verificável
+func requireMetaAppSecret(c *models.Client) error {
+	if c.Provider == webhook.MetaProvider && strings.TrimSpace(c.MetaAppSecret) == "" {
+		return errors.New("metaAppSecret é obrigatório para provider meta")
+	}
+	return nil
+}
+
 func (s *clientService) GetByID(ctx context.Context, id string) (*models.Client, error) {
 	return s.repo.GetByID(ctx, id)
 }
@@ -55,14 +134,8 @@ func (s *clientService) Update(ctx context.Context, c *models.Client) error {
 	if c.ID == "" {
 		return errors.New("id é obrigatório")
 	}
-	if c.Name == "" || c.WebhookURL == "" {
-		return errors.New("name e webhookUrl são obrigatórios")
-	}
-	if c.RateLimitPerMin <= 0 {
-		c.RateLimitPerMin = 60
-	}
-	if c.Plan == "" {
-		c.Plan = models.PlanFREE
+	if err := validateClient(c); err != nil {
+		return err
 	}
 	return s.repo.Update(ctx, c)
 }
@@ -70,3 +143,20 @@ func (s *clientService) Update(ctx context.Context, c *models.Client) error {
 func (s *clientService) Delete(ctx context.Context, id string) error {
 	return s.repo.Delete(ctx, id)
 }
+
+func (s *clientService) RotateSigningSecret(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return "", errors.New("id é obrigatório")
+	}
+	return s.repo.RotateSigningSecret(ctx, id)
+}
+
+func (s *clientService) UpdateFilterRules(ctx context.Context, id string, rules []models.FilterRule) error {
+	if id == "" {
+		return errors.New("id é obrigatório")
+	}
+	if err := webhook.ValidateRules(rules); err != nil {
+		return err
+	}
+	return s.repo.UpdateFilterRules(ctx, id, rules)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
+)
+
+type DestinationService interface {
+	Create(ctx context.Context, dst *models.Destination) error
+	GetByID(ctx context.Context, clientID, id string) (*models.Destination, error)
+	ListByClient(ctx context.Context, clientID string) ([]models.Destination, error)
+	Update(ctx context.Context, dst *models.Destination) error
+	Delete(ctx context.Context, clientID, id string) error
+}
+
+type destinationService struct {
+	repo repository.DestinationRepository
+}
+
+func NewDestinationService(repo repository.DestinationRepository) DestinationService {
+	return &destinationService{repo: repo}
+}
+
+func validateDestination(dst *models.Destination) error {
+	if dst.ClientID == "" {
+		return errors.New("clientId é obrigatório")
+	}
+	if dst.Name == "" || dst.URL == "" {
+		return errors.New("name e url são obrigatórios")
+	}
+	if !strings.HasPrefix(dst.URL, "http://") && !strings.HasPrefix(dst.URL, "https://") {
+		return errors.New("url inválida")
+	}
+	return webhook.ValidateMatch(dst.Selector)
+}
+
+func (s *destinationService) Create(ctx context.Context, dst *models.Destination) error {
+	if err := validateDestination(dst); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, dst)
+}
+
+func (s *destinationService) GetByID(ctx context.Context, clientID, id string) (*models.Destination, error) {
+	return s.repo.GetByID(ctx, clientID, id)
+}
+
+func (s *destinationService) ListByClient(ctx context.Context, clientID string) ([]models.Destination, error) {
+	return s.repo.ListByClient(ctx, clientID)
+}
+
+func (s *destinationService) Update(ctx context.Context, dst *models.Destination) error {
+	if dst.ID == "" {
+		return errors.New("id é obrigatório")
+	}
+	if err := validateDestination(dst); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, dst)
+}
+
+func (s *destinationService) Delete(ctx context.Context, clientID, id string) error {
+	return s.repo.Delete(ctx, clientID, id)
+}
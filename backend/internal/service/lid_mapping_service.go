@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
+	"github.com/jackc/pgx/v5"
+)
+
+// LIDMappingService guarda mapeamentos LID->JID no Postgres com cache em memória na frente.
+// Implementa webhook.LIDStore para o conversor.
+type LIDMappingService interface {
+	Lookup(ctx context.Context, lid string) (string, bool)
+	Save(ctx context.Context, lid, jid, source string)
+	Get(ctx context.Context, lid string) (*models.LIDMapping, error)
+	Correct(ctx context.Context, lid, jid string) (*models.LIDMapping, error)
+	Delete(ctx context.Context, lid string) error
+	// Start sobe o worker que grava os mapeamentos de Save; Stop grava o que restou na fila
+	Start()
+	Stop(ctx context.Context) error
+}
+
+// saveQueueSize limita os mapeamentos aguardando gravação; acima disso Save descarta (e loga)
+const saveQueueSize = 1000
+
+type lidMappingService struct {
+	repo   repository.LIDMappingRepository
+	cache  *cache.MemoryCache[string, string]
+	misses *cache.MemoryCache[string, struct{}]
+	logger *slog.Logger
+
+	saves    chan models.LIDMapping
+	stopChan chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewLIDMappingService cria o serviço; misses guarda por pouco tempo os LIDs sem mapeamento,
+// para um LID desconhecido não consultar o Postgres a cada evento
+func NewLIDMappingService(repo repository.LIDMappingRepository, c *cache.MemoryCache[string, string], misses *cache.MemoryCache[string, struct{}], logger *slog.Logger) LIDMappingService {
+	return &lidMappingService{
+		repo:     repo,
+		cache:    c,
+		misses:   misses,
+		logger:   logger,
+		saves:    make(chan models.LIDMapping, saveQueueSize),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *lidMappingService) Lookup(ctx context.Context, lid string) (string, bool) {
+	lid = strings.TrimSpace(lid)
+	if jid, ok := s.cache.Get(lid); ok {
+		return jid, true
+	}
+	if _, ok := s.misses.Get(lid); ok {
+		return "", false
+	}
+	m, err := s.repo.Get(ctx, lid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.misses.Set(lid, struct{}{})
+		return "", false
+	}
+	if err != nil || m == nil {
+		return "", false
+	}
+	s.cache.Set(lid, m.JID)
+	return m.JID, true
+}
+
+// Save registra um mapeamento vindo da API ou aprendido de um evento. A gravação no Postgres sai
+// do caminho da requisição (worker de Start); o cache já responde com o par novo. Erros só são logados.
+func (s *lidMappingService) Save(_ context.Context, lid, jid, source string) {
+	lid, jid = strings.TrimSpace(lid), strings.TrimSpace(jid)
+	if !webhook.IsLID(lid) || !webhook.IsJID(jid) {
+		return
+	}
+	cached, ok := s.cache.Get(lid)
+	if ok && cached == jid {
+		return
+	}
+	s.misses.Delete(lid)
+	if !ok {
+		// Um valor diferente no cache (ex.: correção manual) só é trocado depois do upsert
+		s.cache.Set(lid, jid)
+	}
+	select {
+	case s.saves <- models.LIDMapping{LID: lid, JID: jid, Source: source}:
+	default:
+		s.logger.Warn("Fila de mapeamentos LID cheia, mapeamento descartado", "lid", lid, "source", source)
+	}
+}
+
+// Start consome a fila de Save até Stop
+func (s *lidMappingService) Start() {
+	go func() {
+		defer close(s.done)
+		for {
+			select {
+			case m := <-s.saves:
+				s.persist(m)
+			case <-s.stopChan:
+				// Grava o que já estava na fila
+				for {
+					select {
+					case m := <-s.saves:
+						s.persist(m)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop encerra o worker depois de gravar a fila, respeitando o prazo do ctx
+func (s *lidMappingService) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *lidMappingService) persist(m models.LIDMapping) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	applied, err := s.repo.Upsert(ctx, &m)
+	if err != nil {
+		s.logger.Error("Erro ao gravar mapeamento LID", "lid", m.LID, "err", err)
+		return
+	}
+	s.misses.Delete(m.LID)
+	if applied {
+		s.cache.Set(m.LID, m.JID)
+	} else {
+		// Correção manual prevaleceu; força releitura do banco
+		s.cache.Delete(m.LID)
+	}
+}
+
+func (s *lidMappingService) Get(ctx context.Context, lid string) (*models.LIDMapping, error) {
+	return s.repo.Get(ctx, strings.TrimSpace(lid))
+}
+
+// Correct grava uma correção manual, que não é sobrescrita por API/aprendizado
+func (s *lidMappingService) Correct(ctx context.Context, lid, jid string) (*models.LIDMapping, error) {
+	lid, jid = strings.TrimSpace(lid), strings.TrimSpace(jid)
+	if !webhook.IsLID(lid) {
+		return nil, errors.New("lid deve terminar com @lid")
+	}
+	if !webhook.IsJID(jid) {
+		return nil, errors.New("jid inválido")
+	}
+	if _, err := s.repo.Upsert(ctx, &models.LIDMapping{LID: lid, JID: jid, Source: models.LIDSourceManual}); err != nil {
+		return nil, err
+	}
+	s.cache.Set(lid, jid)
+	s.misses.Delete(lid)
+	return s.repo.Get(ctx, lid)
+}
+
+func (s *lidMappingService) Delete(ctx context.Context, lid string) error {
+	lid = strings.TrimSpace(lid)
+	s.cache.Delete(lid)
+	return s.repo.Delete(ctx, lid)
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+)
+
+// memLIDRepo simula lid_mappings; manual marca LIDs com correção manual (upsert não se aplica)
+type memLIDRepo struct {
+	mu      sync.Mutex
+	rows    map[string]string
+	manual  map[string]bool
+	gets    int
+	upserts int
+}
+
+func newMemLIDRepo() *memLIDRepo {
+	return &memLIDRepo{rows: map[string]string{}, manual: map[string]bool{}}
+}
+
+func (r *memLIDRepo) Get(_ context.Context, lid string) (*models.LIDMapping, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gets++
+	jid, ok := r.rows[lid]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return &models.LIDMapping{LID: lid, JID: jid}, nil
+}
+
+func (r *memLIDRepo) Upsert(_ context.Context, m *models.LIDMapping) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.upserts++
+	if r.manual[m.LID] && m.Source != models.LIDSourceManual {
+		return false, nil
+	}
+	r.rows[m.LID] = m.JID
+	return true, nil
+}
+
+func (r *memLIDRepo) Delete(_ context.Context, lid string) error {
+	r.mu.Lock()
+	delete(r.rows, lid)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *memLIDRepo) counts() (gets, upserts int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.gets, r.upserts
+}
+
+func newTestLIDService(repo *memLIDRepo) *lidMappingService {
+	return NewLIDMappingService(repo,
+		cache.NewMemoryCache[string, string](time.Hour),
+		cache.NewMemoryCache[string, struct{}](time.Minute),
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+	).(*lidMappingService)
+}
+
+func TestLookupCachesMisses(t *testing.T) {
+	repo := newMemLIDRepo()
+	s := newTestLIDService(repo)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, ok := s.Lookup(ctx, "1@lid"); ok {
+			t.Fatal("LID desconhecido encontrado")
+		}
+	}
+	if gets, _ := repo.counts(); gets != 1 {
+		t.Fatalf("Postgres consultado %d vezes, want 1", gets)
+	}
+
+	// Uma correção manual invalida a falha em cache
+	if _, err := s.Correct(ctx, "1@lid", "5511987654321@s.whatsapp.net"); err != nil {
+		t.Fatal(err)
+	}
+	if jid, ok := s.Lookup(ctx, "1@lid"); !ok || jid != "5511987654321@s.whatsapp.net" {
+		t.Fatalf("Lookup após Correct = %q, %v", jid, ok)
+	}
+}
+
+func TestSaveIsAsync(t *testing.T) {
+	repo := newMemLIDRepo()
+	s := newTestLIDService(repo)
+	ctx := context.Background()
+
+	// Falha em cache antes de aprender o par
+	s.Lookup(ctx, "1@lid")
+	s.Save(ctx, "1@lid", "5511987654321@s.whatsapp.net", models.LIDSourceLearned)
+
+	// O par já vale em memória, sem esperar o upsert
+	if jid, ok := s.Lookup(ctx, "1@lid"); !ok || jid != "5511987654321@s.whatsapp.net" {
+		t.Fatalf("Lookup após Save = %q, %v", jid, ok)
+	}
+	if _, upserts := repo.counts(); upserts != 0 {
+		t.Fatal("Save gravou no caminho da requisição")
+	}
+
+	// Stop grava o que estava na fila
+	s.Start()
+	stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := s.Stop(stopCtx); err != nil {
+		t.Fatal(err)
+	}
+	if _, upserts := repo.counts(); upserts != 1 {
+		t.Fatalf("upserts = %d, want 1", upserts)
+	}
+	if repo.rows["1@lid"] != "5511987654321@s.whatsapp.net" {
+		t.Fatalf("mapeamento não persistido: %v", repo.rows)
+	}
+}
+
+func TestSaveKeepsManualCorrection(t *testing.T) {
+	repo := newMemLIDRepo()
+	repo.rows["1@lid"] = "5511900000000@s.whatsapp.net"
+	repo.manual["1@lid"] = true
+	s := newTestLIDService(repo)
+	ctx := context.Background()
+
+	s.Save(ctx, "1@lid", "5511987654321@s.whatsapp.net", models.LIDSourceLearned)
+	s.Start()
+	stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := s.Stop(stopCtx); err != nil {
+		t.Fatal(err)
+	}
+	// Upsert recusado: o cache é descartado e a próxima leitura traz a correção manual
+	if jid, ok := s.Lookup(ctx, "1@lid"); !ok || jid != "5511900000000@s.whatsapp.net" {
+		t.Fatalf("Lookup = %q, %v; want correção manual", jid, ok)
+	}
+}
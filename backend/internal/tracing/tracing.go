@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/JoaoCarlosAssis/MS_SDR_FIX"
+	// HeaderTraceParent é o header W3C Trace Context
+	HeaderTraceParent = "traceparent"
+)
+
+// propagator W3C (traceparent/tracestate) usado na entrada e nos encaminhamentos
+var propagator = propagation.TraceContext{}
+
+// Setup registra o TracerProvider global com export OTLP/HTTP. Endpoint, headers, timeout e
+// sampler vêm das variáveis padrão OTEL_* (OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_TRACES_SAMPLER, ...).
+// Com enabled=false nada é exportado, mas o traceparent recebido continua sendo propagado.
+func Setup(ctx context.Context, enabled bool, serviceName string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagator)
+	if !enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME / OTEL_RESOURCE_ATTRIBUTES sobrescrevem o nome padrão
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return SetupWithExporter(exporter, res), nil
+}
+
+// SetupWithExporter registra um TracerProvider com o exporter informado (ex.: in-memory em testes)
+func SetupWithExporter(exporter sdktrace.SpanExporter, res *resource.Resource) func(context.Context) error {
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithBatcher(exporter)}
+	if res != nil {
+		opts = append(opts, sdktrace.WithResource(res))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator)
+	return tp.Shutdown
+}
+
+// Start abre um span com o tracer do serviço
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// End registra o erro (se houver) no span e o encerra
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Extract lê o traceparent dos headers recebidos
+func Extract(ctx context.Context, h http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// Inject grava o traceparent do span atual nos headers de saída
+func Inject(ctx context.Context, h http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(h))
+}
+
+// TraceParent serializa o contexto do span atual (vazio sem span válido), para persistir na outbox
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier.Get(HeaderTraceParent)
+}
+
+// WithTraceParent restaura um traceparent persistido como pai remoto dos próximos spans
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier{HeaderTraceParent: traceParent})
+}
+
+// TraceID retorna o trace id do span atual (vazio sem span válido), para correlacionar logs
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
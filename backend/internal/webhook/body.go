@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+)
+
+// ReplaceJSONData troca o jsonData original pelo novo no corpo recebido, preservando o
+// formato do envelope (JSON puro, envelope JSON, multipart ou urlencoded).
+func ReplaceJSONData(body []byte, contentType, oldData, newData string) ([]byte, error) {
+	if oldData == newData {
+		return body, nil
+	}
+	ctLower := strings.ToLower(contentType)
+	switch {
+	case strings.HasPrefix(ctLower, "application/json"):
+		if strings.TrimSpace(string(body)) == oldData {
+			return []byte(newData), nil
+		}
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil, fmt.Errorf("erro ao fazer parse do envelope: %w", err)
+		}
+		out, err := json.Marshal(replaceJSONString(v, oldData, newData))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao serializar envelope: %w", err)
+		}
+		return out, nil
+
+	case strings.HasPrefix(ctLower, "multipart/form-data"):
+		return replaceMultipartField(body, contentType, "jsonData", newData)
+
+	default: // urlencoded e outros
+		vals, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer parse do form: %w", err)
+		}
+		vals.Set("jsonData", newData)
+		return []byte(vals.Encode()), nil
+	}
+}
+
+// replaceJSONString percorre o envelope trocando valores (ou chaves, no caso JSON-como-chave) iguais a old
+func replaceJSONString(v any, old, new string) any {
+	switch t := v.(type) {
+	case string:
+		if t == old {
+			return new
+		}
+		return t
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			if k == old {
+				k = new
+			}
+			out[k] = replaceJSONString(val, old, new)
+		}
+		return out
+	case []any:
+		for i := range t {
+			t[i] = replaceJSONString(t[i], old, new)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// replaceMultipartField reescreve o multipart mantendo o boundary (Content-Type inalterado)
+func replaceMultipartField(body []byte, contentType, field, value string) ([]byte, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, fmt.Errorf("boundary ausente no multipart")
+	}
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		w, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == field && part.FileName() == "" {
+			_, err = io.WriteString(w, value)
+		} else {
+			_, err = io.Copy(w, part)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
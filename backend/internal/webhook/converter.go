@@ -4,11 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // LIDConvertRequest representa a requisição para conversão LID->JID
@@ -26,10 +33,26 @@ type LIDConvertResponse struct {
 	} `json:"data"`
 }
 
+// ErrLIDNotMapped indica LID fora do store sem consulta à API (sem token ou falha recente para o
+// mesmo LID); não conta como falha da API
+var ErrLIDNotMapped = errors.New("LID sem mapeamento conhecido e API não consultada")
+
+// LIDStore persiste mapeamentos LID->JID para evitar chamadas repetidas à API
+type LIDStore interface {
+	Lookup(ctx context.Context, lid string) (string, bool)
+	Save(ctx context.Context, lid, jid, source string)
+}
+
 // LIDConverter gerencia conversões LID->JID
 type LIDConverter struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// Store opcional: consultado antes da API e alimentado com resultados/pares aprendidos
+	Store LIDStore
+	// OnConvert opcional recebe cada conversão de LID com a origem ("store" ou "api") e o erro
+	OnConvert func(source string, err error)
+	// APIMisses opcional guarda por pouco tempo os LIDs que a API não converteu, para não repetir a chamada a cada evento
+	APIMisses *cache.MemoryCache[string, struct{}]
 }
 
 // NewLIDConverter cria uma nova instância do conversor (token será passado dinamicamente)
@@ -40,6 +63,46 @@ func NewLIDConverter(baseURL string) *LIDConverter {
 	}
 }
 
+// LIDConverters mantém um conversor por URL base da API; o token do cliente vai por chamada,
+// então o conversor e o HTTP client são montados uma vez e reaproveitados entre eventos
+type LIDConverters struct {
+	Store     LIDStore
+	OnConvert func(source string, err error)
+	APIMisses *cache.MemoryCache[string, struct{}]
+
+	mu         sync.Mutex
+	byBaseURL  map[string]*LIDConverter
+	httpClient *http.Client
+}
+
+func NewLIDConverters(store LIDStore, onConvert func(source string, err error), apiMisses *cache.MemoryCache[string, struct{}]) *LIDConverters {
+	return &LIDConverters{
+		Store:      store,
+		OnConvert:  onConvert,
+		APIMisses:  apiMisses,
+		byBaseURL:  make(map[string]*LIDConverter),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// For retorna o conversor da URL base, criado na primeira chamada
+func (p *LIDConverters) For(baseURL string) *LIDConverter {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.byBaseURL[baseURL]; ok {
+		return c
+	}
+	c := &LIDConverter{BaseURL: baseURL, HTTPClient: p.httpClient, Store: p.Store, OnConvert: p.OnConvert, APIMisses: p.APIMisses}
+	p.byBaseURL[baseURL] = c
+	return c
+}
+
+// HasLID indica se o payload menciona algum LID; evita parse e conversão nos eventos sem LID
+func HasLID(payload string) bool {
+	return strings.Contains(strings.ToLower(payload), "@lid")
+}
+
 // IsLID verifica se um ID está no formato LID
 func IsLID(id string) bool {
 	return strings.HasSuffix(strings.ToLower(id), "@lid")
@@ -57,6 +120,50 @@ func (c *LIDConverter) ConvertLIDToJID(ctx context.Context, lid string, apiToken
 		return lid, nil // Não é LID, retorna como está
 	}
 
+	ctx, span := tracing.Start(ctx, "lid.convert")
+	if c.Store != nil {
+		if jid, ok := c.Store.Lookup(ctx, lid); ok {
+			c.observe("store", nil)
+			span.SetAttributes(attribute.String("lid.source", "store"))
+			span.End()
+			return jid, nil
+		}
+	}
+
+	// Sem token do cliente a API nem é chamada: só mapeamentos conhecidos são aplicados
+	if apiToken == "" {
+		span.SetAttributes(attribute.String("lid.source", "none"))
+		span.End()
+		return "", ErrLIDNotMapped
+	}
+
+	missKey := c.BaseURL + "|" + lid
+	if c.APIMisses != nil {
+		if _, ok := c.APIMisses.Get(missKey); ok {
+			span.SetAttributes(attribute.String("lid.source", "miss_cache"))
+			span.End()
+			return "", ErrLIDNotMapped
+		}
+	}
+
+	jid, err := c.convertViaAPI(ctx, lid, apiToken)
+	if err != nil && c.APIMisses != nil {
+		c.APIMisses.Set(missKey, struct{}{})
+	}
+	c.observe("api", err)
+	span.SetAttributes(attribute.String("lid.source", "api"))
+	tracing.End(span, err)
+	return jid, err
+}
+
+func (c *LIDConverter) observe(source string, err error) {
+	if c.OnConvert != nil {
+		c.OnConvert(source, err)
+	}
+}
+
+// convertViaAPI consulta a API Avisa (/user/parselid) e grava o resultado no store
+func (c *LIDConverter) convertViaAPI(ctx context.Context, lid string, apiToken string) (string, error) {
 	if c.BaseURL == "" {
 		return "", fmt.Errorf("URL da API Avisa não configurada")
 	}
@@ -113,9 +220,37 @@ func (c *LIDConverter) ConvertLIDToJID(ctx context.Context, lid string, apiToken
 		return "", fmt.Errorf("conversão falhou na API")
 	}
 
+	if c.Store != nil {
+		c.Store.Save(ctx, lid, response.Data.JID, models.LIDSourceAPI)
+	}
+
 	return response.Data.JID, nil
 }
 
+// LearnFromInfo alimenta o store com pares LID/JID presentes no próprio evento
+// (Sender/SenderAlt e Chat/RecipientAlt), sem chamada de rede.
+func (c *LIDConverter) LearnFromInfo(ctx context.Context, info map[string]any) {
+	if c.Store == nil || info == nil {
+		return
+	}
+	pairs := [][2]string{{"Sender", "SenderAlt"}, {"Chat", "RecipientAlt"}}
+	for _, p := range pairs {
+		a, _ := info[p[0]].(string)
+		b, _ := info[p[1]].(string)
+		switch {
+		case IsLID(a) && isUserJID(b):
+			c.Store.Save(ctx, a, b, models.LIDSourceLearned)
+		case IsLID(b) && isUserJID(a):
+			c.Store.Save(ctx, b, a, models.LIDSourceLearned)
+		}
+	}
+}
+
+// isUserJID aceita apenas JIDs de usuário (grupos não têm LID correspondente)
+func isUserJID(id string) bool {
+	return strings.HasSuffix(strings.ToLower(id), "@s.whatsapp.net")
+}
+
 // DetectAndConvertIDs extrai e converte todos os IDs relevantes de um evento com token dinâmico
 func (c *LIDConverter) DetectAndConvertIDs(ctx context.Context, jsonDataStr string, apiToken string) (*ConvertedEventInfo, error) {
 	// Parse inicial do evento
@@ -131,6 +266,9 @@ func (c *LIDConverter) DetectAndConvertIDs(ctx context.Context, jsonDataStr stri
 
 	// Extrai IDs relevantes
 	if info := env.Event.Info; info != nil {
+		// Aprende pares antes de converter: o evento pode trazer o JID ao lado do LID
+		c.LearnFromInfo(ctx, info)
+
 		// Chat
 		if chat, ok := info["Chat"].(string); ok && chat != "" {
 			result.Chat = chat
@@ -291,12 +429,3 @@ func (c *ConvertedEventInfo) ApplyConversionsToJSON() (string, error) {
 
 	return string(modifiedJSON), nil
 }
-
-// getMapKeys retorna as chaves de um map para debug
-func getMapKeys(m map[string]interface{}) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
-	}
-	return keys
-}
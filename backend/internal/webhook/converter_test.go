@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
+)
+
+func TestLIDConvertersReuse(t *testing.T) {
+	p := NewLIDConverters(nil, nil, nil)
+	a := p.For("https://api.avisa.test/")
+	if b := p.For("https://api.avisa.test"); a != b {
+		t.Fatal("mesma URL base deve reaproveitar o conversor")
+	}
+	if c := p.For("https://outra.test"); c == a || c.HTTPClient != a.HTTPClient {
+		t.Fatal("URL base diferente deve ter conversor próprio com o HTTP client compartilhado")
+	}
+	if a.BaseURL != "https://api.avisa.test" {
+		t.Fatalf("BaseURL = %q", a.BaseURL)
+	}
+}
+
+func TestHasLID(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    bool
+	}{
+		{`{"Info":{"Chat":"5511987654321@s.whatsapp.net"}}`, false},
+		{`{"Info":{"SenderAlt":"112233445566778@lid"}}`, true},
+		{`{"Info":{"Chat":"112233445566778@LID"}}`, true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := HasLID(tt.payload); got != tt.want {
+			t.Errorf("HasLID(%q) = %v, want %v", tt.payload, got, tt.want)
+		}
+	}
+}
+
+// Falha da API para um LID fica em cache: o próximo evento não repete a chamada
+func TestConvertLIDAPIMissCached(t *testing.T) {
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = io.WriteString(w, `{"status":true,"data":{"success":false}}`)
+	}))
+	defer api.Close()
+
+	misses := cache.NewMemoryCache[string, struct{}](time.Minute)
+	c := NewLIDConverters(nil, nil, misses).For(api.URL)
+	if _, err := c.ConvertLIDToJID(context.Background(), "1@lid", "tok"); err == nil || errors.Is(err, ErrLIDNotMapped) {
+		t.Fatalf("1ª conversão: err = %v, want falha da API", err)
+	}
+	if _, err := c.ConvertLIDToJID(context.Background(), "1@lid", "tok"); !errors.Is(err, ErrLIDNotMapped) {
+		t.Fatalf("2ª conversão: err = %v, want ErrLIDNotMapped", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("API chamada %d vezes, want 1", got)
+	}
+	// Outro LID segue consultando a API
+	_, _ = c.ConvertLIDToJID(context.Background(), "2@lid", "tok")
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("API chamada %d vezes, want 2", got)
+	}
+}
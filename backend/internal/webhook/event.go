@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event é o modelo canônico entregue aos clientes com payloadFormat "normalized";
+// independe do formato de envelope do provider.
+type Event struct {
+	ID          string          `json:"id,omitempty"`
+	Type        string          `json:"type"`
+	Instance    string          `json:"instance,omitempty"`
+	Chat        string          `json:"chat,omitempty"`
+	Sender      string          `json:"sender,omitempty"`
+	PushName    string          `json:"pushName,omitempty"`
+	FromMe      bool            `json:"fromMe"`
+	IsGroup     bool            `json:"isGroup"`
+	Timestamp   *time.Time      `json:"timestamp,omitempty"`
+	MessageType string          `json:"messageType,omitempty"`
+	Text        string          `json:"text,omitempty"`
+	Media       *Media          `json:"media,omitempty"`
+	Raw         json.RawMessage `json:"raw"`
+}
+
+// Media descreve o anexo de uma mensagem (imagem, áudio, vídeo, documento, sticker)
+type Media struct {
+	Kind string `json:"kind"`
+	// ID da mídia no provider (Meta Cloud API não envia URL)
+	ID       string `json:"id,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+	FileName string `json:"fileName,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+// Info projeta o evento nos campos usados pelo pipeline (regras, dedup, roteamento)
+func (e *Event) Info() *EventInfo {
+	return &EventInfo{
+		ID:          e.ID,
+		Type:        e.Type,
+		Chat:        e.Chat,
+		Sender:      e.Sender,
+		IsGroup:     e.IsGroup,
+		IsFromMe:    e.FromMe,
+		MessageType: e.MessageType,
+	}
+}
+
+// mediaKinds mapeia as chaves de mensagem do WhatsApp para o tipo de mídia canônico
+var mediaKinds = []struct{ key, kind string }{
+	{"imageMessage", "image"},
+	{"videoMessage", "video"},
+	{"audioMessage", "audio"},
+	{"documentMessage", "document"},
+	{"stickerMessage", "sticker"},
+}
+
+// DecodeJSONData devolve o JSON do evento, desfazendo o double-encoding quando o jsonData veio como string
+func DecodeJSONData(jsonDataStr string) []byte {
+	raw := []byte(jsonDataStr)
+	var inner string
+	if err := json.Unmarshal(raw, &inner); err == nil {
+		return []byte(inner)
+	}
+	return raw
+}
+
+// NormalizeEvent converte o jsonData do wuzapi/Avisa no Event canônico
+func NormalizeEvent(jsonDataStr string) (*Event, error) {
+	raw := DecodeJSONData(jsonDataStr)
+	var env struct {
+		jsonDataEnvelope
+		InstanceName string `json:"instanceName"`
+		Instance     string `json:"instance"`
+		UserID       string `json:"userID"`
+	}
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, err
+	}
+	info, err := ParseEventInfo(string(raw))
+	if err != nil {
+		return nil, err
+	}
+	evt := &Event{
+		ID:          info.ID,
+		Type:        info.Type,
+		Instance:    firstNonEmpty(env.InstanceName, env.Instance, env.UserID),
+		Chat:        info.Chat,
+		Sender:      info.Sender,
+		FromMe:      info.IsFromMe,
+		IsGroup:     info.IsGroup,
+		MessageType: info.MessageType,
+		Raw:         json.RawMessage(raw),
+	}
+	if v, ok := env.Event.Info["PushName"].(string); ok {
+		evt.PushName = v
+	}
+	evt.Timestamp = parseTimestamp(env.Event.Info["Timestamp"])
+	evt.Text, evt.Media = extractContent(env.Event.Message)
+	if evt.MessageType == "" && evt.Media != nil {
+		evt.MessageType = evt.Media.Kind
+	}
+	return evt, nil
+}
+
+// extractContent lê texto e mídia do nó Message (formato whatsmeow/wuzapi)
+func extractContent(msg map[string]any) (string, *Media) {
+	if msg == nil {
+		return "", nil
+	}
+	if v, ok := msg["conversation"].(string); ok && v != "" {
+		return v, nil
+	}
+	if ext, ok := msg["extendedTextMessage"].(map[string]any); ok {
+		if v, ok := ext["text"].(string); ok {
+			return v, nil
+		}
+	}
+	for _, mk := range mediaKinds {
+		node, ok := msg[mk.key].(map[string]any)
+		if !ok {
+			continue
+		}
+		m := &Media{Kind: mk.kind}
+		m.MimeType, _ = node["mimetype"].(string)
+		m.URL, _ = node["URL"].(string)
+		if m.URL == "" {
+			m.URL, _ = node["url"].(string)
+		}
+		m.Caption, _ = node["caption"].(string)
+		m.FileName, _ = node["fileName"].(string)
+		switch n := node["fileLength"].(type) {
+		case float64:
+			m.Size = int64(n)
+		case string:
+			m.Size, _ = strconv.ParseInt(n, 10, 64)
+		}
+		return m.Caption, m
+	}
+	return "", nil
+}
+
+// parseTimestamp aceita RFC3339 (wuzapi) ou epoch em segundos/milissegundos
+func parseTimestamp(v any) *time.Time {
+	var t time.Time
+	switch ts := v.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(ts))
+		if err != nil {
+			n, err := strconv.ParseInt(strings.TrimSpace(ts), 10, 64)
+			if err != nil {
+				return nil
+			}
+			parsed = epochToTime(n)
+		}
+		t = parsed
+	case float64:
+		t = epochToTime(int64(ts))
+	default:
+		return nil
+	}
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func epochToTime(n int64) time.Time {
+	if n <= 0 {
+		return time.Time{}
+	}
+	if n > 1e12 {
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// phoneJID converte um telefone em JID de usuário (ou de grupo, para IDs "<id>-group" do Z-API)
+func phoneJID(phone string, isGroup bool) string {
+	phone = strings.TrimSpace(phone)
+	if phone == "" || strings.Contains(phone, "@") {
+		return phone
+	}
+	if isGroup || strings.HasSuffix(phone, "-group") {
+		return strings.TrimSuffix(phone, "-group") + "@g.us"
+	}
+	return phone + "@s.whatsapp.net"
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+func init() { RegisterExtractor(evolutionExtractor{}) }
+
+// evolutionExtractor trata o Evolution API: corpo JSON { event, instance, data: { key, message, ... } }
+type evolutionExtractor struct{}
+
+func (evolutionExtractor) Name() string { return "evolution" }
+
+func (evolutionExtractor) Extract(body []byte, contentType string) (string, error) {
+	payload := strings.TrimSpace(string(body))
+	if payload == "" || !json.Valid(body) {
+		return "", ErrEmptyPayload
+	}
+	return payload, nil
+}
+
+type evolutionKey struct {
+	RemoteJID   string `json:"remoteJid"`
+	FromMe      bool   `json:"fromMe"`
+	ID          string `json:"id"`
+	Participant string `json:"participant"`
+}
+
+type evolutionData struct {
+	Key              evolutionKey   `json:"key"`
+	PushName         string         `json:"pushName"`
+	Message          map[string]any `json:"message"`
+	MessageType      string         `json:"messageType"`
+	MessageTimestamp any            `json:"messageTimestamp"`
+}
+
+func (evolutionExtractor) Normalize(payload string) (*Event, error) {
+	raw := DecodeJSONData(payload)
+	var env struct {
+		Event    string          `json:"event"`
+		Instance string          `json:"instance"`
+		Sender   string          `json:"sender"`
+		Data     json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, err
+	}
+	var data evolutionData
+	// Algumas versões enviam data como lista; usa o primeiro item
+	if len(env.Data) > 0 && env.Data[0] == '[' {
+		var list []evolutionData
+		if err := json.Unmarshal(env.Data, &list); err != nil {
+			return nil, err
+		}
+		if len(list) > 0 {
+			data = list[0]
+		}
+	} else if len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, &data); err != nil {
+			return nil, err
+		}
+	}
+
+	evt := &Event{
+		ID:          data.Key.ID,
+		Type:        env.Event,
+		Instance:    env.Instance,
+		Chat:        data.Key.RemoteJID,
+		FromMe:      data.Key.FromMe,
+		IsGroup:     isGroupChat(data.Key.RemoteJID),
+		PushName:    data.PushName,
+		MessageType: data.MessageType,
+		Timestamp:   parseTimestamp(data.MessageTimestamp),
+		Raw:         json.RawMessage(raw),
+	}
+	switch {
+	case data.Key.Participant != "":
+		evt.Sender = data.Key.Participant
+	case data.Key.FromMe:
+		evt.Sender = env.Sender
+	default:
+		evt.Sender = data.Key.RemoteJID
+	}
+	evt.Text, evt.Media = extractContent(data.Message)
+	return evt, nil
+}
+
+// isGroupChat reconhece JIDs de grupo e broadcast
+func isGroupChat(chat string) bool {
+	lower := strings.ToLower(chat)
+	return strings.Contains(lower, "@g.us") || strings.Contains(lower, "@broadcast")
+}
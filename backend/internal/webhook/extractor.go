@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultProvider é usado quando o cliente não define provider (formato legado wuzapi/Avisa)
+const DefaultProvider = "wuzapi"
+
+// ErrEmptyPayload indica que o corpo recebido não contém um evento reconhecível
+var ErrEmptyPayload = errors.New("payload do evento ausente")
+
+// Extractor conhece o envelope de um provider WhatsApp: localiza o evento no corpo
+// recebido e o converte no Event canônico.
+type Extractor interface {
+	// Name identifica o provider na configuração do cliente
+	Name() string
+	// Extract devolve o JSON do evento contido no corpo (ErrEmptyPayload se ausente)
+	Extract(body []byte, contentType string) (string, error)
+	// Normalize converte o JSON retornado por Extract no Event canônico
+	Normalize(payload string) (*Event, error)
+}
+
+// Splitter é implementado por providers que agrupam vários eventos num único POST;
+// cada parte retornada é um payload válido para Normalize com um só evento.
+type Splitter interface {
+	Split(payload string) ([]string, error)
+}
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[string]Extractor{}
+)
+
+// RegisterExtractor registra (ou substitui) o extractor de um provider; chamado no init de cada implementação
+func RegisterExtractor(e Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors[strings.ToLower(e.Name())] = e
+}
+
+// LookupExtractor retorna o extractor registrado para o provider
+func LookupExtractor(provider string) (Extractor, bool) {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	e, ok := extractors[strings.ToLower(strings.TrimSpace(provider))]
+	return e, ok
+}
+
+// ExtractorFor retorna o extractor do provider, caindo no DefaultProvider quando vazio ou desconhecido
+func ExtractorFor(provider string) Extractor {
+	if e, ok := LookupExtractor(provider); ok {
+		return e
+	}
+	e, _ := LookupExtractor(DefaultProvider)
+	return e
+}
+
+// Providers lista os providers registrados (ordem alfabética)
+func Providers() []string {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	out := make([]string, 0, len(extractors))
+	for name := range extractors {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// formValue lê um campo de corpo multipart ou urlencoded sem depender do *http.Request
+func formValue(body []byte, contentType, field string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil && strings.EqualFold(mediaType, "multipart/form-data") {
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				return ""
+			}
+			if part.FormName() == field && part.FileName() == "" {
+				v, _ := io.ReadAll(io.LimitReader(part, 10<<20)) // 10 MiB
+				return string(v)
+			}
+		}
+	}
+	vals, err := url.ParseQuery(string(body))
+	if err != nil {
+		return ""
+	}
+	return vals.Get(field)
+}
+
+// isJSONContent indica corpo JSON pelo Content-Type
+func isJSONContent(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(contentType), "application/json")
+}
@@ -0,0 +1,415 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fixture lê um payload real de provider em testdata/
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func mustExtractor(t *testing.T, provider string) Extractor {
+	t.Helper()
+	e, ok := LookupExtractor(provider)
+	if !ok {
+		t.Fatalf("extractor %q não registrado", provider)
+	}
+	return e
+}
+
+func multipartBody(t *testing.T, field, value string) ([]byte, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.WriteField("userID", "7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteField(field, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), w.FormDataContentType()
+}
+
+func TestProvidersRegistered(t *testing.T) {
+	want := []string{"evolution", "meta", "wuzapi", "zapi"}
+	if got := Providers(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Providers = %v, want %v", got, want)
+	}
+	if got := ExtractorFor("desconhecido").Name(); got != DefaultProvider {
+		t.Fatalf("ExtractorFor(desconhecido) = %s, want %s", got, DefaultProvider)
+	}
+	if got := ExtractorFor(" Meta ").Name(); got != MetaProvider {
+		t.Fatalf("ExtractorFor(Meta) = %s, want %s", got, MetaProvider)
+	}
+}
+
+func TestWuzapiExtract(t *testing.T) {
+	event := fixture(t, "wuzapi_message.json")
+	quoted, _ := json.Marshal(event)
+	mp, mpType := multipartBody(t, "jsonData", event)
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(event)); err != nil {
+		t.Fatal(err)
+	}
+	compactKey, _ := json.Marshal(compact.String())
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"form urlencoded", []byte(url.Values{"jsonData": {event}, "userID": {"7"}}.Encode()), "application/x-www-form-urlencoded", event},
+		{"multipart", mp, mpType, event},
+		{"envelope JSON com jsonData", []byte(`{"jsonData":` + string(quoted) + `,"userID":"7"}`), "application/json", event},
+		{"envelope body.jsonData", []byte(`{"body":{"jsonData":` + string(quoted) + `}}`), "application/json; charset=utf-8", event},
+		{"body com JSON como chave", []byte(`{"body":{` + string(compactKey) + `:""}}`), "application/json", compact.String()},
+		{"evento sem envelope", []byte(event), "application/json", string(bytes.TrimSpace([]byte(event)))},
+	}
+	e := mustExtractor(t, "wuzapi")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.Extract(tt.body, tt.contentType)
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Extract = %.80q..., want %.80q...", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractEmptyOrMalformed(t *testing.T) {
+	tests := []struct {
+		provider    string
+		body        string
+		contentType string
+	}{
+		{"wuzapi", "", "application/json"},
+		{"wuzapi", "   ", "application/json"},
+		{"wuzapi", "userID=7", "application/x-www-form-urlencoded"},
+		{"wuzapi", "%zz", "application/x-www-form-urlencoded"},
+		{"evolution", "", "application/json"},
+		{"evolution", `{"event":`, "application/json"},
+		{"zapi", "", "application/json"},
+		{"zapi", "phone=5511", "application/x-www-form-urlencoded"},
+		{"meta", "", "application/json"},
+		{"meta", `{"object":"whatsapp_business_account","entry":[`, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.body, func(t *testing.T) {
+			_, err := mustExtractor(t, tt.provider).Extract([]byte(tt.body), tt.contentType)
+			if !errors.Is(err, ErrEmptyPayload) {
+				t.Fatalf("Extract = %v, want ErrEmptyPayload", err)
+			}
+		})
+	}
+}
+
+func TestJSONProvidersExtractBody(t *testing.T) {
+	for provider, name := range map[string]string{
+		"evolution": "evolution_messages_upsert.json",
+		"zapi":      "zapi_received_text.json",
+		"meta":      "meta_text.json",
+	} {
+		t.Run(provider, func(t *testing.T) {
+			body := fixture(t, name)
+			got, err := mustExtractor(t, provider).Extract([]byte(body), "application/json")
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if got != string(bytes.TrimSpace([]byte(body))) {
+				t.Fatalf("Extract alterou o corpo")
+			}
+		})
+	}
+}
+
+func ts(t time.Time) *time.Time {
+	t = t.UTC()
+	return &t
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		provider string
+		fixture  string
+		want     Event
+	}{
+		{"wuzapi", "wuzapi_message.json", Event{
+			ID:          "3EB0A1B2C3D4E5F60718",
+			Type:        "Message",
+			Instance:    "avisa-prod-01",
+			Chat:        "5511987654321@s.whatsapp.net",
+			Sender:      "5511987654321@s.whatsapp.net",
+			PushName:    "Maria Souza",
+			Timestamp:   ts(time.Date(2024, 5, 10, 16, 45, 12, 0, time.UTC)),
+			MessageType: "text",
+			Text:        "Olá, gostaria de saber o horário de atendimento",
+		}},
+		{"wuzapi", "wuzapi_group_image.json", Event{
+			ID:          "ABFA6D1E7C0B2F9D",
+			Type:        "Message",
+			Instance:    "7",
+			Chat:        "120363025246125486@g.us",
+			Sender:      "5521998877665@s.whatsapp.net",
+			PushName:    "João",
+			IsGroup:     true,
+			Timestamp:   ts(time.Date(2024, 5, 10, 16, 45, 12, 0, time.UTC)),
+			MessageType: "media",
+			Text:        "foto do pedido",
+			Media: &Media{
+				Kind:     "image",
+				MimeType: "image/jpeg",
+				URL:      "https://mmg.whatsapp.net/v/t62.7118-24/1234_n.enc",
+				Caption:  "foto do pedido",
+				Size:     48213,
+			},
+		}},
+		{"evolution", "evolution_messages_upsert.json", Event{
+			ID:          "BAE5F2C9A7D31E40",
+			Type:        "messages.upsert",
+			Instance:    "loja-centro",
+			Chat:        "5511912345678@s.whatsapp.net",
+			Sender:      "5511912345678@s.whatsapp.net",
+			PushName:    "Carlos",
+			Timestamp:   ts(time.Unix(1715359512, 0)),
+			MessageType: "conversation",
+			Text:        "Quero fazer um pedido",
+		}},
+		{"evolution", "evolution_group_audio_list.json", Event{
+			ID:          "3A9F0C1D2E3B4A59",
+			Type:        "messages.upsert",
+			Instance:    "loja-centro",
+			Chat:        "120363041234567890@g.us",
+			Sender:      "5511955554444@s.whatsapp.net",
+			PushName:    "Ana",
+			IsGroup:     true,
+			Timestamp:   ts(time.Unix(1715359600, 0)),
+			MessageType: "audioMessage",
+			Media: &Media{
+				Kind:     "audio",
+				MimeType: "audio/ogg; codecs=opus",
+				URL:      "https://mmg.whatsapp.net/v/t62.7117-24/audio.enc",
+				Size:     9120,
+			},
+		}},
+		{"zapi", "zapi_received_text.json", Event{
+			ID:          "A20DA9C0183A2D35A260F53F5D2B9244",
+			Type:        "ReceivedCallback",
+			Instance:    "A20DA9C0183A2D35A260F53F5D2B9244",
+			Chat:        "5544999999999@s.whatsapp.net",
+			Sender:      "5544999999999@s.whatsapp.net",
+			PushName:    "Pedro",
+			Timestamp:   ts(time.UnixMilli(1632228638000)),
+			MessageType: "text",
+			Text:        "teste",
+		}},
+		{"zapi", "zapi_group_image.json", Event{
+			ID:          "3EB0F12D8A1C4B7E",
+			Type:        "ReceivedCallback",
+			Instance:    "A20DA9C0183A2D35A260F53F5D2B9244",
+			Chat:        "120363019502650977@g.us",
+			Sender:      "5544988887777@s.whatsapp.net",
+			PushName:    "Lucas",
+			IsGroup:     true,
+			Timestamp:   ts(time.UnixMilli(1632228955000)),
+			MessageType: "image",
+			Text:        "catálogo novo",
+			Media: &Media{
+				Kind:     "image",
+				MimeType: "image/jpeg",
+				URL:      "https://storage.z-api.io/instances/A20D/image.jpeg",
+				Caption:  "catálogo novo",
+			},
+		}},
+		{"meta", "meta_text.json", Event{
+			ID:          "wamid.HBgLMTY1MDM4Nzk0MzkVAgASGBQzQTRBNjU5OUFFRTAzODEwMTQ0RgA=",
+			Type:        "message",
+			Instance:    "106540352242922",
+			Chat:        "16505551234@s.whatsapp.net",
+			Sender:      "16505551234@s.whatsapp.net",
+			PushName:    "Sheena Nelson",
+			Timestamp:   ts(time.Unix(1749416383, 0)),
+			MessageType: "text",
+			Text:        "Does it come in another color?",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			payload := fixture(t, tt.fixture)
+			got, err := mustExtractor(t, tt.provider).Normalize(payload)
+			if err != nil {
+				t.Fatalf("Normalize: %v", err)
+			}
+			if !json.Valid(got.Raw) {
+				t.Fatalf("Raw não é JSON válido")
+			}
+			got.Raw = nil
+			if !reflect.DeepEqual(*got, tt.want) {
+				gotJSON, _ := json.MarshalIndent(got, "", "  ")
+				wantJSON, _ := json.MarshalIndent(tt.want, "", "  ")
+				t.Fatalf("Normalize =\n%s\nwant\n%s", gotJSON, wantJSON)
+			}
+			// Info projeta os campos do pipeline a partir do mesmo evento
+			info := got.Info()
+			if info.ID != tt.want.ID || info.Chat != tt.want.Chat || info.IsGroup != tt.want.IsGroup || info.IsFromMe != tt.want.FromMe {
+				t.Fatalf("Info = %+v", info)
+			}
+		})
+	}
+}
+
+// O jsonData do wuzapi às vezes chega como string JSON (double-encoded)
+func TestNormalizeDoubleEncoded(t *testing.T) {
+	quoted, _ := json.Marshal(fixture(t, "wuzapi_message.json"))
+	got, err := mustExtractor(t, "wuzapi").Normalize(string(quoted))
+	if err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if got.ID != "3EB0A1B2C3D4E5F60718" || got.Text == "" {
+		t.Fatalf("Normalize = %+v", got)
+	}
+}
+
+func TestNormalizeMalformed(t *testing.T) {
+	for _, provider := range Providers() {
+		for _, payload := range []string{`{"event":`, `not json`, `[1,2`} {
+			t.Run(provider+"/"+payload, func(t *testing.T) {
+				if _, err := mustExtractor(t, provider).Normalize(payload); err == nil {
+					t.Fatalf("Normalize(%q) sem erro", payload)
+				}
+			})
+		}
+	}
+}
+
+// Payloads válidos de formato desconhecido não falham: viram um evento vazio, sem ID para dedup
+func TestNormalizeUnknownPayload(t *testing.T) {
+	for _, provider := range Providers() {
+		t.Run(provider, func(t *testing.T) {
+			got, err := mustExtractor(t, provider).Normalize(`{"foo":"bar","n":1}`)
+			if err != nil {
+				t.Fatalf("Normalize: %v", err)
+			}
+			if got.ID != "" || got.Chat != "" || got.Text != "" || got.Media != nil {
+				t.Fatalf("Normalize = %+v, want evento vazio", got)
+			}
+			if string(got.Raw) != `{"foo":"bar","n":1}` {
+				t.Fatalf("Raw = %s", got.Raw)
+			}
+		})
+	}
+	// Evolution com data de tipo inesperado é erro de parse
+	if _, err := mustExtractor(t, "evolution").Normalize(`{"event":"x","data":"texto"}`); err == nil {
+		t.Fatal("evolution: data string sem erro")
+	}
+}
+
+func TestMetaSplit(t *testing.T) {
+	e := mustExtractor(t, MetaProvider)
+	sp, ok := e.(Splitter)
+	if !ok {
+		t.Fatal("meta não implementa Splitter")
+	}
+
+	parts, err := sp.Split(fixture(t, "meta_multi.json"))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	want := []struct {
+		id, typ, chat, pushName, text, messageType string
+		fromMe                                     bool
+		media                                      *Media
+	}{
+		{"wamid.AAA1", "message", "16505551234@s.whatsapp.net", "Sheena Nelson", "like this one", "image", false,
+			&Media{Kind: "image", ID: "1003383421387256", MimeType: "image/jpeg", Caption: "like this one"}},
+		{"wamid.AAA2", "message", "16505559876@s.whatsapp.net", "Ravi", "Sim", "interactive", false, nil},
+		{"wamid.OUT1:delivered", "status", "16505551234@s.whatsapp.net", "", "", "delivered", true, nil},
+	}
+	if len(parts) != len(want) {
+		t.Fatalf("Split = %d partes, want %d", len(parts), len(want))
+	}
+	for i, part := range parts {
+		var p metaPayload
+		if err := json.Unmarshal([]byte(part), &p); err != nil {
+			t.Fatalf("parte %d inválida: %v", i, err)
+		}
+		if p.Object != "whatsapp_business_account" || len(p.Entry) != 1 || len(p.Entry[0].Changes) != 1 {
+			t.Fatalf("parte %d não preserva o envelope: %s", i, part)
+		}
+		if v := p.Entry[0].Changes[0].Value; len(v.Messages)+len(v.Statuses) != 1 || v.Metadata.PhoneNumberID != "106540352242922" {
+			t.Fatalf("parte %d deveria ter um único item com metadata: %s", i, part)
+		}
+		evt, err := e.Normalize(part)
+		if err != nil {
+			t.Fatalf("Normalize parte %d: %v", i, err)
+		}
+		w := want[i]
+		if evt.ID != w.id || evt.Type != w.typ || evt.Chat != w.chat || evt.PushName != w.pushName ||
+			evt.Text != w.text || evt.MessageType != w.messageType || evt.FromMe != w.fromMe || !reflect.DeepEqual(evt.Media, w.media) {
+			t.Fatalf("parte %d = %+v (media %+v)", i, evt, evt.Media)
+		}
+	}
+
+	single, err := sp.Split(fixture(t, "meta_text.json"))
+	if err != nil || len(single) != 1 {
+		t.Fatalf("Split(meta_text) = %d partes, err %v", len(single), err)
+	}
+	if empty, err := sp.Split(`{"object":"whatsapp_business_account","entry":[]}`); err != nil || len(empty) != 0 {
+		t.Fatalf("Split(sem entry) = %v, %v", empty, err)
+	}
+	if _, err := sp.Split(`{"entry":`); err == nil {
+		t.Fatal("Split(malformado) sem erro")
+	}
+}
+
+func TestVerifyMetaSignature(t *testing.T) {
+	body := []byte(fixture(t, "meta_text.json"))
+	sig := "sha256=" + hmacHex("app-secret", body)
+	tampered := append([]byte{}, body...)
+	tampered[len(tampered)-2] = ' '
+	if !VerifyMetaSignature("app-secret", body, sig) {
+		t.Fatal("assinatura válida rejeitada")
+	}
+	for name, tc := range map[string]struct{ secret, header string }{
+		"segredo errado":   {"outro", sig},
+		"sem prefixo":      {"app-secret", sig[len("sha256="):]},
+		"hex inválido":     {"app-secret", "sha256=zz"},
+		"header vazio":     {"app-secret", ""},
+		"segredo ausente":  {"", sig},
+		"corpo adulterado": {"app-secret", "sha256=" + hmacHex("app-secret", tampered)},
+	} {
+		if VerifyMetaSignature(tc.secret, body, tc.header) {
+			t.Fatalf("%s: assinatura aceita", name)
+		}
+	}
+}
+
+func hmacHex(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
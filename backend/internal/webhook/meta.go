@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// MetaProvider é o nome do provider da WhatsApp Cloud API
+const MetaProvider = "meta"
+
+// MetaSignatureHeader carrega o HMAC-SHA256 do corpo assinado com o app secret
+const MetaSignatureHeader = "X-Hub-Signature-256"
+
+func init() { RegisterExtractor(metaExtractor{}) }
+
+// metaExtractor trata a Meta WhatsApp Cloud API: { object, entry: [ { changes: [ { value } ] } ] }
+type metaExtractor struct{}
+
+func (metaExtractor) Name() string { return MetaProvider }
+
+func (metaExtractor) Extract(body []byte, contentType string) (string, error) {
+	payload := strings.TrimSpace(string(body))
+	if payload == "" || !json.Valid(body) {
+		return "", ErrEmptyPayload
+	}
+	return payload, nil
+}
+
+type metaMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	Caption  string `json:"caption"`
+	Filename string `json:"filename"`
+}
+
+type metaMessage struct {
+	From      string `json:"from"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      *struct {
+		Body string `json:"body"`
+	} `json:"text"`
+	Button *struct {
+		Text string `json:"text"`
+	} `json:"button"`
+	Interactive *struct {
+		ButtonReply *struct {
+			Title string `json:"title"`
+		} `json:"button_reply"`
+		ListReply *struct {
+			Title string `json:"title"`
+		} `json:"list_reply"`
+	} `json:"interactive"`
+	Image    *metaMedia `json:"image"`
+	Audio    *metaMedia `json:"audio"`
+	Video    *metaMedia `json:"video"`
+	Document *metaMedia `json:"document"`
+	Sticker  *metaMedia `json:"sticker"`
+}
+
+type metaStatus struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	Timestamp   string `json:"timestamp"`
+	RecipientID string `json:"recipient_id"`
+}
+
+type metaValue struct {
+	Metadata struct {
+		DisplayPhoneNumber string `json:"display_phone_number"`
+		PhoneNumberID      string `json:"phone_number_id"`
+	} `json:"metadata"`
+	Contacts []struct {
+		Profile struct {
+			Name string `json:"name"`
+		} `json:"profile"`
+		WaID string `json:"wa_id"`
+	} `json:"contacts"`
+	Messages []metaMessage `json:"messages"`
+	Statuses []metaStatus  `json:"statuses"`
+}
+
+type metaPayload struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		ID      string `json:"id"`
+		Changes []struct {
+			Field string    `json:"field"`
+			Value metaValue `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+// Normalize converte a primeira mensagem (ou status) do payload
+func (metaExtractor) Normalize(payload string) (*Event, error) {
+	raw := DecodeJSONData(payload)
+	var p metaPayload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, err
+	}
+	for _, entry := range p.Entry {
+		for _, ch := range entry.Changes {
+			v := ch.Value
+			if len(v.Messages) > 0 {
+				return metaMessageEvent(v, v.Messages[0], raw), nil
+			}
+			if len(v.Statuses) > 0 {
+				return metaStatusEvent(v, v.Statuses[0], raw), nil
+			}
+		}
+	}
+	return &Event{Type: p.Object, Raw: json.RawMessage(raw)}, nil
+}
+
+func metaMessageEvent(v metaValue, m metaMessage, raw []byte) *Event {
+	evt := &Event{
+		ID:          m.ID,
+		Type:        "message",
+		Instance:    v.Metadata.PhoneNumberID,
+		Chat:        phoneJID(m.From, false),
+		Sender:      phoneJID(m.From, false),
+		MessageType: m.Type,
+		Timestamp:   parseTimestamp(m.Timestamp),
+		Raw:         json.RawMessage(raw),
+	}
+	for _, c := range v.Contacts {
+		if c.WaID == m.From {
+			evt.PushName = c.Profile.Name
+		}
+	}
+	switch {
+	case m.Text != nil:
+		evt.Text = m.Text.Body
+	case m.Button != nil:
+		evt.Text = m.Button.Text
+	case m.Interactive != nil && m.Interactive.ButtonReply != nil:
+		evt.Text = m.Interactive.ButtonReply.Title
+	case m.Interactive != nil && m.Interactive.ListReply != nil:
+		evt.Text = m.Interactive.ListReply.Title
+	}
+	for _, md := range []struct {
+		kind string
+		node *metaMedia
+	}{{"image", m.Image}, {"audio", m.Audio}, {"video", m.Video}, {"document", m.Document}, {"sticker", m.Sticker}} {
+		if md.node == nil {
+			continue
+		}
+		// A Cloud API entrega só o ID da mídia; o download exige o token do cliente
+		evt.Media = &Media{Kind: md.kind, ID: md.node.ID, MimeType: md.node.MimeType, Caption: md.node.Caption, FileName: md.node.Filename}
+		if evt.Text == "" {
+			evt.Text = md.node.Caption
+		}
+		break
+	}
+	return evt
+}
+
+func metaStatusEvent(v metaValue, s metaStatus, raw []byte) *Event {
+	// Cada mudança de status gera um evento próprio (o ID da mensagem se repete)
+	return &Event{
+		ID:          s.ID + ":" + s.Status,
+		Type:        "status",
+		Instance:    v.Metadata.PhoneNumberID,
+		Chat:        phoneJID(s.RecipientID, false),
+		Sender:      phoneJID(v.Metadata.DisplayPhoneNumber, false),
+		FromMe:      true,
+		MessageType: s.Status,
+		Timestamp:   parseTimestamp(s.Timestamp),
+		Raw:         json.RawMessage(raw),
+	}
+}
+
+// Split separa o payload em um envelope por mensagem/status, preservando o formato da Cloud API
+func (metaExtractor) Split(payload string) ([]string, error) {
+	var root map[string]any
+	if err := json.Unmarshal(DecodeJSONData(payload), &root); err != nil {
+		return nil, err
+	}
+	entries, _ := root["entry"].([]any)
+	var parts []string
+	for _, e := range entries {
+		entry, _ := e.(map[string]any)
+		changes, _ := entry["changes"].([]any)
+		for _, ch := range changes {
+			change, _ := ch.(map[string]any)
+			value, _ := change["value"].(map[string]any)
+			for _, key := range []string{"messages", "statuses"} {
+				items, _ := value[key].([]any)
+				for _, item := range items {
+					part, err := metaSingle(root, entry, change, value, key, item)
+					if err != nil {
+						return nil, err
+					}
+					parts = append(parts, part)
+				}
+			}
+		}
+	}
+	return parts, nil
+}
+
+// metaSingle monta um envelope com uma única entry/change contendo só o item informado
+func metaSingle(root, entry, change, value map[string]any, key string, item any) (string, error) {
+	v := make(map[string]any, len(value))
+	for k, val := range value {
+		if k != "messages" && k != "statuses" {
+			v[k] = val
+		}
+	}
+	v[key] = []any{item}
+	ch := make(map[string]any, len(change))
+	for k, val := range change {
+		ch[k] = val
+	}
+	ch["value"] = v
+	en := make(map[string]any, len(entry))
+	for k, val := range entry {
+		en[k] = val
+	}
+	en["changes"] = []any{ch}
+	out := make(map[string]any, len(root))
+	for k, val := range root {
+		out[k] = val
+	}
+	out["entry"] = []any{en}
+	b, err := json.Marshal(out)
+	return string(b), err
+}
+
+// VerifyMetaSignature valida o header X-Hub-Signature-256 ("sha256=<hex>") com o app secret
+func VerifyMetaSignature(appSecret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(strings.TrimSpace(header), "sha256=")
+	if !ok || appSecret == "" {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
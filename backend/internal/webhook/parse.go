@@ -3,7 +3,7 @@ package webhook
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"strings"
 )
 
@@ -14,99 +14,129 @@ type eventFields struct {
 }
 
 type jsonDataEnvelope struct {
+	Type  string      `json:"type"`
 	Event eventFields `json:"event"`
 }
 
+// EventInfo reúne os campos do evento usados no pipeline (dedup, filtros, roteamento)
+type EventInfo struct {
+	// ID da mensagem WhatsApp (event.Info.ID); vazio em eventos que não são mensagem
+	ID          string
+	Type        string
+	Chat        string
+	Sender      string
+	IsGroup     bool
+	IsFromMe    bool
+	MessageType string
+	Reason      string
+}
+
+// ParseEventInfo faz o parse do jsonData uma única vez e extrai os campos do pipeline
+func ParseEventInfo(jsonDataStr string) (*EventInfo, error) {
+	var env jsonDataEnvelope
+	if err := json.Unmarshal(DecodeJSONData(jsonDataStr), &env); err != nil {
+		return nil, err
+	}
+	info := &EventInfo{Type: env.Type}
+	if v, ok := env.Event.Info["ID"].(string); ok {
+		info.ID = strings.TrimSpace(v)
+	}
+	if v, ok := env.Event.Info["IsFromMe"].(bool); ok {
+		info.IsFromMe = v
+	}
+	if v, ok := env.Event.Info["Type"].(string); ok {
+		info.MessageType = v
+	}
+	info.IsGroup, info.Chat, info.Sender, info.Reason = extractGroupInfo(env)
+	return info, nil
+}
+
 // ExtractEventInfo detecta IsGroup/Chat/Sender com tolerância a variações (versão legada)
 func ExtractEventInfo(jsonDataStr string) (isGroup bool, chat string, sender string, reason string) {
-	// 1) Tenta struct tipado
-	var env jsonDataEnvelope
-	if err := json.Unmarshal([]byte(jsonDataStr), &env); err == nil {
-		// Info pode ser map; tentar tipos comuns
-		if v, ok := env.Event.Info["IsGroup"]; ok {
-			switch t := v.(type) {
-			case bool:
-				isGroup = t
-			case string:
-				isGroup = strings.EqualFold(strings.TrimSpace(t), "true")
-			}
-			if isGroup {
-				reason = "is_group_true"
-			}
-		}
-		if v, ok := env.Event.Info["Chat"].(string); ok {
-			chat = v
+	info, err := ParseEventInfo(jsonDataStr)
+	if err != nil {
+		// Fallback para dados mal formados
+		return false, "", "", "json_parse_failed"
+	}
+	return info.IsGroup, info.Chat, info.Sender, info.Reason
+}
+
+// extractGroupInfo aplica as heurísticas de grupo/broadcast sobre o envelope já decodificado
+func extractGroupInfo(env jsonDataEnvelope) (isGroup bool, chat string, sender string, reason string) {
+	// Info pode ser map; tentar tipos comuns
+	if v, ok := env.Event.Info["IsGroup"]; ok {
+		switch t := v.(type) {
+		case bool:
+			isGroup = t
+		case string:
+			isGroup = strings.EqualFold(strings.TrimSpace(t), "true")
 		}
-		if v, ok := env.Event.Info["Sender"].(string); ok {
-			sender = v
+		if isGroup {
+			reason = "is_group_true"
 		}
+	}
+	if v, ok := env.Event.Info["Chat"].(string); ok {
+		chat = v
+	}
+	if v, ok := env.Event.Info["Sender"].(string); ok {
+		sender = v
+	}
 
-		lowerChat := strings.ToLower(chat)
-		if strings.Contains(lowerChat, "@g.us") {
-			isGroup = true
-			if reason == "" {
-				reason = "chat_has_g_us"
-			}
+	lowerChat := strings.ToLower(chat)
+	if strings.Contains(lowerChat, "@g.us") {
+		isGroup = true
+		if reason == "" {
+			reason = "chat_has_g_us"
 		}
-		if strings.Contains(lowerChat, "@broadcast") || strings.Contains(lowerChat, "status@broadcast") {
-			isGroup = true
-			if reason == "" {
-				reason = "chat_status_broadcast"
-			}
+	}
+	if strings.Contains(lowerChat, "@broadcast") || strings.Contains(lowerChat, "status@broadcast") {
+		isGroup = true
+		if reason == "" {
+			reason = "chat_status_broadcast"
 		}
+	}
 
-		if env.Event.Message != nil {
-			if skdm, ok := env.Event.Message["senderKeyDistributionMessage"].(map[string]any); ok {
-				if gid, ok := skdm["groupID"].(string); ok {
-					lowerGid := strings.ToLower(gid)
-					if strings.Contains(lowerGid, "@broadcast") || strings.Contains(lowerGid, "status@broadcast") || strings.Contains(lowerGid, "@g.us") {
-						isGroup = true
-						if reason == "" {
-							reason = "message_group_id_broadcast"
-						}
+	if env.Event.Message != nil {
+		if skdm, ok := env.Event.Message["senderKeyDistributionMessage"].(map[string]any); ok {
+			if gid, ok := skdm["groupID"].(string); ok {
+				lowerGid := strings.ToLower(gid)
+				if strings.Contains(lowerGid, "@broadcast") || strings.Contains(lowerGid, "status@broadcast") || strings.Contains(lowerGid, "@g.us") {
+					isGroup = true
+					if reason == "" {
+						reason = "message_group_id_broadcast"
 					}
 				}
 			}
 		}
-		return isGroup, chat, sender, reason
 	}
-
-	// Fallback para dados mal formados
-	return false, "", "", "json_parse_failed"
+	return isGroup, chat, sender, reason
 }
 
-// ExtractEventInfoWithConversion extrai informações do evento com conversão automática LID->JID
-func ExtractEventInfoWithConversion(ctx context.Context, jsonDataStr string, converter *LIDConverter, apiToken string, logger *log.Logger) (isGroup bool, chat string, sender string, reason string, conversions map[string]string, eventInfo *ConvertedEventInfo) {
+// ExtractEventInfoWithConversion extrai informações do evento com conversão automática LID->JID.
+// Os detalhes da análise saem em nível debug no logger informado (nil = sem log).
+func ExtractEventInfoWithConversion(ctx context.Context, jsonDataStr string, converter *LIDConverter, apiToken string, logger *slog.Logger) (isGroup bool, chat string, sender string, reason string, conversions map[string]string, eventInfo *ConvertedEventInfo) {
 	conversions = make(map[string]string)
-
-	if logger != nil {
-		logger.Printf("🔍 [WEBHOOK] Iniciando análise do evento (tamanho: %d bytes)", len(jsonDataStr))
+	if logger == nil {
+		logger = slog.New(discardHandler{})
 	}
 
+	logger.DebugContext(ctx, "Iniciando análise do evento", "size", len(jsonDataStr))
+
 	// Se não há conversor configurado, usa função legada
 	if converter == nil {
-		if logger != nil {
-			logger.Printf("⚠️ [WEBHOOK] Conversor LID não configurado, usando parser legado")
-		}
+		logger.DebugContext(ctx, "Conversor LID não configurado, usando parser legado")
 		isGroup, chat, sender, reason = ExtractEventInfo(jsonDataStr)
 		return isGroup, chat, sender, reason, conversions, nil
 	}
 
-	// Debug: Log do token sendo usado
-	if logger != nil {
-		if apiToken != "" {
-			logger.Printf("🔑 [WEBHOOK] Usando token do cliente para conversão (token presente)")
-		} else {
-			logger.Printf("⚠️ [WEBHOOK] Token do cliente não encontrado - conversões podem falhar")
-		}
+	if apiToken == "" {
+		logger.DebugContext(ctx, "Token do cliente não encontrado - conversões via API podem falhar")
 	}
 
 	// Usa o novo conversor
 	convertedEventInfo, err := converter.DetectAndConvertIDs(ctx, jsonDataStr, apiToken)
 	if err != nil {
-		if logger != nil {
-			logger.Printf("❌ [WEBHOOK] Erro na conversão LID->JID: %v", err)
-		}
+		logger.WarnContext(ctx, "Erro na conversão LID->JID", "err", err)
 		// Fallback para função legada
 		isGroup, chat, sender, reason = ExtractEventInfo(jsonDataStr)
 
@@ -133,36 +163,20 @@ func ExtractEventInfoWithConversion(ctx context.Context, jsonDataStr string, con
 		}
 	}
 
-	// Log detalhado
-	if logger != nil {
-		logger.Printf("✅ [WEBHOOK] Análise concluída: IsGroup=%v, Chat=%s, Sender=%s", isGroup, chat, sender)
-
-		if len(conversions) > 0 {
-			logger.Printf("🔄 [WEBHOOK] Conversões realizadas:")
-			for field, conversion := range conversions {
-				logger.Printf("   - %s: %s", field, conversion)
-			}
-		} else {
-			logger.Printf("📝 [WEBHOOK] Nenhuma conversão LID->JID necessária")
-		}
-
-		// Log dos dados originais extraídos
-		if eventInfo.Chat != "" || eventInfo.Sender != "" || eventInfo.SenderAlt != "" || eventInfo.RecipientAlt != "" {
-			logger.Printf("📊 [WEBHOOK] Dados originais extraídos:")
-			if eventInfo.Chat != "" {
-				logger.Printf("   - Chat: %s", eventInfo.Chat)
-			}
-			if eventInfo.Sender != "" {
-				logger.Printf("   - Sender: %s", eventInfo.Sender)
-			}
-			if eventInfo.SenderAlt != "" {
-				logger.Printf("   - SenderAlt: %s", eventInfo.SenderAlt)
-			}
-			if eventInfo.RecipientAlt != "" {
-				logger.Printf("   - RecipientAlt: %s", eventInfo.RecipientAlt)
-			}
-		}
-	}
+	logger.DebugContext(ctx, "Análise concluída",
+		"is_group", isGroup, "chat", chat, "sender", sender,
+		"conversions", conversions,
+		"original_chat", eventInfo.Chat, "original_sender", eventInfo.Sender,
+		"sender_alt", eventInfo.SenderAlt, "recipient_alt", eventInfo.RecipientAlt,
+	)
 
 	return isGroup, chat, sender, reason, conversions, eventInfo
 }
+
+// discardHandler descarta todos os registros (slog.DiscardHandler só existe a partir do Go 1.24)
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+)
+
+// EvaluateRules retorna a primeira regra que casa com o evento. Sem match, o evento é encaminhado.
+func EvaluateRules(rules []models.FilterRule, evt *EventInfo) (*models.FilterRule, bool) {
+	for i := range rules {
+		if MatchRule(rules[i].Match, evt) {
+			return &rules[i], true
+		}
+	}
+	return nil, false
+}
+
+// MatchRule verifica se todos os critérios preenchidos casam com o evento
+func MatchRule(m models.RuleMatch, evt *EventInfo) bool {
+	if evt == nil {
+		return false
+	}
+	if m.IsGroup != nil && *m.IsGroup != evt.IsGroup {
+		return false
+	}
+	if m.FromMe != nil && *m.FromMe != evt.IsFromMe {
+		return false
+	}
+	if m.Chat != "" && !globMatch(m.Chat, evt.Chat) {
+		return false
+	}
+	if m.Sender != "" && !globMatch(m.Sender, evt.Sender) {
+		return false
+	}
+	if m.MessageType != "" && !globMatch(m.MessageType, evt.MessageType) {
+		return false
+	}
+	if len(m.EventTypes) > 0 {
+		matched := false
+		for _, t := range m.EventTypes {
+			if globMatch(t, evt.Type) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateRules confere ações, URLs de rota e padrões glob antes de gravar
+func ValidateRules(rules []models.FilterRule) error {
+	for i, r := range rules {
+		switch r.Action {
+		case models.RuleDrop, models.RuleForward:
+		case models.RuleRoute:
+			if !strings.HasPrefix(r.RouteURL, "http://") && !strings.HasPrefix(r.RouteURL, "https://") {
+				return fmt.Errorf("regra %d: routeUrl inválida", i)
+			}
+		default:
+			return fmt.Errorf("regra %d: action deve ser drop, forward ou route", i)
+		}
+		if err := ValidateMatch(r.Match); err != nil {
+			return fmt.Errorf("regra %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateMatch confere os padrões glob de uma regra/selector
+func ValidateMatch(m models.RuleMatch) error {
+	patterns := append([]string{m.Chat, m.Sender, m.MessageType}, m.EventTypes...)
+	for _, p := range patterns {
+		if _, err := path.Match(strings.ToLower(p), ""); err != nil {
+			return fmt.Errorf("padrão %q inválido", p)
+		}
+	}
+	return nil
+}
+
+// globMatch compara sem diferenciar maiúsculas; "*" casa qualquer sequência
+func globMatch(pattern, value string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && ok
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestMatchRule(t *testing.T) {
+	evt := &EventInfo{
+		Type:        "Message",
+		Chat:        "5511987654321@s.whatsapp.net",
+		Sender:      "5511987654321@s.whatsapp.net",
+		MessageType: "image",
+	}
+	group := &EventInfo{Type: "Message", Chat: "120363000000000000@g.us", Sender: "5511900000000@s.whatsapp.net", IsGroup: true, IsFromMe: true}
+
+	tests := []struct {
+		name  string
+		match models.RuleMatch
+		evt   *EventInfo
+		want  bool
+	}{
+		{"match vazio casa tudo", models.RuleMatch{}, evt, true},
+		{"evento nil", models.RuleMatch{}, nil, false},
+
+		{"isGroup true em grupo", models.RuleMatch{IsGroup: boolPtr(true)}, group, true},
+		{"isGroup true em chat privado", models.RuleMatch{IsGroup: boolPtr(true)}, evt, false},
+		{"isGroup false em chat privado", models.RuleMatch{IsGroup: boolPtr(false)}, evt, true},
+
+		{"fromMe true em envio próprio", models.RuleMatch{FromMe: boolPtr(true)}, group, true},
+		{"fromMe true em recebida", models.RuleMatch{FromMe: boolPtr(true)}, evt, false},
+		{"fromMe false em recebida", models.RuleMatch{FromMe: boolPtr(false)}, evt, true},
+
+		{"chat exato", models.RuleMatch{Chat: "5511987654321@s.whatsapp.net"}, evt, true},
+		{"chat glob", models.RuleMatch{Chat: "5511*"}, evt, true},
+		{"chat glob sem match", models.RuleMatch{Chat: "5521*"}, evt, false},
+		{"chat glob por sufixo", models.RuleMatch{Chat: "*@g.us"}, group, true},
+		{"chat ? casa um caractere", models.RuleMatch{Chat: "551198765432?@s.whatsapp.net"}, evt, true},
+		{"chat sem diferenciar maiúsculas", models.RuleMatch{Chat: "*@S.WHATSAPP.NET"}, evt, true},
+
+		{"sender glob", models.RuleMatch{Sender: "5511900000000@*"}, group, true},
+		{"sender sem match", models.RuleMatch{Sender: "5511900000000@*"}, evt, false},
+
+		{"messageType", models.RuleMatch{MessageType: "image"}, evt, true},
+		{"messageType glob", models.RuleMatch{MessageType: "IMA*"}, evt, true},
+		{"messageType sem match", models.RuleMatch{MessageType: "audio"}, evt, false},
+
+		{"eventTypes casa um da lista", models.RuleMatch{EventTypes: []string{"ReadReceipt", "Message"}}, evt, true},
+		{"eventTypes glob", models.RuleMatch{EventTypes: []string{"Mess*"}}, evt, true},
+		{"eventTypes sem match", models.RuleMatch{EventTypes: []string{"ReadReceipt", "Presence"}}, evt, false},
+
+		{"todos os critérios (AND)", models.RuleMatch{IsGroup: boolPtr(false), Chat: "5511*", MessageType: "image", EventTypes: []string{"Message"}}, evt, true},
+		{"um critério falha (AND)", models.RuleMatch{IsGroup: boolPtr(false), Chat: "5511*", MessageType: "audio"}, evt, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchRule(tt.match, tt.evt); got != tt.want {
+				t.Fatalf("MatchRule = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateRulesFirstMatch(t *testing.T) {
+	rules := []models.FilterRule{
+		{Name: "vip", Match: models.RuleMatch{Chat: "5511987654321@*"}, Action: models.RuleRoute, RouteURL: "https://vip.example"},
+		{Name: "sp", Match: models.RuleMatch{Chat: "5511*"}, Action: models.RuleDrop},
+	}
+	tests := []struct {
+		chat string
+		want string
+	}{
+		{"5511987654321@s.whatsapp.net", "vip"},
+		{"5511900000000@s.whatsapp.net", "sp"},
+		{"5521900000000@s.whatsapp.net", ""},
+	}
+	for _, tt := range tests {
+		rule, ok := EvaluateRules(rules, &EventInfo{Chat: tt.chat})
+		if tt.want == "" {
+			if ok {
+				t.Fatalf("%s: casou %q, want nenhuma", tt.chat, rule.Name)
+			}
+			continue
+		}
+		if !ok || rule.Name != tt.want {
+			t.Fatalf("%s: regra = %v, want %q", tt.chat, rule, tt.want)
+		}
+	}
+
+	// Sem regras configuradas valem as padrão: grupos descartados
+	rule, ok := EvaluateRules(models.DefaultFilterRules(), &EventInfo{Chat: "1@g.us", IsGroup: true})
+	if !ok || rule.Action != models.RuleDrop || rule.Name != models.DefaultGroupRuleName {
+		t.Fatalf("regra padrão = %v", rule)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.FilterRule
+		wantErr bool
+	}{
+		{"drop", models.FilterRule{Action: models.RuleDrop}, false},
+		{"forward", models.FilterRule{Action: models.RuleForward}, false},
+		{"route https", models.FilterRule{Action: models.RuleRoute, RouteURL: "https://x.example/hook"}, false},
+		{"route sem esquema", models.FilterRule{Action: models.RuleRoute, RouteURL: "x.example/hook"}, true},
+		{"ação desconhecida", models.FilterRule{Action: "block"}, true},
+		{"glob inválido no chat", models.FilterRule{Action: models.RuleDrop, Match: models.RuleMatch{Chat: "[abc"}}, true},
+		{"glob inválido em eventTypes", models.FilterRule{Action: models.RuleDrop, Match: models.RuleMatch{EventTypes: []string{"Message", "[x"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRules([]models.FilterRule{tt.rule})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
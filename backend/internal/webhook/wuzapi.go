@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+func init() { RegisterExtractor(wuzapiExtractor{}) }
+
+// wuzapiExtractor trata o formato wuzapi/Avisa: campo jsonData (form ou envelope JSON)
+// com o evento whatsmeow { type, event: { Info, Message } }.
+type wuzapiExtractor struct{}
+
+func (wuzapiExtractor) Name() string { return DefaultProvider }
+
+// Extract aceita, em ordem: m.jsonData, m.body com JSON-como-chave/valor, m.body.jsonData
+// e, por fim, o corpo inteiro como evento. Forms usam o campo jsonData.
+func (wuzapiExtractor) Extract(body []byte, contentType string) (string, error) {
+	var payload string
+	if isJSONContent(contentType) {
+		payload = wuzapiFromJSON(body)
+	} else {
+		payload = formValue(body, contentType, "jsonData")
+	}
+	if strings.TrimSpace(payload) == "" {
+		return "", ErrEmptyPayload
+	}
+	return payload, nil
+}
+
+func wuzapiFromJSON(body []byte) string {
+	bodyTrim := strings.TrimSpace(string(body))
+	if bodyTrim == "" {
+		return ""
+	}
+	var m map[string]any
+	if err := json.Unmarshal(body, &m); err != nil {
+		return bodyTrim
+	}
+	if v, ok := m["jsonData"].(string); ok && strings.TrimSpace(v) != "" {
+		return v
+	}
+	inner, ok := m["body"].(map[string]any)
+	if !ok {
+		// Sem envelope: o corpo inteiro é o evento
+		return bodyTrim
+	}
+	// Caso especial: body tem uma chave que é o JSON inteiro (form mal convertido)
+	for key, value := range inner {
+		if strings.HasPrefix(key, "{") && strings.HasSuffix(key, "}") {
+			return key
+		}
+		if v, ok := value.(string); ok && strings.HasPrefix(v, "{") {
+			return v
+		}
+	}
+	if v, ok := inner["jsonData"].(string); ok && strings.TrimSpace(v) != "" {
+		return v
+	}
+	return bodyTrim
+}
+
+func (wuzapiExtractor) Normalize(payload string) (*Event, error) {
+	return NormalizeEvent(payload)
+}
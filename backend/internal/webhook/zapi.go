@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+func init() { RegisterExtractor(zapiExtractor{}) }
+
+// zapiExtractor trata o Z-API: corpo JSON plano { type, instanceId, messageId, phone, text, image, ... }
+type zapiExtractor struct{}
+
+func (zapiExtractor) Name() string { return "zapi" }
+
+func (zapiExtractor) Extract(body []byte, contentType string) (string, error) {
+	payload := strings.TrimSpace(string(body))
+	if payload == "" || !json.Valid(body) {
+		return "", ErrEmptyPayload
+	}
+	return payload, nil
+}
+
+type zapiMedia struct {
+	ImageURL    string `json:"imageUrl"`
+	AudioURL    string `json:"audioUrl"`
+	VideoURL    string `json:"videoUrl"`
+	DocumentURL string `json:"documentUrl"`
+	StickerURL  string `json:"stickerUrl"`
+	MimeType    string `json:"mimeType"`
+	Caption     string `json:"caption"`
+	FileName    string `json:"fileName"`
+}
+
+func (zapiExtractor) Normalize(payload string) (*Event, error) {
+	raw := DecodeJSONData(payload)
+	var env struct {
+		Type             string `json:"type"`
+		InstanceID       string `json:"instanceId"`
+		MessageID        string `json:"messageId"`
+		Phone            string `json:"phone"`
+		FromMe           bool   `json:"fromMe"`
+		Momment          any    `json:"momment"`
+		IsGroup          bool   `json:"isGroup"`
+		ParticipantPhone string `json:"participantPhone"`
+		SenderName       string `json:"senderName"`
+		Text             *struct {
+			Message string `json:"message"`
+		} `json:"text"`
+		Image    *zapiMedia `json:"image"`
+		Audio    *zapiMedia `json:"audio"`
+		Video    *zapiMedia `json:"video"`
+		Document *zapiMedia `json:"document"`
+		Sticker  *zapiMedia `json:"sticker"`
+	}
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, err
+	}
+	evt := &Event{
+		ID:        env.MessageID,
+		Type:      env.Type,
+		Instance:  env.InstanceID,
+		Chat:      phoneJID(env.Phone, env.IsGroup),
+		FromMe:    env.FromMe,
+		PushName:  env.SenderName,
+		Timestamp: parseTimestamp(env.Momment),
+		Raw:       json.RawMessage(raw),
+	}
+	evt.IsGroup = env.IsGroup || isGroupChat(evt.Chat)
+	if env.ParticipantPhone != "" {
+		evt.Sender = phoneJID(env.ParticipantPhone, false)
+	} else {
+		evt.Sender = evt.Chat
+	}
+	if env.Text != nil {
+		evt.Text = env.Text.Message
+		evt.MessageType = "text"
+	}
+	for _, m := range []struct {
+		kind string
+		node *zapiMedia
+	}{{"image", env.Image}, {"audio", env.Audio}, {"video", env.Video}, {"document", env.Document}, {"sticker", env.Sticker}} {
+		if m.node == nil {
+			continue
+		}
+		evt.Media = &Media{
+			Kind:     m.kind,
+			MimeType: m.node.MimeType,
+			URL:      firstNonEmpty(m.node.ImageURL, m.node.AudioURL, m.node.VideoURL, m.node.DocumentURL, m.node.StickerURL),
+			Caption:  m.node.Caption,
+			FileName: m.node.FileName,
+		}
+		evt.MessageType = m.kind
+		if evt.Text == "" {
+			evt.Text = m.node.Caption
+		}
+		break
+	}
+	return evt, nil
+}
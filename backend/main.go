@@ -2,23 +2,38 @@ package main
 
 import (
 	"context"
-	"io"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/aggregate"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/breaker"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/cache"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/config"
 	dbpkg "github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/db"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/dedup"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/delivery"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/health"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/ingest"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/logging"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/metrics"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/migrations"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/models"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/notify"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/ratelimit"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/repository"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/router"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/secrets"
 	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/service"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/tracing"
+	"github.com/JoaoCarlosAssis/MS_SDR_FIX/internal/webhook"
 )
 
 func main() {
@@ -29,14 +44,16 @@ func main() {
 
 	cfg := config.Load()
 
-	// Logger básico controlado por env
-	var infoLogger *log.Logger
-	if strings.EqualFold(os.Getenv("LOG_LEVEL"), "error") {
-		infoLogger = log.New(io.Discard, "", 0) // silencia INFO
-	} else {
-		infoLogger = log.New(os.Stdout, "", log.LstdFlags)
+	// Logger estruturado controlado por env (LOG_LEVEL, LOG_FORMAT)
+	logger := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(logger)
+
+	// Tracing OpenTelemetry (OTLP/HTTP via OTEL_*); sem endpoint só propaga o traceparent
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingEnabled, cfg.TracingServiceName)
+	if err != nil {
+		logger.Error("erro ao configurar tracing", "err", err)
+		os.Exit(1)
 	}
-	errorLogger := log.New(os.Stderr, "", log.LstdFlags)
 
 	// HTTP client compartilhado
 	httpClient := &http.Client{Timeout: 15 * time.Second}
@@ -46,51 +63,240 @@ func main() {
 	defer cancel()
 	pool, err := dbpkg.Connect(ctx, cfg.DatabaseURL)
 	if err != nil {
-		errorLogger.Fatalf("erro ao conectar no banco: %v", err)
+		logger.Error("erro ao conectar no banco", "err", err)
+		os.Exit(1)
 	}
-	defer pool.Close()
 
 	// Migrations mínimas
 	if err := migrations.Run(ctx, pool); err != nil {
-		errorLogger.Fatalf("erro ao rodar migrations: %v", err)
+		logger.Error("erro ao rodar migrations", "err", err)
+		os.Exit(1)
 	}
 
 	// Cache em memória
-	memoryCache := cache.NewMemoryCache[string, string](cfg.CacheTTL)
+	memoryCache := cache.NewMemoryCache[string, *models.Client](cfg.CacheTTL)
 	go memoryCache.StartJanitor()
 
+	// Rate limit por secretId
+	limiter := ratelimit.NewLimiter()
+	go limiter.StartJanitor()
+
+	// Cifra de segredos dos clientes (tokens de provider)
+	box, err := secrets.NewBox(cfg.EncryptionKey)
+	if err != nil {
+		logger.Error("erro na ENCRYPTION_KEY", "err", err)
+		os.Exit(1)
+	}
+	if !box.Enabled() {
+		// Token do provider e app secret da Meta não podem ir em texto puro para o banco
+		if !cfg.IsDevelopment() {
+			logger.Error("ENCRYPTION_KEY ausente; obrigatória fora de APP_ENV=development")
+			os.Exit(1)
+		}
+		logger.Warn("ENCRYPTION_KEY ausente (APP_ENV=development); segredos de clientes serão gravados sem cifra")
+	}
+
 	// Repos e Services
-	clientRepo := repository.NewClientRepository(pool)
+	clientRepo := repository.NewClientRepository(pool, box)
 	clientService := service.NewClientService(clientRepo)
+	destinationService := service.NewDestinationService(repository.NewDestinationRepository(pool))
+	clientResolver := service.NewClientResolver(memoryCache, clientService, destinationService)
+	clientResolver.SigningGrace = cfg.SigningSecretGrace
+	deliveryRepo := repository.NewDeliveryRepository(pool)
+	deadLetterRepo := repository.NewDeadLetterRepository(pool)
+	deliveryLogRepo := repository.NewDeliveryLogRepository(pool)
+
+	// Mapeamentos LID->JID (Postgres + cache em memória)
+	lidCache := cache.NewMemoryCache[string, string](cfg.LIDCacheTTL)
+	go lidCache.StartJanitor()
+	// LIDs sem mapeamento (banco ou API) ficam em cache curto para não repetir a consulta a cada evento
+	lidMissCache := cache.NewMemoryCache[string, struct{}](cfg.LIDMissTTL)
+	go lidMissCache.StartJanitor()
+	lidAPIMissCache := cache.NewMemoryCache[string, struct{}](cfg.LIDMissTTL)
+	go lidAPIMissCache.StartJanitor()
+	lidMappingService := service.NewLIDMappingService(repository.NewLIDMappingRepository(pool), lidCache, lidMissCache, logger)
+	// Mapeamentos aprendidos são gravados em background, fora do caminho do webhook
+	lidMappingService.Start()
+
+	// Chaves da API admin (hash no Postgres + cache curto em memória)
+	apiKeyCache := cache.NewMemoryCache[string, *models.APIKey](cfg.APIKeyCacheTTL)
+	go apiKeyCache.StartJanitor()
+	apiKeyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(pool), apiKeyCache, logger)
+
+	// Dedup por ID de mensagem (cache + Postgres)
+	deduper := dedup.NewDeduper(repository.NewProcessedEventRepository(pool), cfg.DedupWindow, logger)
+	go deduper.StartJanitor()
+
+	// Métricas Prometheus (/metrics); em events+summary também um registro por webhook
+	collector := metrics.New(metrics.Options{
+		Mode:            metrics.ParseMode(cfg.MetricsMode),
+		MaxClientLabels: cfg.MetricsMaxClientLabels,
+	})
+	collector.RegisterCache("client", memoryCache.Stats)
+	collector.RegisterCache("lid", lidCache.Stats)
+	lidConverters := webhook.NewLIDConverters(lidMappingService, collector.LIDConversion, lidAPIMissCache)
+
+	// Eventos do ciclo de vida para o painel admin (ADMIN_INGEST_URL); nil desativa
+	var shipper *ingest.Shipper
+	if cfg.AdminIngestURL != "" {
+		shipper = ingest.NewShipper(cfg.AdminIngestURL, cfg.AdminServiceToken, httpClient, ingest.Options{
+			BatchSize:     cfg.IngestBatchSize,
+			FlushInterval: cfg.IngestFlushInterval,
+			BufferSize:    cfg.IngestBufferSize,
+			MaxRetries:    cfg.IngestMaxRetries,
+		}, logger)
+		shipper.Start()
+		collector.RegisterCounterFunc("msdr_ingest_dropped_total", "Eventos de ingest descartados (buffer cheio ou lote sem sucesso).", shipper.Dropped)
+	}
+
+	// Agregação de rajadas por chat/sender (janela por cliente)
+	// Eventos segurados ficam no Postgres até a rajada ser enfileirada
+	aggregator := aggregate.NewAggregator(repository.NewBurstBufferRepository(pool), logger)
+
+	// Alertas Slack e fila de entregas
+	notifier := notify.NewNotifier(cfg.SlackWebhookURL, cfg.SlackBotToken, cfg.SlackChannelID, logger)
+	circuits := breaker.New(breaker.Options{
+		FailureThreshold: cfg.BreakerFailureThreshold,
+		ErrorRate:        cfg.BreakerErrorRate,
+		MinRequests:      cfg.BreakerMinRequests,
+		Window:           cfg.BreakerWindow,
+		OpenTimeout:      cfg.BreakerOpenTimeout,
+		HalfOpenProbes:   cfg.BreakerHalfOpenProbes,
+	})
+	dispatcher := delivery.NewDispatcher(deliveryRepo, httpClient, clientResolver, notifier, delivery.Options{
+		Workers:      cfg.DeliveryWorkers,
+		MaxAttempts:  cfg.DeliveryMaxAttempts,
+		BackoffBase:  cfg.DeliveryBackoffBase,
+		BackoffMax:   cfg.DeliveryBackoffMax,
+		PollInterval: cfg.DeliveryPollInterval,
+		Breaker:      circuits,
+		Log:          deliveryLogRepo,
+		LogRetention: cfg.DeliveryLogRetention,
+		Retention:    cfg.OutboxRetention,
+		Metrics:      collector,
+		Ingest:       shipper,
+	}, logger)
+	dispatcher.Start()
+
+	// Readiness e health detalhado (Postgres, migrations, fila, breakers, API LID, ingest)
+	checker := &health.Checker{
+		Pool:       pool,
+		Deliveries: deliveryRepo,
+		Breaker:    circuits,
+		Ingest:     shipper,
+		HTTPClient: httpClient,
+		LIDAPIURL:  cfg.AvisaAPIURL,
+		MaxBacklog: int64(cfg.ReadyMaxBacklog),
+	}
 
 	// Gin
 	r := gin.New()
 	r.Use(gin.Recovery())
+	// X-Request-ID e logger da requisição no contexto antes dos demais middlewares;
+	// a linha por request sai em nível info
+	r.Use(router.RequestID(), router.RequestLogger(logger))
 	if cfg.SlackWebhookURL != "" || (cfg.SlackBotToken != "" && cfg.SlackChannelID != "") {
-		r.Use(router.RecoveryWithSlack(cfg.SlackWebhookURL, cfg.SlackBotToken, cfg.SlackChannelID, errorLogger))
-	}
-	// Registra logging de requests somente se não estivermos em nível 'error'
-	if !strings.EqualFold(os.Getenv("LOG_LEVEL"), "error") {
-		r.Use(router.RequestLogger())
+		r.Use(router.RecoveryWithSlack(notifier))
 	}
 
 	// Registrar rotas
 	router.Register(
 		r,
 		router.Dependencies{
-			Config:      cfg,
-			Cache:       memoryCache,
-			HTTPClient:  httpClient,
-			ClientSvc:   clientService,
-			InfoLogger:  infoLogger,
-			ErrorLogger: errorLogger,
+			Config:       cfg,
+			Resolver:     clientResolver,
+			Limiter:      limiter,
+			Deduper:      deduper,
+			Aggregator:   aggregator,
+			Dispatcher:   dispatcher,
+			Breaker:      circuits,
+			Metrics:      collector,
+			Ingest:       shipper,
+			Health:       checker,
+			Notifier:     notifier,
+			HTTPClient:   httpClient,
+			ClientSvc:    clientService,
+			Destinations: destinationService,
+			LIDMappings:  lidMappingService,
+			APIKeys:      apiKeyService,
+			Deliveries:   deliveryRepo,
+			DeadLetters:  deadLetterRepo,
+			DeliveryLog:  deliveryLogRepo,
+			Logger:       logger,
+
+			LIDConverters: lidConverters,
 		},
 	)
 
-	if !strings.EqualFold(os.Getenv("LOG_LEVEL"), "error") {
-		infoLogger.Printf("Servidor iniciado. Porta %s", cfg.Port)
+	// Rajadas que ficaram no Postgres num encerramento anterior saem antes de aceitar tráfego
+	recoverCtx, cancelRecover := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := aggregator.Recover(recoverCtx); err != nil {
+		logger.Error("Erro ao recuperar rajadas pendentes", "err", err)
+	}
+	cancelRecover()
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("Servidor iniciado", "port", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	// SIGTERM (redeploy) ou SIGINT iniciam o shutdown gracioso
+	sigCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	select {
+	case err := <-serverErr:
+		logger.Error("erro ao iniciar servidor", "err", err)
+		os.Exit(1)
+	case <-sigCtx.Done():
+	}
+	stopSignals()
+	logger.Info("Shutdown iniciado", "timeout", cfg.ShutdownTimeout.String())
+	// readyz passa a responder 503 para o orquestrador tirar a instância do balanceamento
+	checker.SetDraining()
+
+	// Um único prazo para todas as etapas; cada uma usa o que restar dele
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+
+	// 1. Para de aceitar conexões e aguarda os webhooks em andamento
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Shutdown do servidor HTTP incompleto", "err", err)
+	}
+	// 2. Rajadas ainda abertas vão para a outbox
+	aggregator.FlushAll()
+	// 3. Workers entregam o que já venceu na fila; o restante fica na outbox
+	if err := dispatcher.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Fila de entregas não esvaziou no prazo", "err", err)
+	}
+	// 4. Alertas do Slack em envio, eventos de ingest e spans pendentes
+	if err := notifier.Wait(shutdownCtx); err != nil {
+		logger.Error("Alertas do Slack não enviados no prazo", "err", err)
+	}
+	if err := lidMappingService.Stop(shutdownCtx); err != nil {
+		logger.Error("Mapeamentos LID pendentes não gravados no prazo", "err", err)
+	}
+	if err := shipper.Stop(shutdownCtx); err != nil {
+		logger.Error("Ingest não esvaziou no prazo", "err", err)
 	}
-	if err := r.Run(":" + cfg.Port); err != nil {
-		errorLogger.Fatalf("erro ao iniciar servidor: %v", err)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("Erro ao enviar spans pendentes", "err", err)
 	}
+	// 5. Janitors e, por último, o pool do Postgres
+	memoryCache.StopJanitor()
+	lidCache.StopJanitor()
+	lidMissCache.StopJanitor()
+	lidAPIMissCache.StopJanitor()
+	apiKeyCache.StopJanitor()
+	limiter.StopJanitor()
+	deduper.StopJanitor()
+	pool.Close()
+	logger.Info("Shutdown concluído")
 }
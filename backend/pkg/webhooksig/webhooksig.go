@@ -0,0 +1,122 @@
+// Package webhooksig assina e verifica webhooks encaminhados pelo MS_SDR.
+//
+// Cada requisição encaminhada traz:
+//
+//	X-Webhook-Id:        id da entrega (estável entre retries)
+//	X-Webhook-Timestamp: unix seconds do envio
+//	X-Webhook-Signature: v1=<hex hmac-sha256(secret, timestamp + "." + body)>
+//
+// Durante a rotação do segredo o header pode trazer mais de uma assinatura
+// separadas por vírgula; basta uma conferir.
+//
+// Uso no destino:
+//
+//	body, err := webhooksig.VerifyRequest(r, os.Getenv("MS_SDR_SIGNING_SECRET"), webhooksig.DefaultTolerance)
+//	if err != nil {
+//		http.Error(w, "assinatura inválida", http.StatusUnauthorized)
+//		return
+//	}
+package webhooksig
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	// DefaultTolerance é a janela aceita entre o timestamp assinado e o relógio do destino
+	DefaultTolerance = 5 * time.Minute
+
+	secretPrefix = "whsec_"
+	version      = "v1="
+)
+
+var (
+	ErrMissingHeaders    = errors.New("webhooksig: headers de assinatura ausentes")
+	ErrInvalidTimestamp  = errors.New("webhooksig: timestamp inválido")
+	ErrTimestampExpired  = errors.New("webhooksig: timestamp fora da tolerância")
+	ErrSignatureMismatch = errors.New("webhooksig: assinatura não confere")
+)
+
+// NewSecret gera um segredo aleatório de 32 bytes no formato whsec_<base64url>
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sign calcula a assinatura "v1=<hex>" para o timestamp e corpo
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return version + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignHeader monta o valor de X-Webhook-Signature com uma assinatura por segredo
+func SignHeader(secrets []string, timestamp int64, body []byte) string {
+	sigs := make([]string, 0, len(secrets))
+	for _, s := range secrets {
+		if s != "" {
+			sigs = append(sigs, Sign(s, timestamp, body))
+		}
+	}
+	return strings.Join(sigs, ",")
+}
+
+// Verify confere os headers contra o corpo. tolerance <= 0 desativa a checagem de tempo.
+func Verify(secret, signatureHeader, timestampHeader string, body []byte, tolerance time.Duration) error {
+	if signatureHeader == "" || timestampHeader == "" {
+		return ErrMissingHeaders
+	}
+	ts, err := strconv.ParseInt(strings.TrimSpace(timestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	if tolerance > 0 {
+		diff := time.Since(time.Unix(ts, 0))
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > tolerance {
+			return ErrTimestampExpired
+		}
+	}
+	expected := Sign(secret, ts, body)
+	for _, sig := range strings.Split(signatureHeader, ",") {
+		if hmac.Equal([]byte(strings.TrimSpace(sig)), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+// VerifyRequest lê o corpo, verifica a assinatura e restaura r.Body para o handler
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := Verify(secret, r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), body, tolerance); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
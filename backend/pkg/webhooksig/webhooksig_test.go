@@ -0,0 +1,99 @@
+package webhooksig
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// Vetor calculado fora do Go: hmac-sha256("whsec_test", "1700000000." + body)
+	got := Sign("whsec_test", 1700000000, []byte(`{"ok":true}`))
+	want := "v1=85876387ad9d6be57a04653bc0729da757049f58afb10ba6cac3bedaecf4fda3"
+	if got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestSignHeader(t *testing.T) {
+	body := []byte("x")
+	got := SignHeader([]string{"new", "", "old"}, 10, body)
+	want := Sign("new", 10, body) + "," + Sign("old", 10, body)
+	if got != want {
+		t.Fatalf("SignHeader = %s, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"event":{"Info":{"ID":"ABC"}}}`)
+	now := time.Now().Unix()
+	ts := strconv.FormatInt(now, 10)
+	old := strconv.FormatInt(now-int64((10*time.Minute).Seconds()), 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		tolerance time.Duration
+		want      error
+	}{
+		{"válida", "s1", Sign("s1", now, body), ts, body, DefaultTolerance, nil},
+		{"corpo adulterado", "s1", Sign("s1", now, body), ts, []byte(`{"event":{}}`), DefaultTolerance, ErrSignatureMismatch},
+		{"segredo errado", "s2", Sign("s1", now, body), ts, body, DefaultTolerance, ErrSignatureMismatch},
+		{"timestamp fora da tolerância", "s1", Sign("s1", now-600, body), old, body, DefaultTolerance, ErrTimestampExpired},
+		{"timestamp no futuro", "s1", Sign("s1", now+600, body), strconv.FormatInt(now+600, 10), body, DefaultTolerance, ErrTimestampExpired},
+		{"tolerância desativada", "s1", Sign("s1", now-600, body), old, body, 0, nil},
+		{"timestamp trocado", "s1", Sign("s1", now-1, body), ts, body, DefaultTolerance, ErrSignatureMismatch},
+		{"timestamp inválido", "s1", Sign("s1", now, body), "abc", body, DefaultTolerance, ErrInvalidTimestamp},
+		{"sem assinatura", "s1", "", ts, body, DefaultTolerance, ErrMissingHeaders},
+		{"sem timestamp", "s1", Sign("s1", now, body), "", body, DefaultTolerance, ErrMissingHeaders},
+		{"rotação: segredo novo", "new", SignHeader([]string{"new", "old"}, now, body), ts, body, DefaultTolerance, nil},
+		{"rotação: segredo anterior", "old", SignHeader([]string{"new", "old"}, now, body), ts, body, DefaultTolerance, nil},
+		{"rotação: espaços após a vírgula", "old", Sign("new", now, body) + ", " + Sign("old", now, body), ts, body, DefaultTolerance, nil},
+		{"rotação: nenhum confere", "other", SignHeader([]string{"new", "old"}, now, body), ts, body, DefaultTolerance, ErrSignatureMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.signature, tt.timestamp, tt.body, tt.tolerance)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRequestRestoresBody(t *testing.T) {
+	body := []byte("a=1&b=2")
+	now := time.Now().Unix()
+	r := httptest.NewRequest("POST", "/hook", bytes.NewReader(body))
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(now, 10))
+	r.Header.Set(HeaderSignature, Sign("s1", now, body))
+
+	got, err := VerifyRequest(r, "s1", DefaultTolerance)
+	if err != nil {
+		t.Fatalf("VerifyRequest: %v", err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Fatalf("corpo = %q, want %q", got, body)
+	}
+	if again, _ := io.ReadAll(r.Body); !bytes.Equal(again, body) {
+		t.Fatalf("r.Body não restaurado: %q", again)
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSecret()
+	if a == b || len(a) <= len(secretPrefix) || a[:len(secretPrefix)] != secretPrefix {
+		t.Fatalf("segredos inválidos: %q %q", a, b)
+	}
+}